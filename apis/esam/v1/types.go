@@ -0,0 +1,28 @@
+// Package v1 contains the esam v1 API types.
+package v1
+
+import metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+
+// AccessVerify records the verification of a card presented at a station.
+type AccessVerify struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AccessVerifySpec `json:"spec,omitempty"`
+}
+
+// AccessVerifySpec describes who requested access, and where.
+type AccessVerifySpec struct {
+	// StationID identifies the station the card was presented at.
+	StationID string `json:"stationId,omitempty"`
+	// Operator is the operator the station belongs to.
+	Operator string `json:"operator,omitempty"`
+	// CardNumber is the number of the card to verify.
+	CardNumber string `json:"cardNumber,omitempty"`
+}
+
+// AccessVerifyList is a list of AccessVerify objects.
+type AccessVerifyList struct {
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []AccessVerify `json:"items"`
+}
@@ -0,0 +1,66 @@
+// Package v1 contains the metadata shared by every API object.
+package v1
+
+// Object lets the informers and caches read the metadata of any API object.
+// Every type embedding ObjectMeta implements it through a pointer.
+type Object interface {
+	GetName() string
+	GetNamespace() string
+	GetResourceVersion() string
+	GetLabels() map[string]string
+}
+
+// ObjectMeta is metadata that all persisted resources must have.
+type ObjectMeta struct {
+	// Name is unique within a namespace.
+	Name string `json:"name,omitempty"`
+	// Namespace defines the space within which each name must be unique. An
+	// empty namespace is equivalent to the "default" namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// ResourceVersion is an opaque value that changes whenever the object
+	// changes. Clients must treat it as opaque and pass it unmodified back
+	// to the server.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// Labels are key value pairs used to organize and select objects.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func (m *ObjectMeta) GetName() string                   { return m.Name }
+func (m *ObjectMeta) GetNamespace() string              { return m.Namespace }
+func (m *ObjectMeta) GetResourceVersion() string        { return m.ResourceVersion }
+func (m *ObjectMeta) GetLabels() map[string]string      { return m.Labels }
+func (m *ObjectMeta) SetResourceVersion(version string) { m.ResourceVersion = version }
+
+// ListMeta describes metadata that synthetic resources must have, including
+// lists.
+type ListMeta struct {
+	// ResourceVersion is the version of the collection the list was read at,
+	// from which a watch can be started.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// Continue is set if there are more results to be listed, and is passed
+	// back to the server to fetch them.
+	Continue string `json:"continue,omitempty"`
+}
+
+// ListOptions is the query options to a List or Watch call.
+type ListOptions struct {
+	// LabelSelector restricts the list of returned objects by their labels,
+	// in the syntax of labels.Parse. Defaults to everything.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// ResourceVersion sets a constraint on what resource versions a request
+	// may be served from. For a watch, it is the version to start watching
+	// from.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// TimeoutSeconds limits the duration of the call, regardless of any
+	// activity or inactivity.
+	TimeoutSeconds *int64 `json:"timeoutSeconds,omitempty"`
+	// Limit is the maximum number of results to return for a list call.
+	Limit int64 `json:"limit,omitempty"`
+	// Continue is the token returned by the server in a previous list call
+	// to fetch the next chunk of results.
+	Continue string `json:"continue,omitempty"`
+}
+
+// NamespaceAll is the default argument to specify on a context when you want
+// to list or filter resources across all namespaces.
+const NamespaceAll string = ""
@@ -0,0 +1,30 @@
+// Package v1 contains the services v1 API types.
+package v1
+
+import metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+
+// SendQRCode asks for a QR code to be shown on a station.
+type SendQRCode struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SendQRCodeSpec `json:"spec,omitempty"`
+}
+
+// SendQRCodeSpec describes the QR code and the station to show it on.
+type SendQRCodeSpec struct {
+	// StationID identifies the station to show the QR code on.
+	StationID string `json:"stationId,omitempty"`
+	// Operator is the operator the station belongs to.
+	Operator string `json:"operator,omitempty"`
+	// Content is the text encoded in the QR code.
+	Content string `json:"content,omitempty"`
+	// Image is the rendered QR code.
+	Image []byte `json:"image,omitempty"`
+}
+
+// SendQRCodeList is a list of SendQRCode objects.
+type SendQRCodeList struct {
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []SendQRCode `json:"items"`
+}
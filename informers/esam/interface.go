@@ -10,13 +10,15 @@ type Interface interface {
 }
 
 type group struct {
-	factory internalinterfaces.SharedInformerFactory
+	factory          internalinterfaces.SharedInformerFactory
+	namespace        string
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
 }
 
-func New(f internalinterfaces.SharedInformerFactory) Interface {
-	return &group{factory: f}
+func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakListOptions internalinterfaces.TweakListOptionsFunc) Interface {
+	return &group{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
 func (g *group) V1() v1.Interface {
-	return v1.New(g.factory)
+	return v1.New(g.factory, g.namespace, g.tweakListOptions)
 }
@@ -1,18 +1,82 @@
 package v1
 
 import (
+	"context"
+	"time"
+
+	esamv1 "github.com/ForbiddenR/jxclient-go/apis/esam/v1"
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
 	internalinterfaces "github.com/ForbiddenR/jxclient-go/informers/internalinterfaces"
+	jxclient "github.com/ForbiddenR/jxclient-go/jxclient"
+	esamlisters "github.com/ForbiddenR/jxclient-go/listers/esam/v1"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
 	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
 )
 
+// AccessVerifyInformer provides access to a shared informer for
+// AccessVerifies.
 type AccessVerifyInformer interface {
-	Informer() cache.SharedInformer
+	Informer() cache.SharedIndexInformer[*esamv1.AccessVerify]
+	Lister() esamlisters.AccessVerifyLister
 }
 
 type accessVerifyInformer struct {
-	factory internalinterfaces.SharedInformerFactory
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewAccessVerifyInformer constructs a new informer for AccessVerify type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewAccessVerifyInformer(client jxclient.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers[*esamv1.AccessVerify]) cache.SharedIndexInformer[*esamv1.AccessVerify] {
+	return NewFilteredAccessVerifyInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredAccessVerifyInformer constructs a new informer for AccessVerify type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredAccessVerifyInformer(client jxclient.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers[*esamv1.AccessVerify], tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer[*esamv1.AccessVerify] {
+	return cache.NewSharedIndexInformer[*esamv1.AccessVerify](
+		&cache.ListWatch[*esamv1.AccessVerify]{
+			ListFunc: func(options metav1.ListOptions) (*cache.ListResult[*esamv1.AccessVerify], error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				list, err := client.EsamV1().AccessVerifies(namespace).List(context.TODO(), options)
+				if err != nil {
+					return nil, err
+				}
+				result := &cache.ListResult[*esamv1.AccessVerify]{
+					ListMeta: list.ListMeta,
+					Items:    make([]*esamv1.AccessVerify, 0, len(list.Items)),
+				}
+				for i := range list.Items {
+					result.Items = append(result.Items, &list.Items[i])
+				}
+				return result, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface[*esamv1.AccessVerify], error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.EsamV1().AccessVerifies(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&esamv1.AccessVerify{},
+		resyncPeriod,
+		indexers,
+	)
 }
 
-func (a *accessVerifyInformer) Informer() cache.SharedInformer {
-	return a.factory.InformerFor(struct{}{})
-}
\ No newline at end of file
+func (a *accessVerifyInformer) defaultInformer(client jxclient.Interface, resyncPeriod time.Duration) cache.Informer {
+	return NewFilteredAccessVerifyInformer(client, a.namespace, resyncPeriod, DefaultAccessVerifyIndexers(), a.tweakListOptions)
+}
+
+func (a *accessVerifyInformer) Informer() cache.SharedIndexInformer[*esamv1.AccessVerify] {
+	return a.factory.InformerFor(&esamv1.AccessVerify{}, a.defaultInformer).(cache.SharedIndexInformer[*esamv1.AccessVerify])
+}
+
+func (a *accessVerifyInformer) Lister() esamlisters.AccessVerifyLister {
+	return esamlisters.NewAccessVerifyLister(a.Informer().GetIndexer())
+}
@@ -0,0 +1,43 @@
+package v1
+
+import (
+	esamv1 "github.com/ForbiddenR/jxclient-go/apis/esam/v1"
+	esamlisters "github.com/ForbiddenR/jxclient-go/listers/esam/v1"
+	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
+)
+
+const (
+	// StationIDIndex is the name of the index of AccessVerifies by spec.stationId.
+	StationIDIndex = "stationId"
+	// OperatorIndex is the name of the index of AccessVerifies by
+	// spec.operator, which the operator scoped lists of the lister read.
+	OperatorIndex = esamlisters.OperatorIndex
+	// CardNumberIndex is the name of the index of AccessVerifies by spec.cardNumber.
+	CardNumberIndex = "cardNumber"
+)
+
+// StationIDIndexFunc indexes an AccessVerify by the station it was requested at.
+func StationIDIndexFunc(obj *esamv1.AccessVerify) ([]string, error) {
+	return []string{obj.Spec.StationID}, nil
+}
+
+// OperatorIndexFunc indexes an AccessVerify by the operator of its station.
+func OperatorIndexFunc(obj *esamv1.AccessVerify) ([]string, error) {
+	return []string{obj.Spec.Operator}, nil
+}
+
+// CardNumberIndexFunc indexes an AccessVerify by the card being verified.
+func CardNumberIndexFunc(obj *esamv1.AccessVerify) ([]string, error) {
+	return []string{obj.Spec.CardNumber}, nil
+}
+
+// DefaultAccessVerifyIndexers returns the indexers installed on the shared
+// AccessVerify informer: namespace, station ID, operator and card number.
+func DefaultAccessVerifyIndexers() cache.Indexers[*esamv1.AccessVerify] {
+	return cache.Indexers[*esamv1.AccessVerify]{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc[*esamv1.AccessVerify],
+		StationIDIndex:       StationIDIndexFunc,
+		OperatorIndex:        OperatorIndexFunc,
+		CardNumberIndex:      CardNumberIndexFunc,
+	}
+}
@@ -3,7 +3,9 @@ package informers
 import (
 	"reflect"
 	"sync"
+	"time"
 
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
 	esam "github.com/ForbiddenR/jxclient-go/informers/esam"
 	internalinterfaces "github.com/ForbiddenR/jxclient-go/informers/internalinterfaces"
 	services "github.com/ForbiddenR/jxclient-go/informers/services"
@@ -11,10 +13,16 @@ import (
 	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
 )
 
+// SharedInformerOption defines the functional option type for SharedInformerFactory.
+type SharedInformerOption func(*sharedInformerFactory) *sharedInformerFactory
+
 type sharedInformerFactory struct {
-	client    jxclient.Interface
-	lock      sync.Mutex
-	informers map[reflect.Type]cache.SharedInformer
+	client           jxclient.Interface
+	namespace        string
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	lock             sync.Mutex
+	defaultResync    time.Duration
+	informers        map[reflect.Type]cache.Informer
 	// staredInformers is used for tracking which informers have been started.
 	// This allows Start() to be called multiple times safely.
 	staredInformers map[reflect.Type]bool
@@ -25,6 +33,50 @@ type sharedInformerFactory struct {
 	shuttingDown bool
 }
 
+// WithTweakListOptions sets a custom filter on all listers of the configured SharedInformerFactory,
+// for example a label selector:
+//
+//	informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+//		options.LabelSelector = selector.String()
+//	})
+func WithTweakListOptions(tweakListOptions internalinterfaces.TweakListOptionsFunc) SharedInformerOption {
+	return func(factory *sharedInformerFactory) *sharedInformerFactory {
+		factory.tweakListOptions = tweakListOptions
+		return factory
+	}
+}
+
+// WithNamespace limits the SharedInformerFactory to the specified namespace.
+func WithNamespace(namespace string) SharedInformerOption {
+	return func(factory *sharedInformerFactory) *sharedInformerFactory {
+		factory.namespace = namespace
+		return factory
+	}
+}
+
+// NewSharedInformerFactory constructs a new instance of sharedInformerFactory for all namespaces.
+func NewSharedInformerFactory(client jxclient.Interface, defaultResync time.Duration) SharedInformerFactory {
+	return NewSharedInformerFactoryWithOptions(client, defaultResync)
+}
+
+// NewSharedInformerFactoryWithOptions constructs a new instance of a SharedInformerFactory with additional options.
+func NewSharedInformerFactoryWithOptions(client jxclient.Interface, defaultResync time.Duration, options ...SharedInformerOption) SharedInformerFactory {
+	factory := &sharedInformerFactory{
+		client:          client,
+		namespace:       metav1.NamespaceAll,
+		defaultResync:   defaultResync,
+		informers:       make(map[reflect.Type]cache.Informer),
+		staredInformers: make(map[reflect.Type]bool),
+	}
+
+	// Apply all options
+	for _, opt := range options {
+		factory = opt(factory)
+	}
+
+	return factory
+}
+
 func (f *sharedInformerFactory) Start(stopCh <-chan struct{}) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -49,6 +101,27 @@ func (f *sharedInformerFactory) Start(stopCh <-chan struct{}) {
 	}
 }
 
+func (f *sharedInformerFactory) WaitForCacheSync(stopCh <-chan struct{}) map[reflect.Type]bool {
+	informers := func() map[reflect.Type]cache.Informer {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+
+		informers := map[reflect.Type]cache.Informer{}
+		for informerType, informer := range f.informers {
+			if f.staredInformers[informerType] {
+				informers[informerType] = informer
+			}
+		}
+		return informers
+	}()
+
+	res := map[reflect.Type]bool{}
+	for informType, informer := range informers {
+		res[informType] = cache.WaitForCacheSync(stopCh, informer.HasSynced)
+	}
+	return res
+}
+
 func (f *sharedInformerFactory) Shutdown() {
 	f.lock.Lock()
 	f.shuttingDown = true
@@ -58,8 +131,22 @@ func (f *sharedInformerFactory) Shutdown() {
 	f.wg.Wait()
 }
 
-func (f *sharedInformerFactory) InformerFor(obj interface{}) cache.SharedInformer {
-	return nil
+// InformerFor returns the SharedIndexInformer for obj using an internal
+// client.
+func (f *sharedInformerFactory) InformerFor(obj interface{}, newFunc internalinterfaces.NewInformerFunc) cache.Informer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	informerType := reflect.TypeOf(obj)
+	informer, exists := f.informers[informerType]
+	if exists {
+		return informer
+	}
+
+	informer = newFunc(f.client, f.defaultResync)
+	f.informers[informerType] = informer
+
+	return informer
 }
 
 type SharedInformerFactory interface {
@@ -69,22 +156,27 @@ type SharedInformerFactory interface {
 	// which run until the stop channel gets closed.
 	Start(stopCh <-chan struct{})
 
+	// WaitForCacheSync blocks until all started informers' caches were synced
+	// or the stop channel gets closed.
+	WaitForCacheSync(stopCh <-chan struct{}) map[reflect.Type]bool
+
 	// Shutdown marks a factory as shutting down. At that point no new
 	// informers can be started anymore and Start will return without
 	// doing anything.
 	Shutdown()
 
-	// InformerFor returns the SharedInformer
-	InformerFor(obj interface{}) cache.SharedInformer
+	// InformerFor returns the SharedIndexInformer for obj using an internal
+	// client.
+	InformerFor(obj interface{}, newFunc internalinterfaces.NewInformerFunc) cache.Informer
 
 	Esam() esam.Interface
 	Services() services.Interface
 }
 
 func (f *sharedInformerFactory) Esam() esam.Interface {
-	return esam.New(f)
+	return esam.New(f, f.namespace, f.tweakListOptions)
 }
 
 func (f *sharedInformerFactory) Services() services.Interface {
-	return services.New(f)
-}
\ No newline at end of file
+	return services.New(f, f.namespace, f.tweakListOptions)
+}
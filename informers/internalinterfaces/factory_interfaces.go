@@ -3,15 +3,19 @@ package internalinterfaces
 import (
 	"time"
 
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
 	jxclient "github.com/ForbiddenR/jxclient-go/jxclient"
 	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
 )
 
 // NewInformerFunc takes jxclient.Interface and time.Duration to return a SharedInformer.
-type NewInformerFunc func(jxclient.Interface, time.Duration) cache.SharedInformer
+type NewInformerFunc func(jxclient.Interface, time.Duration) cache.Informer
 
 // SharedInformerFactory a small interface to allow for adding an informer without an import cycle.
 type SharedInformerFactory interface {
 	Start(stopCh <-chan struct{})
-	InformerFor(obj interface{}) cache.SharedInformer
-}
\ No newline at end of file
+	InformerFor(obj interface{}, newFunc NewInformerFunc) cache.Informer
+}
+
+// TweakListOptionsFunc is a function that transforms a metav1.ListOptions.
+type TweakListOptionsFunc func(*metav1.ListOptions)
@@ -0,0 +1,25 @@
+package v1
+
+import (
+	servicesv1 "github.com/ForbiddenR/jxclient-go/apis/services/v1"
+	serviceslisters "github.com/ForbiddenR/jxclient-go/listers/services/v1"
+	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
+)
+
+// OperatorIndex is the name of the index of SendQRCodes by spec.operator,
+// which the operator scoped lists of the lister read.
+const OperatorIndex = serviceslisters.OperatorIndex
+
+// OperatorIndexFunc indexes a SendQRCode by the operator of its station.
+func OperatorIndexFunc(obj *servicesv1.SendQRCode) ([]string, error) {
+	return []string{obj.Spec.Operator}, nil
+}
+
+// DefaultSendQRCodeIndexers returns the indexers installed on the shared
+// SendQRCode informer: namespace and operator.
+func DefaultSendQRCodeIndexers() cache.Indexers[*servicesv1.SendQRCode] {
+	return cache.Indexers[*servicesv1.SendQRCode]{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc[*servicesv1.SendQRCode],
+		OperatorIndex:        OperatorIndexFunc,
+	}
+}
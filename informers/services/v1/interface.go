@@ -7,13 +7,15 @@ type Interface interface {
 }
 
 type version struct {
-	factory internalinterfaces.SharedInformerFactory
+	factory          internalinterfaces.SharedInformerFactory
+	namespace        string
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
 }
 
-func New(f internalinterfaces.SharedInformerFactory) Interface {
-	return &version{factory: f}
+func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakListOptions internalinterfaces.TweakListOptionsFunc) Interface {
+	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
 func (v *version) SendQRCode() SendQRCodeInformer {
-	return &sendQRCodeInformer{factory: v.factory}
+	return &sendQRCodeInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
\ No newline at end of file
@@ -1,18 +1,81 @@
 package v1
 
 import (
+	"context"
+	"time"
+
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	servicesv1 "github.com/ForbiddenR/jxclient-go/apis/services/v1"
 	internalinterfaces "github.com/ForbiddenR/jxclient-go/informers/internalinterfaces"
+	jxclient "github.com/ForbiddenR/jxclient-go/jxclient"
+	serviceslisters "github.com/ForbiddenR/jxclient-go/listers/services/v1"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
 	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
 )
 
+// SendQRCodeInformer provides access to a shared informer for SendQRCodes.
 type SendQRCodeInformer interface {
-	Informer() cache.SharedInformer
+	Informer() cache.SharedIndexInformer[*servicesv1.SendQRCode]
+	Lister() serviceslisters.SendQRCodeLister
 }
 
 type sendQRCodeInformer struct {
-	factory internalinterfaces.SharedInformerFactory
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewSendQRCodeInformer constructs a new informer for SendQRCode type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewSendQRCodeInformer(client jxclient.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers[*servicesv1.SendQRCode]) cache.SharedIndexInformer[*servicesv1.SendQRCode] {
+	return NewFilteredSendQRCodeInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredSendQRCodeInformer constructs a new informer for SendQRCode type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredSendQRCodeInformer(client jxclient.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers[*servicesv1.SendQRCode], tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer[*servicesv1.SendQRCode] {
+	return cache.NewSharedIndexInformer[*servicesv1.SendQRCode](
+		&cache.ListWatch[*servicesv1.SendQRCode]{
+			ListFunc: func(options metav1.ListOptions) (*cache.ListResult[*servicesv1.SendQRCode], error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				list, err := client.ServicesV1().SendQRCodes(namespace).List(context.TODO(), options)
+				if err != nil {
+					return nil, err
+				}
+				result := &cache.ListResult[*servicesv1.SendQRCode]{
+					ListMeta: list.ListMeta,
+					Items:    make([]*servicesv1.SendQRCode, 0, len(list.Items)),
+				}
+				for i := range list.Items {
+					result.Items = append(result.Items, &list.Items[i])
+				}
+				return result, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface[*servicesv1.SendQRCode], error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.ServicesV1().SendQRCodes(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&servicesv1.SendQRCode{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (s *sendQRCodeInformer) defaultInformer(client jxclient.Interface, resyncPeriod time.Duration) cache.Informer {
+	return NewFilteredSendQRCodeInformer(client, s.namespace, resyncPeriod, DefaultSendQRCodeIndexers(), s.tweakListOptions)
+}
+
+func (s *sendQRCodeInformer) Informer() cache.SharedIndexInformer[*servicesv1.SendQRCode] {
+	return s.factory.InformerFor(&servicesv1.SendQRCode{}, s.defaultInformer).(cache.SharedIndexInformer[*servicesv1.SendQRCode])
 }
 
-func (s *sendQRCodeInformer) Informer() cache.SharedInformer {
-	return s.factory.InformerFor(struct{}{})
+func (s *sendQRCodeInformer) Lister() serviceslisters.SendQRCodeLister {
+	return serviceslisters.NewSendQRCodeLister(s.Informer().GetIndexer())
 }
@@ -0,0 +1,16 @@
+package v1
+
+import (
+	servicesv1 "github.com/ForbiddenR/jxclient-go/apis/services/v1"
+)
+
+// DropImageTransform is a transform function for SendQRCode informers which
+// drops the rendered QR code image. Consumers which only need the station and
+// the content of a SendQRCode should set it to keep the image blobs out of
+// the cache:
+//
+//	informer.SetTransform(DropImageTransform)
+func DropImageTransform(obj *servicesv1.SendQRCode) (*servicesv1.SendQRCode, error) {
+	obj.Spec.Image = nil
+	return obj, nil
+}
@@ -0,0 +1,171 @@
+// Package clocktesting provides a fake clock for the tests of this module.
+package clocktesting
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ForbiddenR/jxutils/clock"
+)
+
+var _ clock.WithTicker = &FakeClock{}
+
+// FakeClock implements clock.WithTicker, but only moves forward when Step or
+// SetTime is called.
+type FakeClock struct {
+	lock    sync.RWMutex
+	time    time.Time
+	waiters []*fakeClockWaiter
+}
+
+type fakeClockWaiter struct {
+	targetTime    time.Time
+	stepInterval  time.Duration
+	skipIfBlocked bool
+	destChan      chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to t.
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{time: t}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.time
+}
+
+func (f *FakeClock) Since(ts time.Time) time.Duration {
+	return f.Now().Sub(ts)
+}
+
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *FakeClock) NewTimer(d time.Duration) clock.Timer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w := &fakeClockWaiter{
+		targetTime: f.time.Add(d),
+		destChan:   make(chan time.Time, 1),
+	}
+	f.waiters = append(f.waiters, w)
+	return &fakeTimer{clock: f, waiter: w}
+}
+
+func (f *FakeClock) Tick(d time.Duration) <-chan time.Time {
+	return f.NewTicker(d).C()
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) clock.Ticker {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w := &fakeClockWaiter{
+		targetTime:    f.time.Add(d),
+		stepInterval:  d,
+		skipIfBlocked: true,
+		destChan:      make(chan time.Time, 1),
+	}
+	f.waiters = append(f.waiters, w)
+	return &fakeTicker{clock: f, waiter: w}
+}
+
+func (f *FakeClock) Sleep(d time.Duration) {
+	f.Step(d)
+}
+
+// Step moves the clock by d, firing every timer and ticker that became due.
+func (f *FakeClock) Step(d time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.setTimeLocked(f.time.Add(d))
+}
+
+// SetTime sets the clock to t, firing every timer and ticker that became due.
+func (f *FakeClock) SetTime(t time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.setTimeLocked(t)
+}
+
+// HasWaiters reports whether any timer or ticker is still pending.
+func (f *FakeClock) HasWaiters() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return len(f.waiters) > 0
+}
+
+func (f *FakeClock) setTimeLocked(t time.Time) {
+	f.time = t
+	newWaiters := make([]*fakeClockWaiter, 0, len(f.waiters))
+	for _, w := range f.waiters {
+		if w.targetTime.After(t) {
+			newWaiters = append(newWaiters, w)
+			continue
+		}
+		if w.skipIfBlocked {
+			select {
+			case w.destChan <- t:
+			default:
+			}
+		} else {
+			w.destChan <- t
+		}
+		if w.stepInterval > 0 {
+			for !w.targetTime.After(t) {
+				w.targetTime = w.targetTime.Add(w.stepInterval)
+			}
+			newWaiters = append(newWaiters, w)
+		}
+	}
+	f.waiters = newWaiters
+}
+
+func (f *FakeClock) removeWaiter(w *fakeClockWaiter) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i, existing := range f.waiters {
+		if existing == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	waiter *fakeClockWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.waiter.destChan
+}
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.removeWaiter(t.waiter)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.clock.removeWaiter(t.waiter)
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	t.waiter.targetTime = t.clock.time.Add(d)
+	t.clock.waiters = append(t.clock.waiters, t.waiter)
+	return active
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	waiter *fakeClockWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.destChan
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.removeWaiter(t.waiter)
+}
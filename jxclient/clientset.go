@@ -1,5 +1,11 @@
 package jxclient
 
+import (
+	esamv1 "github.com/ForbiddenR/jxclient-go/jxclient/typed/esam/v1"
+	servicesv1 "github.com/ForbiddenR/jxclient-go/jxclient/typed/services/v1"
+)
+
 type Interface interface {
-	
+	EsamV1() esamv1.EsamV1Interface
+	ServicesV1() servicesv1.ServicesV1Interface
 }
@@ -0,0 +1,25 @@
+package v1
+
+import (
+	"context"
+
+	v1 "github.com/ForbiddenR/jxclient-go/apis/esam/v1"
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
+)
+
+// EsamV1Interface has methods to work with the esam v1 resources.
+type EsamV1Interface interface {
+	AccessVerifiesGetter
+}
+
+// AccessVerifiesGetter has a method to return an AccessVerifyInterface.
+type AccessVerifiesGetter interface {
+	AccessVerifies(namespace string) AccessVerifyInterface
+}
+
+// AccessVerifyInterface has methods to work with AccessVerify resources.
+type AccessVerifyInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.AccessVerifyList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface[*v1.AccessVerify], error)
+}
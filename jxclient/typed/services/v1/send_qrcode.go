@@ -0,0 +1,25 @@
+package v1
+
+import (
+	"context"
+
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	v1 "github.com/ForbiddenR/jxclient-go/apis/services/v1"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
+)
+
+// ServicesV1Interface has methods to work with the services v1 resources.
+type ServicesV1Interface interface {
+	SendQRCodesGetter
+}
+
+// SendQRCodesGetter has a method to return a SendQRCodeInterface.
+type SendQRCodesGetter interface {
+	SendQRCodes(namespace string) SendQRCodeInterface
+}
+
+// SendQRCodeInterface has methods to work with SendQRCode resources.
+type SendQRCodeInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.SendQRCodeList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface[*v1.SendQRCode], error)
+}
@@ -0,0 +1,119 @@
+// Package v1 contains the listers of the esam v1 resources, which read from
+// the cache of a shared informer instead of the server.
+package v1
+
+import (
+	esamv1 "github.com/ForbiddenR/jxclient-go/apis/esam/v1"
+	apierrors "github.com/ForbiddenR/jxclient-go/pkg/api/errors"
+	"github.com/ForbiddenR/jxclient-go/pkg/labels"
+	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
+)
+
+// OperatorIndex is the name of the index of AccessVerifies by spec.operator,
+// which AccessVerifyLister.Operator reads from.
+const OperatorIndex = "operator"
+
+// AccessVerifyLister helps list AccessVerifies.
+// All objects returned here must be treated as read-only.
+type AccessVerifyLister interface {
+	// List lists all AccessVerifies in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*esamv1.AccessVerify, err error)
+	// AccessVerifies returns an object that can list and get AccessVerifies
+	// in a namespace.
+	AccessVerifies(namespace string) AccessVerifyNamespaceLister
+	// Operator returns an object that can list the AccessVerifies of the
+	// stations of an operator.
+	Operator(operator string) AccessVerifyOperatorLister
+}
+
+// accessVerifyLister implements the AccessVerifyLister interface.
+type accessVerifyLister struct {
+	indexer cache.Indexer[*esamv1.AccessVerify]
+}
+
+// NewAccessVerifyLister returns a new AccessVerifyLister. The operator
+// scoped lists need the OperatorIndex on indexer.
+func NewAccessVerifyLister(indexer cache.Indexer[*esamv1.AccessVerify]) AccessVerifyLister {
+	return &accessVerifyLister{indexer: indexer}
+}
+
+// List lists all AccessVerifies in the indexer.
+func (s *accessVerifyLister) List(selector labels.Selector) ([]*esamv1.AccessVerify, error) {
+	return cache.ListAll[*esamv1.AccessVerify](s.indexer, selector), nil
+}
+
+// AccessVerifies returns an object that can list and get AccessVerifies in
+// a namespace.
+func (s *accessVerifyLister) AccessVerifies(namespace string) AccessVerifyNamespaceLister {
+	return accessVerifyNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// Operator returns an object that can list the AccessVerifies of the
+// stations of an operator.
+func (s *accessVerifyLister) Operator(operator string) AccessVerifyOperatorLister {
+	return accessVerifyOperatorLister{indexer: s.indexer, operator: operator}
+}
+
+// AccessVerifyNamespaceLister helps list and get AccessVerifies in a
+// namespace.
+// All objects returned here must be treated as read-only.
+type AccessVerifyNamespaceLister interface {
+	// List lists all AccessVerifies in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*esamv1.AccessVerify, err error)
+	// Get retrieves the AccessVerify from the indexer for a given namespace
+	// and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*esamv1.AccessVerify, error)
+}
+
+// accessVerifyNamespaceLister implements the AccessVerifyNamespaceLister
+// interface.
+type accessVerifyNamespaceLister struct {
+	indexer   cache.Indexer[*esamv1.AccessVerify]
+	namespace string
+}
+
+// List lists all AccessVerifies in the indexer for a given namespace.
+func (s accessVerifyNamespaceLister) List(selector labels.Selector) ([]*esamv1.AccessVerify, error) {
+	return cache.ListAllByNamespace(s.indexer, s.namespace, selector), nil
+}
+
+// Get retrieves the AccessVerify from the indexer for a given namespace and
+// name.
+func (s accessVerifyNamespaceLister) Get(name string) (*esamv1.AccessVerify, error) {
+	key := name
+	if s.namespace != "" {
+		key = s.namespace + "/" + name
+	}
+	obj, exists, err := s.indexer.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound("accessverifies", name)
+	}
+	return obj, nil
+}
+
+// AccessVerifyOperatorLister helps list the AccessVerifies of the stations
+// of an operator.
+// All objects returned here must be treated as read-only.
+type AccessVerifyOperatorLister interface {
+	// List lists all AccessVerifies in the indexer for a given operator.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*esamv1.AccessVerify, err error)
+}
+
+// accessVerifyOperatorLister implements the AccessVerifyOperatorLister
+// interface.
+type accessVerifyOperatorLister struct {
+	indexer  cache.Indexer[*esamv1.AccessVerify]
+	operator string
+}
+
+// List lists all AccessVerifies in the indexer for a given operator.
+func (s accessVerifyOperatorLister) List(selector labels.Selector) ([]*esamv1.AccessVerify, error) {
+	return cache.ListAllByIndex(s.indexer, OperatorIndex, s.operator, selector)
+}
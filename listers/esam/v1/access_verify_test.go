@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	esamv1 "github.com/ForbiddenR/jxclient-go/apis/esam/v1"
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	apierrors "github.com/ForbiddenR/jxclient-go/pkg/api/errors"
+	"github.com/ForbiddenR/jxclient-go/pkg/labels"
+	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
+)
+
+func newAccessVerify(namespace, name, operator string) *esamv1.AccessVerify {
+	return &esamv1.AccessVerify{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       esamv1.AccessVerifySpec{Operator: operator},
+	}
+}
+
+func names(objs []*esamv1.AccessVerify) []string {
+	ret := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		ret = append(ret, obj.Namespace+"/"+obj.Name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestAccessVerifyLister(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc[*esamv1.AccessVerify], cache.Indexers[*esamv1.AccessVerify]{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc[*esamv1.AccessVerify],
+		OperatorIndex: func(obj *esamv1.AccessVerify) ([]string, error) {
+			return []string{obj.Spec.Operator}, nil
+		},
+	})
+	indexer.Add(newAccessVerify("ns1", "a", "op1"))
+	indexer.Add(newAccessVerify("ns1", "b", "op2"))
+	indexer.Add(newAccessVerify("ns2", "a", "op1"))
+	lister := NewAccessVerifyLister(indexer)
+
+	all, err := lister.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := []string{"ns1/a", "ns1/b", "ns2/a"}, names(all); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	inNamespace, err := lister.AccessVerifies("ns1").List(labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := []string{"ns1/a", "ns1/b"}, names(inNamespace); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	ofOperator, err := lister.Operator("op1").List(labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := []string{"ns1/a", "ns2/a"}, names(ofOperator); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	obj, err := lister.AccessVerifies("ns2").Get("a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "op1", obj.Spec.Operator; e != a {
+		t.Errorf("expected operator %q, got %q", e, a)
+	}
+	if _, err := lister.AccessVerifies("ns2").Get("b"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
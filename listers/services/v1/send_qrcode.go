@@ -0,0 +1,119 @@
+// Package v1 contains the listers of the services v1 resources, which read from
+// the cache of a shared informer instead of the server.
+package v1
+
+import (
+	servicesv1 "github.com/ForbiddenR/jxclient-go/apis/services/v1"
+	apierrors "github.com/ForbiddenR/jxclient-go/pkg/api/errors"
+	"github.com/ForbiddenR/jxclient-go/pkg/labels"
+	cache "github.com/ForbiddenR/jxclient-go/tools/cache"
+)
+
+// OperatorIndex is the name of the index of SendQRCodes by spec.operator,
+// which SendQRCodeLister.Operator reads from.
+const OperatorIndex = "operator"
+
+// SendQRCodeLister helps list SendQRCodes.
+// All objects returned here must be treated as read-only.
+type SendQRCodeLister interface {
+	// List lists all SendQRCodes in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*servicesv1.SendQRCode, err error)
+	// SendQRCodes returns an object that can list and get SendQRCodes
+	// in a namespace.
+	SendQRCodes(namespace string) SendQRCodeNamespaceLister
+	// Operator returns an object that can list the SendQRCodes of the
+	// stations of an operator.
+	Operator(operator string) SendQRCodeOperatorLister
+}
+
+// sendQRCodeLister implements the SendQRCodeLister interface.
+type sendQRCodeLister struct {
+	indexer cache.Indexer[*servicesv1.SendQRCode]
+}
+
+// NewSendQRCodeLister returns a new SendQRCodeLister. The operator
+// scoped lists need the OperatorIndex on indexer.
+func NewSendQRCodeLister(indexer cache.Indexer[*servicesv1.SendQRCode]) SendQRCodeLister {
+	return &sendQRCodeLister{indexer: indexer}
+}
+
+// List lists all SendQRCodes in the indexer.
+func (s *sendQRCodeLister) List(selector labels.Selector) ([]*servicesv1.SendQRCode, error) {
+	return cache.ListAll[*servicesv1.SendQRCode](s.indexer, selector), nil
+}
+
+// SendQRCodes returns an object that can list and get SendQRCodes in
+// a namespace.
+func (s *sendQRCodeLister) SendQRCodes(namespace string) SendQRCodeNamespaceLister {
+	return sendQRCodeNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// Operator returns an object that can list the SendQRCodes of the
+// stations of an operator.
+func (s *sendQRCodeLister) Operator(operator string) SendQRCodeOperatorLister {
+	return sendQRCodeOperatorLister{indexer: s.indexer, operator: operator}
+}
+
+// SendQRCodeNamespaceLister helps list and get SendQRCodes in a
+// namespace.
+// All objects returned here must be treated as read-only.
+type SendQRCodeNamespaceLister interface {
+	// List lists all SendQRCodes in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*servicesv1.SendQRCode, err error)
+	// Get retrieves the SendQRCode from the indexer for a given namespace
+	// and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*servicesv1.SendQRCode, error)
+}
+
+// sendQRCodeNamespaceLister implements the SendQRCodeNamespaceLister
+// interface.
+type sendQRCodeNamespaceLister struct {
+	indexer   cache.Indexer[*servicesv1.SendQRCode]
+	namespace string
+}
+
+// List lists all SendQRCodes in the indexer for a given namespace.
+func (s sendQRCodeNamespaceLister) List(selector labels.Selector) ([]*servicesv1.SendQRCode, error) {
+	return cache.ListAllByNamespace(s.indexer, s.namespace, selector), nil
+}
+
+// Get retrieves the SendQRCode from the indexer for a given namespace and
+// name.
+func (s sendQRCodeNamespaceLister) Get(name string) (*servicesv1.SendQRCode, error) {
+	key := name
+	if s.namespace != "" {
+		key = s.namespace + "/" + name
+	}
+	obj, exists, err := s.indexer.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound("sendqrcodes", name)
+	}
+	return obj, nil
+}
+
+// SendQRCodeOperatorLister helps list the SendQRCodes of the stations
+// of an operator.
+// All objects returned here must be treated as read-only.
+type SendQRCodeOperatorLister interface {
+	// List lists all SendQRCodes in the indexer for a given operator.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*servicesv1.SendQRCode, err error)
+}
+
+// sendQRCodeOperatorLister implements the SendQRCodeOperatorLister
+// interface.
+type sendQRCodeOperatorLister struct {
+	indexer  cache.Indexer[*servicesv1.SendQRCode]
+	operator string
+}
+
+// List lists all SendQRCodes in the indexer for a given operator.
+func (s sendQRCodeOperatorLister) List(selector labels.Selector) ([]*servicesv1.SendQRCode, error) {
+	return cache.ListAllByIndex(s.indexer, OperatorIndex, s.operator, selector)
+}
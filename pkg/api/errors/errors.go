@@ -0,0 +1,166 @@
+// Package errors provides the errors returned by the server, and helpers to
+// classify them.
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// StatusReason is a machine-readable description of why a request failed.
+type StatusReason string
+
+const (
+	// StatusReasonUnknown means the server has declined to indicate a specific reason.
+	StatusReasonUnknown StatusReason = ""
+
+	// StatusReasonUnauthorized means the server can be reached and understood the request, but requires
+	// the user to present appropriate authorization credentials in order for the action to be completed.
+	StatusReasonUnauthorized StatusReason = "Unauthorized"
+
+	// StatusReasonForbidden means the server can be reached and understood the request, but refuses
+	// to take any further action.
+	StatusReasonForbidden StatusReason = "Forbidden"
+
+	// StatusReasonNotFound means one or more resources required for this operation
+	// could not be found.
+	StatusReasonNotFound StatusReason = "NotFound"
+
+	// StatusReasonGone means the item is no longer available at the server and no
+	// forwarding address is known.
+	StatusReasonGone StatusReason = "Gone"
+
+	// StatusReasonExpired indicates that the request is invalid because the content you are requesting
+	// has expired and is no longer available. It is typically associated with watches that can't be
+	// serviced.
+	StatusReasonExpired StatusReason = "Expired"
+
+	// StatusReasonTooManyRequests means the server experienced too many requests within a
+	// given window and that the client must wait to perform the action again.
+	StatusReasonTooManyRequests StatusReason = "TooManyRequests"
+)
+
+// StatusError is an error intended for consumption by a client. It carries
+// the HTTP status code and the reason the server gave for the failure.
+type StatusError struct {
+	Code    int32
+	Reason  StatusReason
+	Message string
+}
+
+// Error implements the Error interface.
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// NewUnauthorized returns an error indicating the client is not authorized to perform the requested
+// action.
+func NewUnauthorized(reason string) *StatusError {
+	message := reason
+	if len(message) == 0 {
+		message = "not authorized"
+	}
+	return &StatusError{Code: http.StatusUnauthorized, Reason: StatusReasonUnauthorized, Message: message}
+}
+
+// NewForbidden returns an error indicating the requested action was forbidden.
+func NewForbidden(resource, name string, err error) *StatusError {
+	var message string
+	if name == "" {
+		message = fmt.Sprintf("forbidden: %v", err)
+	} else {
+		message = fmt.Sprintf("%s %q is forbidden: %v", resource, name, err)
+	}
+	return &StatusError{Code: http.StatusForbidden, Reason: StatusReasonForbidden, Message: message}
+}
+
+// NewNotFound returns a new error which indicates that the resource of the kind and the name was not found.
+func NewNotFound(resource, name string) *StatusError {
+	return &StatusError{Code: http.StatusNotFound, Reason: StatusReasonNotFound, Message: fmt.Sprintf("%s %q not found", resource, name)}
+}
+
+// NewGone returns an error indicating the item no longer available at the server and no forwarding address is known.
+func NewGone(message string) *StatusError {
+	return &StatusError{Code: http.StatusGone, Reason: StatusReasonGone, Message: message}
+}
+
+// NewResourceExpired creates an error that indicates that the requested resource content has expired from
+// the server (usually due to a resourceVersion that is too old).
+func NewResourceExpired(message string) *StatusError {
+	return &StatusError{Code: http.StatusGone, Reason: StatusReasonExpired, Message: message}
+}
+
+// NewTooManyRequests creates an error that indicates that the client must try again later because
+// the specified endpoint is not accepting requests.
+func NewTooManyRequests(message string) *StatusError {
+	return &StatusError{Code: http.StatusTooManyRequests, Reason: StatusReasonTooManyRequests, Message: message}
+}
+
+// ReasonForError returns the StatusReason for a particular error.
+func ReasonForError(err error) StatusReason {
+	if status, ok := statusError(err); ok {
+		return status.Reason
+	}
+	return StatusReasonUnknown
+}
+
+// IsUnauthorized determines if err is an error which indicates that the request is unauthorized and
+// requires authentication by the user.
+// It supports wrapped errors and returns false when the error is nil.
+func IsUnauthorized(err error) bool {
+	return reasonOrCode(err, StatusReasonUnauthorized, http.StatusUnauthorized)
+}
+
+// IsForbidden determines if err is an error which indicates that the request is forbidden and cannot
+// be completed as requested.
+// It supports wrapped errors and returns false when the error is nil.
+func IsForbidden(err error) bool {
+	return reasonOrCode(err, StatusReasonForbidden, http.StatusForbidden)
+}
+
+// IsNotFound returns true if the specified error was created by NewNotFound.
+// It supports wrapped errors and returns false when the error is nil.
+func IsNotFound(err error) bool {
+	return reasonOrCode(err, StatusReasonNotFound, http.StatusNotFound)
+}
+
+// IsGone is true if the error indicates the requested resource is no longer available.
+// It supports wrapped errors and returns false when the error is nil.
+func IsGone(err error) bool {
+	return reasonOrCode(err, StatusReasonGone, http.StatusGone)
+}
+
+// IsResourceExpired is true if the error indicates the resource has expired and the current action is
+// no longer possible.
+// It supports wrapped errors and returns false when the error is nil.
+func IsResourceExpired(err error) bool {
+	return ReasonForError(err) == StatusReasonExpired
+}
+
+// IsTooManyRequests determines if err is an error which indicates that there are too many requests
+// that the server cannot handle.
+// It supports wrapped errors and returns false when the error is nil.
+func IsTooManyRequests(err error) bool {
+	return reasonOrCode(err, StatusReasonTooManyRequests, http.StatusTooManyRequests)
+}
+
+func reasonOrCode(err error, reason StatusReason, code int32) bool {
+	status, ok := statusError(err)
+	if !ok {
+		return false
+	}
+	if status.Reason == reason {
+		return true
+	}
+	// Fall back to the code when the server gave no reason.
+	return status.Reason == StatusReasonUnknown && status.Code == code
+}
+
+func statusError(err error) (*StatusError, bool) {
+	var status *StatusError
+	if err == nil || !errors.As(err, &status) {
+		return nil, false
+	}
+	return status, true
+}
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorClassification(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		reason   StatusReason
+		expired  bool
+		gone     bool
+		notFound bool
+		unauth   bool
+		forbid   bool
+		tooMany  bool
+	}{
+		{name: "nil", err: nil},
+		{name: "plain", err: errors.New("boom")},
+		{name: "expired", err: NewResourceExpired("too old"), reason: StatusReasonExpired, expired: true},
+		{name: "gone", err: NewGone("gone"), reason: StatusReasonGone, gone: true},
+		{name: "gone code only", err: &StatusError{Code: 410}, gone: true},
+		{name: "not found", err: NewNotFound("accessverifies", "a"), reason: StatusReasonNotFound, notFound: true},
+		{name: "not found code only", err: &StatusError{Code: 404}, notFound: true},
+		{name: "unauthorized", err: NewUnauthorized(""), reason: StatusReasonUnauthorized, unauth: true},
+		{name: "forbidden", err: NewForbidden("accessverifies", "a", errors.New("denied")), reason: StatusReasonForbidden, forbid: true},
+		{name: "too many requests", err: NewTooManyRequests("slow down"), reason: StatusReasonTooManyRequests, tooMany: true},
+		{name: "wrapped", err: fmt.Errorf("watch failed: %w", NewUnauthorized("bad token")), reason: StatusReasonUnauthorized, unauth: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if e, a := tc.reason, ReasonForError(tc.err); e != a {
+				t.Errorf("expected reason %q, got %q", e, a)
+			}
+			if e, a := tc.expired, IsResourceExpired(tc.err); e != a {
+				t.Errorf("IsResourceExpired: expected %v, got %v", e, a)
+			}
+			if e, a := tc.gone, IsGone(tc.err); e != a {
+				t.Errorf("IsGone: expected %v, got %v", e, a)
+			}
+			if e, a := tc.notFound, IsNotFound(tc.err); e != a {
+				t.Errorf("IsNotFound: expected %v, got %v", e, a)
+			}
+			if e, a := tc.unauth, IsUnauthorized(tc.err); e != a {
+				t.Errorf("IsUnauthorized: expected %v, got %v", e, a)
+			}
+			if e, a := tc.forbid, IsForbidden(tc.err); e != a {
+				t.Errorf("IsForbidden: expected %v, got %v", e, a)
+			}
+			if e, a := tc.tooMany, IsTooManyRequests(tc.err); e != a {
+				t.Errorf("IsTooManyRequests: expected %v, got %v", e, a)
+			}
+		})
+	}
+}
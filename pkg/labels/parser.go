@@ -0,0 +1,333 @@
+package labels
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError is returned by Parse for a malformed selector.
+type ParseError struct {
+	// Selector is the selector which failed to parse.
+	Selector string
+	// Pos is the byte offset in Selector at which parsing failed.
+	Pos int
+	// Msg describes what was wrong at Pos.
+	Msg string
+}
+
+// Error implements the Error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("unable to parse selector %q at position %d: %s", e.Selector, e.Pos, e.Msg)
+}
+
+// Parse takes a string representing a selector and returns a selector
+// object, or a *ParseError pointing at where the input stops following
+// this form:
+//
+//	<selector-syntax>         ::= <requirement> | <requirement> "," <selector-syntax>
+//	<requirement>             ::= [!] KEY [ <set-based-restriction> | <exact-match-restriction> ]
+//	<set-based-restriction>   ::= "" | <inclusion-exclusion> <value-set>
+//	<inclusion-exclusion>     ::= <inclusion> | <exclusion>
+//	<exclusion>               ::= "notin"
+//	<inclusion>               ::= "in"
+//	<value-set>               ::= "(" <values> ")"
+//	<values>                  ::= VALUE | VALUE "," <values>
+//	<exact-match-restriction> ::= ["="|"=="|"!="] VALUE
+//
+// KEY is a sequence of one or more characters following [ DNS_SUBDOMAIN "/" ] DNS_LABEL. Max length is 63 characters.
+// VALUE is a sequence of zero or more characters "([A-Za-z0-9_-\.])". Max length is 63 characters.
+// Delimiter is white space.
+//
+// Example of valid syntax:
+//
+//	"x in (foo,,baz),y,!z,w!=qux"
+//
+// Note:
+//  1. Inclusion - " in " - denotes that the KEY exists and is equal to any of the
+//     VALUEs in its requirement
+//  2. Exclusion - " notin " - denotes that the KEY is not equal to any
+//     of the VALUEs in its requirement or does not exist
+//  3. The empty string is a valid VALUE
+//  4. A requirement with just a KEY - as in "y" above - denotes that
+//     the KEY exists and can be any VALUE.
+//  5. A requirement with just !KEY requires that the KEY not exist.
+//
+// An empty selector selects everything. The requirements of the returned
+// selector are sorted by key, so its String is canonical.
+func Parse(selector string) (Selector, error) {
+	p := &parser{l: &lexer{s: selector}}
+	reqs, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return internalSelector{}.Add(reqs...), nil
+}
+
+// tokenKind is the kind of a token of the selector syntax.
+type tokenKind int
+
+const (
+	endOfStringToken tokenKind = iota
+	identifierToken
+	openParToken
+	closedParToken
+	commaToken
+	doesNotExistToken
+	equalsToken
+	doubleEqualsToken
+	notEqualsToken
+	inToken
+	notInToken
+)
+
+// token is a token of the selector syntax, at pos in the selector.
+type token struct {
+	kind    tokenKind
+	literal string
+	pos     int
+}
+
+// describe returns how the token is quoted in errors.
+func (t token) describe() string {
+	if t.kind == endOfStringToken {
+		return "end of selector"
+	}
+	return fmt.Sprintf("%q", t.literal)
+}
+
+// isIdentifier reports whether the token can be a key or a value. The
+// keywords are only operators in operator position.
+func (t token) isIdentifier() bool {
+	return t.kind == identifierToken || t.kind == inToken || t.kind == notInToken
+}
+
+// isSpecialSymbol reports whether ch starts an operator or a delimiter, and
+// so ends an identifier.
+func isSpecialSymbol(ch byte) bool {
+	switch ch {
+	case '=', '!', '(', ')', ',':
+		return true
+	}
+	return false
+}
+
+// isWhitespace reports whether ch is ASCII whitespace. The lexer works on
+// bytes, so the bytes of multi-byte UTF-8 characters, e.g. 0xA0 in "à",
+// must not be taken for whitespace.
+func isWhitespace(ch byte) bool {
+	switch ch {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+// lexer splits a selector into tokens.
+type lexer struct {
+	s   string
+	pos int
+}
+
+// next returns the next token, skipping white space.
+func (l *lexer) next() token {
+	for l.pos < len(l.s) && isWhitespace(l.s[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if start == len(l.s) {
+		return token{kind: endOfStringToken, pos: start}
+	}
+
+	switch l.s[start] {
+	case '(':
+		l.pos++
+		return token{kind: openParToken, literal: "(", pos: start}
+	case ')':
+		l.pos++
+		return token{kind: closedParToken, literal: ")", pos: start}
+	case ',':
+		l.pos++
+		return token{kind: commaToken, literal: ",", pos: start}
+	case '=':
+		if strings.HasPrefix(l.s[start:], "==") {
+			l.pos += 2
+			return token{kind: doubleEqualsToken, literal: "==", pos: start}
+		}
+		l.pos++
+		return token{kind: equalsToken, literal: "=", pos: start}
+	case '!':
+		if strings.HasPrefix(l.s[start:], "!=") {
+			l.pos += 2
+			return token{kind: notEqualsToken, literal: "!=", pos: start}
+		}
+		l.pos++
+		return token{kind: doesNotExistToken, literal: "!", pos: start}
+	}
+
+	for l.pos < len(l.s) && !isWhitespace(l.s[l.pos]) && !isSpecialSymbol(l.s[l.pos]) {
+		l.pos++
+	}
+	literal := l.s[start:l.pos]
+	switch literal {
+	case "in":
+		return token{kind: inToken, literal: literal, pos: start}
+	case "notin":
+		return token{kind: notInToken, literal: literal, pos: start}
+	}
+	return token{kind: identifierToken, literal: literal, pos: start}
+}
+
+// parser parses the tokens of a lexer into requirements, looking one token
+// ahead.
+type parser struct {
+	l      *lexer
+	peeked *token
+}
+
+func (p *parser) peek() token {
+	if p.peeked == nil {
+		t := p.l.next()
+		p.peeked = &t
+	}
+	return *p.peeked
+}
+
+func (p *parser) consume() token {
+	t := p.peek()
+	p.peeked = nil
+	return t
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &ParseError{Selector: p.l.s, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parse() ([]Requirement, error) {
+	if p.peek().kind == endOfStringToken {
+		return nil, nil
+	}
+
+	var reqs []Requirement
+	for {
+		r, err := p.parseRequirement()
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, *r)
+
+		switch t := p.consume(); t.kind {
+		case endOfStringToken:
+			return reqs, nil
+		case commaToken:
+			if next := p.peek(); next.kind == endOfStringToken {
+				return nil, p.errorf(next.pos, "found %s, expected a requirement after ','", next.describe())
+			}
+		default:
+			return nil, p.errorf(t.pos, "found %s, expected ',' or end of selector", t.describe())
+		}
+	}
+}
+
+func (p *parser) parseRequirement() (*Requirement, error) {
+	negated := false
+	if p.peek().kind == doesNotExistToken {
+		p.consume()
+		negated = true
+	}
+
+	keyToken := p.consume()
+	if !keyToken.isIdentifier() {
+		return nil, p.errorf(keyToken.pos, "found %s, expected a key", keyToken.describe())
+	}
+	if err := validateLabelKey(keyToken.literal); err != nil {
+		return nil, p.errorf(keyToken.pos, "%v", err)
+	}
+
+	next := p.peek()
+	if next.kind == endOfStringToken || next.kind == commaToken {
+		if negated {
+			return &Requirement{key: keyToken.literal, operator: DoesNotExist}, nil
+		}
+		return &Requirement{key: keyToken.literal, operator: Exists}, nil
+	}
+	if negated {
+		return nil, p.errorf(next.pos, "found %s, expected ',' or end of selector after '!%s'", next.describe(), keyToken.literal)
+	}
+
+	opToken := p.consume()
+	var op Operator
+	switch opToken.kind {
+	case equalsToken:
+		op = Equals
+	case doubleEqualsToken:
+		op = DoubleEquals
+	case notEqualsToken:
+		op = NotEquals
+	case inToken:
+		op = In
+	case notInToken:
+		op = NotIn
+	default:
+		return nil, p.errorf(opToken.pos, "found %s, expected one of '=', '==', '!=', 'in', 'notin', ',' or end of selector", opToken.describe())
+	}
+
+	var values []string
+	var err error
+	if op == In || op == NotIn {
+		values, err = p.parseValueSet(keyToken.literal)
+	} else {
+		var value string
+		value, err = p.parseValue(keyToken.literal, endOfStringToken, commaToken)
+		values = []string{value}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewRequirement(keyToken.literal, op, values)
+}
+
+// parseValue parses an optional value, which may be empty if it is followed
+// by one of the terminators.
+func (p *parser) parseValue(key string, terminators ...tokenKind) (string, error) {
+	t := p.peek()
+	for _, kind := range terminators {
+		if t.kind == kind {
+			return "", nil
+		}
+	}
+	p.consume()
+	if !t.isIdentifier() {
+		return "", p.errorf(t.pos, "found %s, expected a value", t.describe())
+	}
+	if err := validateLabelValue(key, t.literal); err != nil {
+		return "", p.errorf(t.pos, "%v", err)
+	}
+	return t.literal, nil
+}
+
+// parseValueSet parses "(" <values> ")".
+func (p *parser) parseValueSet(key string) ([]string, error) {
+	if t := p.consume(); t.kind != openParToken {
+		return nil, p.errorf(t.pos, "found %s, expected '('", t.describe())
+	}
+	if t := p.peek(); t.kind == closedParToken {
+		return nil, p.errorf(t.pos, "found ')', expected at least one value")
+	}
+
+	var values []string
+	for {
+		value, err := p.parseValue(key, commaToken, closedParToken)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		switch t := p.consume(); t.kind {
+		case closedParToken:
+			return values, nil
+		case commaToken:
+		default:
+			return nil, p.errorf(t.pos, "found %s, expected ',' or ')'", t.describe())
+		}
+	}
+}
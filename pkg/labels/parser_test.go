@@ -0,0 +1,92 @@
+package labels
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		selector string
+		// expected is the canonical String of the parsed selector.
+		expected string
+	}{
+		{selector: "", expected: ""},
+		{selector: "   ", expected: ""},
+		{selector: "x", expected: "x"},
+		{selector: "!x", expected: "!x"},
+		{selector: "x=a", expected: "x=a"},
+		{selector: "x==a", expected: "x==a"},
+		{selector: "x!=a", expected: "x!=a"},
+		{selector: "x=", expected: "x="},
+		{selector: "x=,y", expected: "x=,y"},
+		{selector: "x in (b,a)", expected: "x in (a,b)"},
+		{selector: "x notin (a)", expected: "x notin (a)"},
+		{selector: "x in (a,,b)", expected: "x in (,a,b)"},
+		{selector: "x in (,)", expected: "x in (,)"},
+		{selector: "  z = c ,  x in ( a , b ) , ! y ", expected: "x in (a,b),!y,z=c"},
+		{selector: "example.com/app=web,tier!=db", expected: "example.com/app=web,tier!=db"},
+		{selector: "in=notin", expected: "in=notin"},
+		{selector: "x in (in,notin)", expected: "x in (in,notin)"},
+		{selector: "x=a,x=b", expected: "x=a,x=b"},
+	} {
+		s, err := Parse(tc.selector)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.selector, err)
+			continue
+		}
+		if a := s.String(); tc.expected != a {
+			t.Errorf("%q: expected %q, got %q", tc.selector, tc.expected, a)
+		}
+		// The string of a selector parses back into the same selector.
+		roundTrip, err := Parse(s.String())
+		if err != nil {
+			t.Errorf("%q: unexpected error parsing %q: %v", tc.selector, s.String(), err)
+			continue
+		}
+		if e, a := s.String(), roundTrip.String(); e != a {
+			t.Errorf("%q: expected %q to round trip, got %q", tc.selector, e, a)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		selector string
+		pos      int
+	}{
+		{selector: ",", pos: 0},
+		{selector: "x,", pos: 2},
+		{selector: "x,,y", pos: 2},
+		{selector: "x y", pos: 2},
+		{selector: "x=a b", pos: 4},
+		{selector: "x=(a)", pos: 2},
+		{selector: "!", pos: 1},
+		{selector: "!x=a", pos: 2},
+		{selector: "x in a", pos: 5},
+		{selector: "x in ()", pos: 6},
+		{selector: "x in (a", pos: 7},
+		{selector: "x in (a b)", pos: 8},
+		{selector: "x notin (a))", pos: 11},
+		{selector: "=a", pos: 0},
+		{selector: "x=a,-bad=c", pos: 4},
+		{selector: "x=a,Example.com/y=c", pos: 4},
+		{selector: "x=-bad", pos: 2},
+		{selector: "x in (a,b-)", pos: 8},
+		{selector: "xà=1", pos: 0},
+		{selector: "x=1à", pos: 2},
+		{selector: "x=1\u0085", pos: 2},
+		{selector: "x=1\xa0", pos: 2},
+		{selector: "x\x85=\x85a", pos: 0},
+	} {
+		_, err := Parse(tc.selector)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%q: expected a parse error, got %v", tc.selector, err)
+			continue
+		}
+		if e, a := tc.pos, parseErr.Pos; e != a {
+			t.Errorf("%q: expected the error at position %d, got %d: %v", tc.selector, e, a, err)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Selector represents a label selector.
+type Selector interface {
+	// Matches returns true if this selector matches the given set of labels.
+	Matches(Labels) bool
+
+	// Empty returns true if this selector does not restrict the selection space.
+	Empty() bool
+
+	// String returns a human readable string that represents this selector.
+	// Parse accepts it and returns an equivalent selector.
+	String() string
+
+	// Add adds requirements to the selector. It copies the current selector
+	// returning a new one.
+	Add(r ...Requirement) Selector
+
+	// Requirements returns the requirements of this selector, sorted by key.
+	Requirements() []Requirement
+}
+
+// Everything returns a selector that matches all labels.
+func Everything() Selector {
+	return internalSelector{}
+}
+
+// Operator is the relation between the key of a Requirement and its values.
+type Operator string
+
+const (
+	DoesNotExist Operator = "!"
+	Equals       Operator = "="
+	DoubleEquals Operator = "=="
+	In           Operator = "in"
+	NotEquals    Operator = "!="
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+)
+
+// Requirement contains a key, an operator and values, and is the unit a
+// selector is made of. A selector matches the labels which meet all of its
+// requirements.
+type Requirement struct {
+	key      string
+	operator Operator
+	// strValues is sorted, so that String is deterministic.
+	strValues []string
+}
+
+// NewRequirement is the constructor for a Requirement.
+// If any of these rules is violated, an error is returned:
+//  1. The operator can only be In, NotIn, Equals, DoubleEquals, NotEquals,
+//     Exists, or DoesNotExist.
+//  2. If the operator is In or NotIn, the values set must be non-empty.
+//  3. If the operator is Equals, DoubleEquals, or NotEquals, the values set
+//     must contain one value.
+//  4. If the operator is Exists or DoesNotExist, the value set must be empty.
+//  5. The key and the values must be valid label keys and values.
+func NewRequirement(key string, op Operator, vals []string) (*Requirement, error) {
+	if err := validateLabelKey(key); err != nil {
+		return nil, err
+	}
+	switch op {
+	case In, NotIn:
+		if len(vals) == 0 {
+			return nil, fmt.Errorf("for 'in', 'notin' operators, values set can't be empty")
+		}
+	case Equals, DoubleEquals, NotEquals:
+		if len(vals) != 1 {
+			return nil, fmt.Errorf("exact-match compatibility requires one single value")
+		}
+	case Exists, DoesNotExist:
+		if len(vals) != 0 {
+			return nil, fmt.Errorf("values set must be empty for exists and does not exist")
+		}
+	default:
+		return nil, fmt.Errorf("operator %q is not recognized", op)
+	}
+	for _, val := range vals {
+		if err := validateLabelValue(key, val); err != nil {
+			return nil, err
+		}
+	}
+	strValues := append([]string(nil), vals...)
+	sort.Strings(strValues)
+	return &Requirement{key: key, operator: op, strValues: strValues}, nil
+}
+
+// Key returns the key of the requirement.
+func (r *Requirement) Key() string {
+	return r.key
+}
+
+// Operator returns the operator of the requirement.
+func (r *Requirement) Operator() Operator {
+	return r.operator
+}
+
+// Values returns the values of the requirement, sorted.
+func (r *Requirement) Values() []string {
+	return append([]string(nil), r.strValues...)
+}
+
+// Matches returns true if the Requirement matches the input Labels:
+//   - In, Equals and DoubleEquals require the label to be set to one of
+//     the values.
+//   - NotIn and NotEquals require the label to be unset, or set to none of
+//     the values.
+//   - Exists requires the label to be set, DoesNotExist requires it unset.
+func (r *Requirement) Matches(ls Labels) bool {
+	switch r.operator {
+	case In, Equals, DoubleEquals:
+		return ls.Has(r.key) && r.hasValue(ls.Get(r.key))
+	case NotIn, NotEquals:
+		return !ls.Has(r.key) || !r.hasValue(ls.Get(r.key))
+	case Exists:
+		return ls.Has(r.key)
+	case DoesNotExist:
+		return !ls.Has(r.key)
+	default:
+		return false
+	}
+}
+
+func (r *Requirement) hasValue(value string) bool {
+	for _, v := range r.strValues {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a human-readable string that represents this Requirement,
+// in the syntax Parse accepts.
+func (r *Requirement) String() string {
+	var sb strings.Builder
+	if r.operator == DoesNotExist {
+		sb.WriteString("!")
+	}
+	sb.WriteString(r.key)
+
+	switch r.operator {
+	case Equals, DoubleEquals, NotEquals:
+		sb.WriteString(string(r.operator))
+	case In, NotIn:
+		sb.WriteString(" " + string(r.operator) + " ")
+	case Exists, DoesNotExist:
+		return sb.String()
+	}
+
+	if r.operator == In || r.operator == NotIn {
+		sb.WriteString("(")
+	}
+	sb.WriteString(strings.Join(r.strValues, ","))
+	if r.operator == In || r.operator == NotIn {
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
+
+// internalSelector is a Selector made of requirements, sorted by key.
+type internalSelector []Requirement
+
+// Matches returns true if all of the Requirements match the provided Labels.
+func (s internalSelector) Matches(ls Labels) bool {
+	for i := range s {
+		if !s[i].Matches(ls) {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty returns true if the selector has no requirements.
+func (s internalSelector) Empty() bool {
+	return len(s) == 0
+}
+
+// String returns a comma-separated string of all the requirements'
+// human-readable strings.
+func (s internalSelector) String() string {
+	reqs := make([]string, 0, len(s))
+	for i := range s {
+		reqs = append(reqs, s[i].String())
+	}
+	return strings.Join(reqs, ",")
+}
+
+// Add adds requirements to the selector, keeping them sorted by key.
+func (s internalSelector) Add(reqs ...Requirement) Selector {
+	ret := make(internalSelector, 0, len(s)+len(reqs))
+	ret = append(ret, s...)
+	ret = append(ret, reqs...)
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].key < ret[j].key })
+	return ret
+}
+
+// Requirements returns a copy of the requirements of the selector.
+func (s internalSelector) Requirements() []Requirement {
+	return append([]Requirement(nil), s...)
+}
+
+// SelectorFromSet returns a Selector which will match exactly the given Set.
+// A nil or empty Set is considered equivalent to Everything(). The set is
+// not validated, see ValidatedSelectorFromSet.
+func SelectorFromSet(ls Set) Selector {
+	if len(ls) == 0 {
+		return internalSelector{}
+	}
+	reqs := make([]Requirement, 0, len(ls))
+	for key, value := range ls {
+		reqs = append(reqs, Requirement{key: key, operator: Equals, strValues: []string{value}})
+	}
+	return internalSelector{}.Add(reqs...)
+}
+
+// ValidatedSelectorFromSet returns a Selector which will match exactly the
+// given Set, or an error if the set has an invalid label key or value.
+func ValidatedSelectorFromSet(ls Set) (Selector, error) {
+	if len(ls) == 0 {
+		return internalSelector{}, nil
+	}
+	reqs := make([]Requirement, 0, len(ls))
+	for key, value := range ls {
+		r, err := NewRequirement(key, Equals, []string{value})
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, *r)
+	}
+	return internalSelector{}.Add(reqs...), nil
+}
+
+const (
+	// nameMaxLength is the longest label name or value.
+	nameMaxLength = 63
+	// prefixMaxLength is the longest label key prefix, a DNS subdomain.
+	prefixMaxLength = 253
+)
+
+var (
+	nameRegexp   = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+	prefixRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+// validateLabelKey checks that key is an optional DNS subdomain prefix and
+// a slash, followed by a name of at most 63 alphanumeric characters, '-',
+// '_' or '.', starting and ending with an alphanumeric character.
+func validateLabelKey(key string) error {
+	name := key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+		if len(prefix) == 0 || len(prefix) > prefixMaxLength || !prefixRegexp.MatchString(prefix) {
+			return fmt.Errorf("invalid label key %q: prefix must be a lowercase DNS subdomain", key)
+		}
+	}
+	if len(name) == 0 || len(name) > nameMaxLength || !nameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid label key %q: name must be at most %d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric character", key, nameMaxLength)
+	}
+	return nil
+}
+
+// validateLabelValue checks that value is empty, or a name like the name
+// part of a key.
+func validateLabelValue(key, value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > nameMaxLength || !nameRegexp.MatchString(value) {
+		return fmt.Errorf("invalid label value %q for key %q: must be at most %d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric character", value, key, nameMaxLength)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package labels
+
+import "testing"
+
+func TestSetString(t *testing.T) {
+	for _, tc := range []struct {
+		set      Set
+		expected string
+	}{
+		{set: nil, expected: ""},
+		{set: Set{"x": "y"}, expected: "x=y"},
+		{set: Set{"foo": "bar", "baz": "qux"}, expected: "baz=qux,foo=bar"},
+		{set: Set{"empty": ""}, expected: "empty="},
+	} {
+		if a := tc.set.String(); tc.expected != a {
+			t.Errorf("expected %q for %#v, got %q", tc.expected, tc.set, a)
+		}
+	}
+}
+
+func TestSelectorFromSet(t *testing.T) {
+	labels := Set{"operator": "jx", "station": "s1"}
+	for _, tc := range []struct {
+		selector Set
+		matches  bool
+	}{
+		{selector: nil, matches: true},
+		{selector: Set{"operator": "jx"}, matches: true},
+		{selector: Set{"operator": "jx", "station": "s1"}, matches: true},
+		{selector: Set{"operator": "other"}, matches: false},
+		{selector: Set{"missing": ""}, matches: false},
+		{selector: Set{"operator": "jx", "missing": "x"}, matches: false},
+	} {
+		if a := tc.selector.AsSelector().Matches(labels); tc.matches != a {
+			t.Errorf("expected %v matching %v against %v, got %v", tc.matches, tc.selector, labels, a)
+		}
+	}
+	if !Everything().Empty() || !Everything().Matches(Set{}) {
+		t.Error("expected Everything to be empty and to match everything")
+	}
+	if SelectorFromSet(Set{"a": "b"}).Empty() {
+		t.Error("expected a selector with labels not to be empty")
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := Set{"operator": "jx", "station": "s1", "empty": ""}
+	for _, tc := range []struct {
+		selector string
+		matches  bool
+	}{
+		{selector: "", matches: true},
+		{selector: "operator=jx", matches: true},
+		{selector: "operator==jx", matches: true},
+		{selector: "operator=other", matches: false},
+		{selector: "operator!=other", matches: true},
+		{selector: "operator!=jx", matches: false},
+		{selector: "missing!=x", matches: true},
+		{selector: "empty=", matches: true},
+		{selector: "missing=", matches: false},
+		{selector: "station in (s1,s2)", matches: true},
+		{selector: "station in (s2,s3)", matches: false},
+		{selector: "missing in (s1)", matches: false},
+		{selector: "station notin (s2,s3)", matches: true},
+		{selector: "station notin (s1)", matches: false},
+		{selector: "missing notin (s1)", matches: true},
+		{selector: "station", matches: true},
+		{selector: "missing", matches: false},
+		{selector: "!missing", matches: true},
+		{selector: "!station", matches: false},
+		{selector: "operator=jx,station in (s1),!missing", matches: true},
+		{selector: "operator=jx,station in (s2)", matches: false},
+	} {
+		s, err := Parse(tc.selector)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.selector, err)
+			continue
+		}
+		if a := s.Matches(labels); tc.matches != a {
+			t.Errorf("expected %v matching %q against %v, got %v", tc.matches, tc.selector, labels, a)
+		}
+	}
+}
+
+func TestNewRequirement(t *testing.T) {
+	for _, tc := range []struct {
+		key    string
+		op     Operator
+		values []string
+		valid  bool
+	}{
+		{key: "x", op: Equals, values: []string{"a"}, valid: true},
+		{key: "x", op: Equals, values: []string{"a", "b"}},
+		{key: "x", op: Equals},
+		{key: "x", op: In, values: []string{"a", "b"}, valid: true},
+		{key: "x", op: NotIn},
+		{key: "x", op: Exists, valid: true},
+		{key: "x", op: DoesNotExist, values: []string{"a"}},
+		{key: "x", op: Operator("~"), values: []string{"a"}},
+		{key: "", op: Exists},
+		{key: "example.com/x", op: Exists, valid: true},
+		{key: "/x", op: Exists},
+		{key: "x", op: Equals, values: []string{"not valid"}},
+	} {
+		_, err := NewRequirement(tc.key, tc.op, tc.values)
+		if tc.valid && err != nil {
+			t.Errorf("%q %s %v: unexpected error: %v", tc.key, tc.op, tc.values, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%q %s %v: expected an error", tc.key, tc.op, tc.values)
+		}
+	}
+}
+
+func TestSelectorAdd(t *testing.T) {
+	r1, err := NewRequirement("b", In, []string{"y", "x"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r2, err := NewRequirement("a", DoesNotExist, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := Everything()
+	added := s.Add(*r1, *r2)
+	if !s.Empty() {
+		t.Error("expected Add not to modify the selector")
+	}
+	if e, a := "!a,b in (x,y)", added.String(); e != a {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+	if e, a := 2, len(added.Requirements()); e != a {
+		t.Errorf("expected %d requirements, got %d", e, a)
+	}
+	if _, err := ValidatedSelectorFromSet(Set{"bad key": "x"}); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+	if e, a := "a=1,b=2", SelectorFromSet(Set{"b": "2", "a": "1"}).String(); e != a {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+}
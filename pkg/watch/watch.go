@@ -0,0 +1,116 @@
+// Package watch defines the stream of changes returned by Watch calls.
+package watch
+
+import "sync"
+
+// Interface can be implemented by anything that knows how to watch and
+// report changes.
+type Interface[T any] interface {
+	// Stop stops watching. Will close the channel returned by ResultChan().
+	// Releases any resources used by the watch.
+	Stop()
+
+	// ResultChan returns a chan which will receive all the events. If an
+	// error occurs or Stop() is called, the implementation will close this
+	// channel and release any resources used by the watch.
+	ResultChan() <-chan Event[T]
+}
+
+// EventType defines the possible types of events.
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+	Bookmark EventType = "BOOKMARK"
+	Error    EventType = "ERROR"
+)
+
+// Event represents a single event to a watched resource.
+type Event[T any] struct {
+	Type EventType
+
+	// Object is:
+	//  * If Type is Added or Modified: the new state of the object.
+	//  * If Type is Deleted: the state of the object immediately before deletion.
+	//  * If Type is Bookmark: the object (instance of a type being watched) where
+	//    only ResourceVersion field is set. On successful restart of watch from a
+	//    bookmark resourceVersion, client is guaranteed to not get repeat event
+	//    nor miss any events.
+	//  * If Type is Error: the zero value.
+	Object T
+
+	// Err is the reason of an Error event.
+	Err error
+}
+
+// FakeWatcher lets you test anything that consumes a watch.Interface;
+// threadsafe.
+type FakeWatcher[T any] struct {
+	result  chan Event[T]
+	stopped bool
+	sync.Mutex
+}
+
+// NewFake constructs a FakeWatcher whose events must be received before the
+// next one can be sent.
+func NewFake[T any]() *FakeWatcher[T] {
+	return &FakeWatcher[T]{
+		result: make(chan Event[T]),
+	}
+}
+
+// NewFakeWithChanSize constructs a FakeWatcher buffering size events.
+func NewFakeWithChanSize[T any](size int) *FakeWatcher[T] {
+	return &FakeWatcher[T]{
+		result: make(chan Event[T], size),
+	}
+}
+
+// Stop implements Interface.Stop().
+func (f *FakeWatcher[T]) Stop() {
+	f.Lock()
+	defer f.Unlock()
+	if !f.stopped {
+		close(f.result)
+		f.stopped = true
+	}
+}
+
+// IsStopped reports whether Stop was called.
+func (f *FakeWatcher[T]) IsStopped() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.stopped
+}
+
+// ResultChan implements Interface.ResultChan().
+func (f *FakeWatcher[T]) ResultChan() <-chan Event[T] {
+	return f.result
+}
+
+// Add sends an add event.
+func (f *FakeWatcher[T]) Add(obj T) {
+	f.result <- Event[T]{Type: Added, Object: obj}
+}
+
+// Modify sends a modify event.
+func (f *FakeWatcher[T]) Modify(obj T) {
+	f.result <- Event[T]{Type: Modified, Object: obj}
+}
+
+// Delete sends a delete event.
+func (f *FakeWatcher[T]) Delete(lastValue T) {
+	f.result <- Event[T]{Type: Deleted, Object: lastValue}
+}
+
+// Action sends an event of the requested type, for table-based testing.
+func (f *FakeWatcher[T]) Action(action EventType, obj T) {
+	f.result <- Event[T]{Type: action, Object: obj}
+}
+
+// Error sends an Error event.
+func (f *FakeWatcher[T]) Error(err error) {
+	f.result <- Event[T]{Type: Error, Err: err}
+}
@@ -1,5 +1,157 @@
 package cache
 
-type ResourceEventHandler interface {
-	
-}
\ No newline at end of file
+// ResourceEventHandler can handle notifications for events that
+// happen to a resource. The events are informational only, so you
+// can't return an error. The handlers MUST NOT modify the objects
+// received; this concerns not only the top level of structure but all
+// data structures reachable from it.
+//   - OnAdd is called when an object is added.
+//   - OnUpdate is called when an object is modified. Note that oldObj is the
+//     last known state of the object-- it is possible that several changes
+//     were combined together, so you can't use this to see every single
+//     change. OnUpdate is also called when a re-list happens, and it will
+//     get called even if nothing changed. This is useful for periodically
+//     evaluating or syncing something.
+//   - OnDelete will get the final state of the item if it is known, and a
+//     nil tombstone. If the deletion was missed, e.g. because the watch was
+//     closed, obj is the last state the informer saw, which may be stale,
+//     and tombstone holds it along with its key.
+//
+// The isInInitialList parameter of OnAdd is true if the object is part of
+// the initial list of the informer, i.e. was not added by a watch event.
+type ResourceEventHandler[T any] interface {
+	OnAdd(obj T, isInInitialList bool)
+	OnUpdate(oldObj, newObj T)
+	OnDelete(obj T, tombstone *DeletedFinalStateUnknown[T])
+}
+
+// TransformFunc allows for transforming an object before it will be processed.
+//
+// The most common usage pattern is to clean-up some parts of the object to
+// reduce component memory usage if a given component doesn't care about them.
+//
+// TransformFunc sees the object before any other actor, so it is safe to
+// mutate the object in place instead of making a copy. It is called while
+// inserting objects into the notification queue and must not block.
+type TransformFunc[T any] func(obj T) (T, error)
+
+// DeletedFinalStateUnknown is passed to OnDelete when an object was deleted
+// but the watch deletion event was missed while disconnected from the
+// server. In this case we don't know the final "resting" state of the
+// object, so there's a chance the included `Obj` is stale.
+type DeletedFinalStateUnknown[T any] struct {
+	Key string
+	Obj T
+}
+
+// ResourceEventHandlerFuncs is an adaptor to let you easily specify as many or
+// as few of the notification functions as you want while still implementing
+// ResourceEventHandler. This adapter does not remove the prohibition against
+// modifying the objects.
+//
+// See ResourceEventHandlerDetailedFuncs if your use needs to propagate
+// HasSynced.
+type ResourceEventHandlerFuncs[T any] struct {
+	AddFunc    func(obj T)
+	UpdateFunc func(oldObj, newObj T)
+	DeleteFunc func(obj T, tombstone *DeletedFinalStateUnknown[T])
+}
+
+var _ ResourceEventHandler[any] = ResourceEventHandlerFuncs[any]{}
+
+// OnAdd calls AddFunc if it's not nil.
+func (r ResourceEventHandlerFuncs[T]) OnAdd(obj T, isInInitialList bool) {
+	if r.AddFunc != nil {
+		r.AddFunc(obj)
+	}
+}
+
+// OnUpdate calls UpdateFunc if it's not nil.
+func (r ResourceEventHandlerFuncs[T]) OnUpdate(oldObj, newObj T) {
+	if r.UpdateFunc != nil {
+		r.UpdateFunc(oldObj, newObj)
+	}
+}
+
+// OnDelete calls DeleteFunc if it's not nil.
+func (r ResourceEventHandlerFuncs[T]) OnDelete(obj T, tombstone *DeletedFinalStateUnknown[T]) {
+	if r.DeleteFunc != nil {
+		r.DeleteFunc(obj, tombstone)
+	}
+}
+
+// ResourceEventHandlerDetailedFuncs is exactly like ResourceEventHandlerFuncs
+// except its AddFunc accepts the isInInitialList parameter, for propagating
+// HasSynced.
+type ResourceEventHandlerDetailedFuncs[T any] struct {
+	AddFunc    func(obj T, isInInitialList bool)
+	UpdateFunc func(oldObj, newObj T)
+	DeleteFunc func(obj T, tombstone *DeletedFinalStateUnknown[T])
+}
+
+var _ ResourceEventHandler[any] = ResourceEventHandlerDetailedFuncs[any]{}
+
+// OnAdd calls AddFunc if it's not nil.
+func (r ResourceEventHandlerDetailedFuncs[T]) OnAdd(obj T, isInInitialList bool) {
+	if r.AddFunc != nil {
+		r.AddFunc(obj, isInInitialList)
+	}
+}
+
+// OnUpdate calls UpdateFunc if it's not nil.
+func (r ResourceEventHandlerDetailedFuncs[T]) OnUpdate(oldObj, newObj T) {
+	if r.UpdateFunc != nil {
+		r.UpdateFunc(oldObj, newObj)
+	}
+}
+
+// OnDelete calls DeleteFunc if it's not nil.
+func (r ResourceEventHandlerDetailedFuncs[T]) OnDelete(obj T, tombstone *DeletedFinalStateUnknown[T]) {
+	if r.DeleteFunc != nil {
+		r.DeleteFunc(obj, tombstone)
+	}
+}
+
+// FilteringResourceEventHandler applies the provided filter to all events coming
+// in, ensuring the appropriate nested handler method is invoked. An object
+// that starts passing the filter after an update is considered an add, and an
+// object that stops passing the filter after an update is considered a delete.
+// Like the handlers, the filter MUST NOT modify the objects it is given.
+type FilteringResourceEventHandler[T any] struct {
+	FilterFunc func(obj T) bool
+	Handler    ResourceEventHandler[T]
+}
+
+var _ ResourceEventHandler[any] = FilteringResourceEventHandler[any]{}
+
+// OnAdd calls the nested handler only if the filter succeeds
+func (r FilteringResourceEventHandler[T]) OnAdd(obj T, isInInitialList bool) {
+	if !r.FilterFunc(obj) {
+		return
+	}
+	r.Handler.OnAdd(obj, isInInitialList)
+}
+
+// OnUpdate ensures the proper handler is called depending on whether the filter matches
+func (r FilteringResourceEventHandler[T]) OnUpdate(oldObj, newObj T) {
+	newer := r.FilterFunc(newObj)
+	older := r.FilterFunc(oldObj)
+	switch {
+	case newer && older:
+		r.Handler.OnUpdate(oldObj, newObj)
+	case newer && !older:
+		r.Handler.OnAdd(newObj, false)
+	case !newer && older:
+		r.Handler.OnDelete(oldObj, nil)
+	default:
+		// do nothing
+	}
+}
+
+// OnDelete calls the nested handler only if the filter succeeds
+func (r FilteringResourceEventHandler[T]) OnDelete(obj T, tombstone *DeletedFinalStateUnknown[T]) {
+	if !r.FilterFunc(obj) {
+		return
+	}
+	r.Handler.OnDelete(obj, tombstone)
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+type recordingHandler struct {
+	events []string
+}
+
+func (r *recordingHandler) OnAdd(obj string, isInInitialList bool) {
+	r.events = append(r.events, "add "+obj)
+}
+
+func (r *recordingHandler) OnUpdate(oldObj, newObj string) {
+	r.events = append(r.events, "update "+oldObj+"->"+newObj)
+}
+
+func (r *recordingHandler) OnDelete(obj string, tombstone *DeletedFinalStateUnknown[string]) {
+	if tombstone != nil {
+		r.events = append(r.events, "tombstone "+tombstone.Key)
+		return
+	}
+	r.events = append(r.events, "delete "+obj)
+}
+
+func TestFilteringResourceEventHandler(t *testing.T) {
+	recorder := &recordingHandler{}
+	handler := FilteringResourceEventHandler[string]{
+		FilterFunc: func(obj string) bool { return obj[0] == 'a' },
+		Handler:    recorder,
+	}
+
+	handler.OnAdd("a1", true)
+	handler.OnAdd("b1", false)
+	handler.OnUpdate("a1", "a2")
+	handler.OnUpdate("a2", "b2")
+	handler.OnUpdate("b2", "a3")
+	handler.OnUpdate("b3", "b4")
+	handler.OnDelete("a3", &DeletedFinalStateUnknown[string]{Key: "key-a3", Obj: "a3"})
+	handler.OnDelete("b4", nil)
+
+	expected := []string{"add a1", "update a1->a2", "delete a2", "add a3", "tombstone key-a3"}
+	if !reflect.DeepEqual(expected, recorder.events) {
+		t.Errorf("Expected %v, got %v", expected, recorder.events)
+	}
+}
+
+func TestResourceEventHandlerFuncs(t *testing.T) {
+	var got []string
+	var handler ResourceEventHandler[int] = ResourceEventHandlerDetailedFuncs[int]{
+		AddFunc: func(obj int, isInInitialList bool) {
+			if isInInitialList {
+				got = append(got, "initial")
+			}
+		},
+	}
+	handler.OnAdd(1, true)
+	// Unset funcs are skipped.
+	handler.OnUpdate(1, 2)
+	handler.OnDelete(2, nil)
+
+	handler = ResourceEventHandlerFuncs[int]{
+		DeleteFunc: func(obj int, tombstone *DeletedFinalStateUnknown[int]) {
+			if tombstone != nil {
+				got = append(got, "tombstone "+tombstone.Key)
+			}
+		},
+	}
+	handler.OnAdd(1, true)
+	handler.OnDelete(1, &DeletedFinalStateUnknown[int]{Key: "one", Obj: 1})
+
+	if expected := []string{"initial", "tombstone one"}; !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,677 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+)
+
+// DeltaFIFOOptions is the configuration parameters for DeltaFIFO. All are
+// optional.
+type DeltaFIFOOptions[T any] struct {
+
+	// KeyFunction is used to figure out what key an object should have. (It's
+	// exposed in the returned DeltaFIFO's KeyOf() method, with additional
+	// handling around deleted objects and queue state).
+	// Optional, the default is MetaNamespaceKeyFunc for objects which
+	// implement metav1.Object.
+	KeyFunction KeyFunc[T]
+
+	// KnownObjects is expected to return a list of keys that the consumer of
+	// this queue "knows about". It is used to decide which items are missing
+	// when Replace() is called; 'Deleted' deltas are produced for the missing items.
+	// KnownObjects may be nil if you can tolerate missing deletions on Replace().
+	KnownObjects KeyListerGetter[T]
+
+	// If set, will be called for objects before enqueueing them. Please
+	// see the comment on TransformFunc for details.
+	Transformer TransformFunc[T]
+}
+
+// DeltaFIFO is like FIFO, but differs in two ways. One is that the
+// accumulator associated with a given object's key is not that object
+// but rather a Deltas, which is a slice of Delta values for that
+// object. Applying an object to a Deltas means to append a Delta
+// except when the potentially appended Delta is a Deleted and the
+// Deltas already ends with a Deleted. In that case the Deltas does
+// not grow, although the terminal Deleted will be replaced by the new
+// Deleted if the older Deleted's object is a tombstone.
+//
+// The other difference is that DeltaFIFO has two additional ways that
+// an object can be applied to an accumulator: Replaced and Sync.
+// If Replace() is called, every object in the new list gets a Replaced
+// delta, and every object the queue or its KnownObjects knew about but
+// which is not in the new list gets a Deleted delta whose
+// FinalStateUnknown is set. Sync is applied by Resync() to the objects
+// of KnownObjects which have no deltas queued.
+//
+// DeltaFIFO is a producer-consumer queue, where a Reflector is
+// intended to be the producer, and the consumer is whatever calls
+// the Pop() method.
+//
+// DeltaFIFO solves this use case:
+//   - You want to process every object change (delta) at most once.
+//   - When you process an object, you want to see everything
+//     that's happened to it since you last processed it.
+//   - You want to process the deletion of some of the objects.
+//   - You might want to periodically reprocess objects.
+//
+// DeltaFIFO's Pop, Get, and GetByKey methods return the Deltas of an
+// object; List returns the newest object from each accumulator in
+// the FIFO.
+//
+// A DeltaFIFO's knownObjects provides the abilities to list Store keys
+// and to get objects by Store key. The objects in question are called
+// "known objects" and this set of objects modifies the behavior of the
+// Delete, Replace, and Resync methods (each in a different way).
+//
+// A note on threading: If you call Pop() in parallel from multiple
+// threads, you could end up with multiple threads processing slightly
+// different versions of the same object.
+type DeltaFIFO[T any] struct {
+	// lock/cond protects access to 'items' and 'queue'.
+	lock sync.RWMutex
+	cond sync.Cond
+
+	// `items` maps a key to a Deltas.
+	// Each such Deltas has at least one Delta.
+	items map[string]Deltas[T]
+
+	// `queue` maintains FIFO order of keys for consumption in Pop().
+	// There are no duplicates in `queue`.
+	// A key is in `queue` if and only if it is in `items`.
+	queue []string
+
+	// populated is true if the first batch of items inserted by Replace() has been populated
+	// or Delete/Add/Update was called first.
+	populated bool
+	// initialPopulationCount is the number of items inserted by the first call of Replace()
+	initialPopulationCount int
+	// synced mirrors hasSyncedLocked so that HasSynced does not need the
+	// lock, which Pop holds while the process function runs.
+	synced atomic.Bool
+
+	// keyFunc is used to make the key used for queued item
+	// insertion and retrieval, and should be deterministic.
+	keyFunc KeyFunc[T]
+
+	// knownObjects list keys that are "known" --- affecting Delete(),
+	// Replace(), and Resync()
+	knownObjects KeyListerGetter[T]
+
+	// Called with every object if non-nil.
+	transformer TransformFunc[T]
+
+	// Used to indicate a queue is closed so a control loop can exit when a queue is empty.
+	// Currently, not used to gate any of CRUD operations.
+	closed bool
+}
+
+// DeltaType is the type of a change (addition, deletion, etc)
+type DeltaType string
+
+// Change type definition
+const (
+	Added   DeltaType = "Added"
+	Updated DeltaType = "Updated"
+	Deleted DeltaType = "Deleted"
+	// Replaced is emitted when we encountered watch errors and had to do a
+	// relist. We don't know if the replaced object has changed.
+	Replaced DeltaType = "Replaced"
+	// Sync is for synthetic events during a periodic resync.
+	Sync DeltaType = "Sync"
+)
+
+// Delta is a member of Deltas (a list of Delta objects) which
+// in its turn is the type stored by a DeltaFIFO. It tells you what
+// change happened, and the object's state after* that change.
+type Delta[T any] struct {
+	Type   DeltaType
+	Object T
+
+	// FinalStateUnknown is set on a Deleted delta when the deletion was
+	// inferred by Replace rather than observed, so Object is the last state
+	// the queue or its KnownObjects had, which may be stale. Informers hand
+	// it to their handlers as a DeletedFinalStateUnknown tombstone.
+	FinalStateUnknown bool
+}
+
+// Deltas is a list of one or more 'Delta's to an individual object.
+// The oldest delta is at index 0, the newest delta is the last one.
+type Deltas[T any] []Delta[T]
+
+// KeyListerGetter is any object that knows how to list its keys and look up by key.
+type KeyListerGetter[T any] interface {
+	KeyLister
+	KeyGetter[T]
+}
+
+// KeyLister is any object that knows how to list its keys.
+type KeyLister interface {
+	ListKeys() []string
+}
+
+// KeyGetter is any object that knows how to get the value stored under a given key.
+type KeyGetter[T any] interface {
+	// GetByKey returns the value associated with the key, or sets exists=false.
+	GetByKey(key string) (value T, exists bool, err error)
+}
+
+// PopProcessFunc is passed to Pop() method of DeltaFIFO.
+// It is supposed to process the accumulator popped from the queue.
+// isInInitialList reports whether the accumulator was queued by the
+// first Replace, before the queue had synced.
+type PopProcessFunc[T any] func(deltas Deltas[T], isInInitialList bool) error
+
+var (
+	// ErrFIFOClosed used when FIFO is closed
+	ErrFIFOClosed = errors.New("DeltaFIFO: manipulating with closed queue")
+
+	// ErrZeroLengthDeltasObject is returned in a KeyError if a Deltas
+	// object with zero length is encountered (should be impossible,
+	// but included for completeness).
+	ErrZeroLengthDeltasObject = errors.New("0 length Deltas object; can't get key")
+)
+
+// NewDeltaFIFOWithOptions returns a DeltaFIFO which can be used to process changes to
+// items. See also the comment on DeltaFIFO.
+func NewDeltaFIFOWithOptions[T any](opts DeltaFIFOOptions[T]) *DeltaFIFO[T] {
+	if opts.KeyFunction == nil {
+		opts.KeyFunction = func(obj T) (string, error) {
+			if o, ok := any(obj).(metav1.Object); ok {
+				return MetaNamespaceKeyFunc(o)
+			}
+			return "", fmt.Errorf("object %T has no metadata, a KeyFunction is required", obj)
+		}
+	}
+
+	f := &DeltaFIFO[T]{
+		items:        map[string]Deltas[T]{},
+		queue:        []string{},
+		keyFunc:      opts.KeyFunction,
+		knownObjects: opts.KnownObjects,
+		transformer:  opts.Transformer,
+	}
+	f.cond.L = &f.lock
+	return f
+}
+
+// Close the queue.
+func (f *DeltaFIFO[T]) Close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closed = true
+	f.cond.Broadcast()
+}
+
+// KeyOf exposes f's keyFunc.
+func (f *DeltaFIFO[T]) KeyOf(obj T) (string, error) {
+	return f.keyFunc(obj)
+}
+
+// keyOfDeltas returns the key of the object a Deltas is about.
+func (f *DeltaFIFO[T]) keyOfDeltas(deltas Deltas[T]) (string, error) {
+	d := deltas.Newest()
+	if d == nil {
+		return "", KeyError{deltas, ErrZeroLengthDeltasObject}
+	}
+	return f.keyFunc(d.Object)
+}
+
+// HasSynced returns true if an Add/Update/Delete/AddIfNotPresent are called first,
+// or the first batch of items inserted by Replace() has been popped.
+func (f *DeltaFIFO[T]) HasSynced() bool {
+	return f.synced.Load()
+}
+
+func (f *DeltaFIFO[T]) hasSyncedLocked() bool {
+	return f.populated && f.initialPopulationCount == 0
+}
+
+// updateSyncedLocked publishes the result of hasSyncedLocked for HasSynced.
+func (f *DeltaFIFO[T]) updateSyncedLocked() {
+	f.synced.Store(f.hasSyncedLocked())
+}
+
+// Add inserts an item, and puts it in the queue. The item is only enqueued
+// if it doesn't already exist in the set.
+func (f *DeltaFIFO[T]) Add(obj T) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.populated = true
+	f.updateSyncedLocked()
+	return f.queueActionLocked(Added, obj, false)
+}
+
+// Update is just like Add, but makes an Updated Delta.
+func (f *DeltaFIFO[T]) Update(obj T) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.populated = true
+	f.updateSyncedLocked()
+	return f.queueActionLocked(Updated, obj, false)
+}
+
+// Delete is just like Add, but makes a Deleted Delta. If the given
+// object does not already exist, it will be ignored. (It may have
+// already been deleted by a Replace (re-list), for example.)  In this
+// method `f.knownObjects`, if not nil, provides (via GetByKey)
+// _additional_ objects that are considered to already exist.
+func (f *DeltaFIFO[T]) Delete(obj T) error {
+	id, err := f.KeyOf(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.populated = true
+	f.updateSyncedLocked()
+	if f.knownObjects == nil {
+		if _, exists := f.items[id]; !exists {
+			// Presumably, this was deleted when a relist happened.
+			// Don't provide a second report of the same deletion.
+			return nil
+		}
+	} else {
+		// We only want to skip the "deletion" action if the object doesn't
+		// exist in knownObjects and it doesn't have corresponding item in items.
+		// Note that even if there is a "deletion" action in items, we can ignore it,
+		// because it will be deduped automatically in "queueActionLocked"
+		_, exists, err := f.knownObjects.GetByKey(id)
+		_, itemsExist := f.items[id]
+		if err == nil && !exists && !itemsExist {
+			// Presumably, this was deleted when a relist happened.
+			// Don't provide a second report of the same deletion.
+			return nil
+		}
+	}
+
+	// exist in items and/or KnownObjects
+	return f.queueActionLocked(Deleted, obj, false)
+}
+
+// queueActionLocked appends to the delta list for the object.
+// Caller must lock first.
+func (f *DeltaFIFO[T]) queueActionLocked(actionType DeltaType, obj T, finalStateUnknown bool) error {
+	id, err := f.KeyOf(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+
+	// Every object comes through this code path once, so this is a good
+	// place to call the transform func. Sync deltas and the tombstones of
+	// Replace carry objects taken from the queue or from knownObjects,
+	// which have already been transformed.
+	if f.transformer != nil && actionType != Sync && !finalStateUnknown {
+		obj, err = f.transformer(obj)
+		if err != nil {
+			return err
+		}
+	}
+
+	oldDeltas := f.items[id]
+	newDeltas := append(oldDeltas, Delta[T]{Type: actionType, Object: obj, FinalStateUnknown: finalStateUnknown})
+	newDeltas = dedupDeltas(newDeltas)
+
+	if len(newDeltas) > 0 {
+		if _, exists := f.items[id]; !exists {
+			f.queue = append(f.queue, id)
+		}
+		f.items[id] = newDeltas
+		f.cond.Broadcast()
+	} else {
+		// This never happens, because dedupDeltas never returns an empty list
+		// when given a non-empty list (as it is here).
+		// If somehow it happens anyway, deal with it but complain.
+		if oldDeltas == nil {
+			return fmt.Errorf("impossible dedupDeltas for id=%q: oldDeltas=%#+v, obj=%#+v; ignoring", id, oldDeltas, obj)
+		}
+		f.items[id] = newDeltas
+		return fmt.Errorf("impossible dedupDeltas for id=%q: oldDeltas=%#+v, obj=%#+v; breaking invariant by storing empty Deltas", id, oldDeltas, obj)
+	}
+	return nil
+}
+
+// List returns a list of all the items; it returns the object
+// from the most recent Delta.
+// You should treat the items returned inside the deltas as immutable.
+func (f *DeltaFIFO[T]) List() []T {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.listLocked()
+}
+
+func (f *DeltaFIFO[T]) listLocked() []T {
+	list := make([]T, 0, len(f.items))
+	for _, item := range f.items {
+		list = append(list, item.Newest().Object)
+	}
+	return list
+}
+
+// ListKeys returns a list of all the keys of the objects currently
+// in the FIFO.
+func (f *DeltaFIFO[T]) ListKeys() []string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	list := make([]string, len(f.queue))
+	copy(list, f.queue)
+	return list
+}
+
+// Get returns the complete list of deltas for the requested item,
+// or sets exists=false.
+// You should treat the items returned inside the deltas as immutable.
+func (f *DeltaFIFO[T]) Get(obj T) (item Deltas[T], exists bool, err error) {
+	key, err := f.KeyOf(obj)
+	if err != nil {
+		return nil, false, KeyError{obj, err}
+	}
+	return f.GetByKey(key)
+}
+
+// GetByKey returns the complete list of deltas for the requested item,
+// setting exists=false if that list is empty.
+// You should treat the items returned inside the deltas as immutable.
+func (f *DeltaFIFO[T]) GetByKey(key string) (item Deltas[T], exists bool, err error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	d, exists := f.items[key]
+	if exists {
+		// Copy item's slice so operations on this slice
+		// won't interfere with the object we return.
+		d = copyDeltas(d)
+	}
+	return d, exists, nil
+}
+
+// IsClosed checks if the queue is closed
+func (f *DeltaFIFO[T]) IsClosed() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.closed
+}
+
+// Pop blocks until the queue has some items, and then returns one.  If
+// multiple items are ready, they are returned in the order in which they were
+// added/updated. The item is removed from the queue (and the store) before it
+// is returned, so if you don't successfully process it, you need to add it back
+// with AddIfNotPresent().
+// process function is called under lock, so it is safe to update data structures
+// in it that need to be in sync with the queue (e.g. knownKeys). The PopProcessFunc
+// may return an instance of ErrRequeue with a nested error to indicate the current
+// item should be requeued (equivalent to calling AddIfNotPresent under the lock).
+// process should avoid expensive I/O operation so that other queue operations, i.e.
+// Add() and Get(), won't be blocked for too long.
+//
+// Pop returns a 'Deltas', which has a complete list of all the things
+// that happened to the object (deltas) while it was sitting in the queue.
+func (f *DeltaFIFO[T]) Pop(process PopProcessFunc[T]) (Deltas[T], error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for {
+		for len(f.queue) == 0 {
+			// When the queue is empty, invocation of Pop() is blocked until new item is enqueued.
+			// When Close() is called, the f.closed is set and the condition is broadcasted.
+			// Which causes this loop to continue and return from the Pop().
+			if f.closed {
+				return nil, ErrFIFOClosed
+			}
+
+			f.cond.Wait()
+		}
+		isInInitialList := !f.hasSyncedLocked()
+		id := f.queue[0]
+		f.queue = f.queue[1:]
+		if f.initialPopulationCount > 0 {
+			f.initialPopulationCount--
+		}
+		item, ok := f.items[id]
+		if !ok {
+			// This should never happen
+			continue
+		}
+		delete(f.items, id)
+		err := process(item, isInInitialList)
+		// HasSynced flips only once the last item of the initial list
+		// has been processed.
+		f.updateSyncedLocked()
+		var requeue ErrRequeue
+		if errors.As(err, &requeue) {
+			f.addIfNotPresentLocked(id, item)
+			err = requeue.Err
+		}
+		// Don't need to copyDeltas here, because we're transferring
+		// ownership to the caller.
+		return item, err
+	}
+}
+
+// ErrRequeue may be returned by a PopProcessFunc to safely requeue
+// the current item. The value of Err will be returned from Pop.
+type ErrRequeue struct {
+	// Err is returned by the Pop function
+	Err error
+}
+
+func (e ErrRequeue) Error() string {
+	if e.Err == nil {
+		return "the popped item should be requeued without returning an error"
+	}
+	return e.Err.Error()
+}
+
+// AddIfNotPresent inserts deltas under their key only if no deltas are
+// already queued for it. This is useful in a single producer/consumer
+// scenario so that the consumer can safely retry items without contending
+// with the producer and potentially enqueueing stale items.
+func (f *DeltaFIFO[T]) AddIfNotPresent(deltas Deltas[T]) error {
+	id, err := f.keyOfDeltas(deltas)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.addIfNotPresentLocked(id, deltas)
+	return nil
+}
+
+// addIfNotPresentLocked inserts deltas if they are not already present.
+// Assumes the caller has already called KeyOf.
+func (f *DeltaFIFO[T]) addIfNotPresentLocked(id string, deltas Deltas[T]) {
+	f.populated = true
+	f.updateSyncedLocked()
+	if _, exists := f.items[id]; exists {
+		return
+	}
+
+	f.queue = append(f.queue, id)
+	f.items[id] = deltas
+	f.cond.Broadcast()
+}
+
+// Replace atomically does two things: (1) it adds the given objects
+// using the Replaced type, and then (2) it does some deletions.
+// In particular: for every pre-existing key K that is not the key of
+// an object in `list` there is the effect of
+// a Deleted delta of O with FinalStateUnknown set, where O is the latest known
+// object of K. The pre-existing keys are those in the union set of the keys in
+// `f.items` and `f.knownObjects` (if not nil). The last known object for key K is
+// the one present in the last delta in `f.items`. If there is no delta for K
+// in `f.items`, it is the object in `f.knownObjects`
+func (f *DeltaFIFO[T]) Replace(list []T, _ string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keys := make(map[string]struct{}, len(list))
+
+	for _, item := range list {
+		key, err := f.KeyOf(item)
+		if err != nil {
+			return KeyError{item, err}
+		}
+		keys[key] = struct{}{}
+		if err := f.queueActionLocked(Replaced, item, false); err != nil {
+			return fmt.Errorf("couldn't enqueue object: %v", err)
+		}
+	}
+
+	// Do deletion detection against objects in the queue
+	for k, oldItem := range f.items {
+		if _, exists := keys[k]; exists {
+			continue
+		}
+		// Delete pre-existing items not in the new list.
+		// This could happen if watch deletion event was missed while
+		// disconnected from apiserver.
+		deletedObj := oldItem.Newest().Object
+		if err := f.queueActionLocked(Deleted, deletedObj, true); err != nil {
+			return err
+		}
+	}
+
+	if f.knownObjects != nil {
+		// Detect deletions for objects not present in the queue, but present in KnownObjects
+		knownKeys := f.knownObjects.ListKeys()
+		for _, k := range knownKeys {
+			if _, exists := keys[k]; exists {
+				continue
+			}
+			if _, exists := f.items[k]; exists {
+				continue
+			}
+
+			deletedObj, exists, err := f.knownObjects.GetByKey(k)
+			if err != nil {
+				continue
+			} else if !exists {
+				continue
+			}
+			if err := f.queueActionLocked(Deleted, deletedObj, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !f.populated {
+		f.populated = true
+		f.initialPopulationCount = len(f.queue)
+	}
+	f.updateSyncedLocked()
+
+	return nil
+}
+
+// Resync adds, with a Sync type of Delta, every object listed by
+// `f.knownObjects` whose key is not already queued for processing.
+// If `f.knownObjects` is `nil` then Resync does nothing.
+func (f *DeltaFIFO[T]) Resync() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.knownObjects == nil {
+		return nil
+	}
+
+	keys := f.knownObjects.ListKeys()
+	for _, k := range keys {
+		if err := f.syncKeyLocked(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *DeltaFIFO[T]) syncKeyLocked(key string) error {
+	obj, exists, err := f.knownObjects.GetByKey(key)
+	if err != nil {
+		return fmt.Errorf("unexpected error when fetching key %v in knownObjects during resync: %w", key, err)
+	} else if !exists {
+		return nil
+	}
+
+	// If we are doing Resync() and there is already an event queued for that object,
+	// we ignore the Resync for it. This is to avoid the race, in which the resync
+	// comes with the previous value of object (since queueing an event for the object
+	// doesn't trigger changing the underlying store <knownObjects>.
+	id, err := f.KeyOf(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+	if len(f.items[id]) > 0 {
+		return nil
+	}
+
+	if err := f.queueActionLocked(Sync, obj, false); err != nil {
+		return fmt.Errorf("couldn't queue object: %v", err)
+	}
+	return nil
+}
+
+// Oldest is a convenience function that returns the oldest delta, or
+// nil if there are no deltas.
+func (d Deltas[T]) Oldest() *Delta[T] {
+	if len(d) > 0 {
+		return &d[0]
+	}
+	return nil
+}
+
+// Newest is a convenience function that returns the newest delta, or
+// nil if there are no deltas.
+func (d Deltas[T]) Newest() *Delta[T] {
+	if n := len(d); n > 0 {
+		return &d[n-1]
+	}
+	return nil
+}
+
+// copyDeltas returns a shallow copy of d; that is, it copies the slice but not
+// the objects in the slice. This allows Get/List to return an object that we
+// know won't be clobbered by a subsequent modifications.
+func copyDeltas[T any](d Deltas[T]) Deltas[T] {
+	d2 := make(Deltas[T], len(d))
+	copy(d2, d)
+	return d2
+}
+
+// re-listing and watching can deliver the same update multiple times in any
+// order. This will combine the most recent two deltas if they are the same.
+func dedupDeltas[T any](deltas Deltas[T]) Deltas[T] {
+	n := len(deltas)
+	if n < 2 {
+		return deltas
+	}
+	a := &deltas[n-1]
+	b := &deltas[n-2]
+	if out := isDup(a, b); out != nil {
+		deltas[n-2] = *out
+		return deltas[:n-1]
+	}
+	return deltas
+}
+
+// If a & b represent the same event, returns the delta that ought to be kept.
+// Otherwise, returns nil.
+// TODO: is there anything other than deletions that need deduping?
+func isDup[T any](a, b *Delta[T]) *Delta[T] {
+	if out := isDeletionDup(a, b); out != nil {
+		return out
+	}
+	// TODO: Detect other duplicate situations? Are there any?
+	return nil
+}
+
+// keep the one with the most information if both are deletions.
+func isDeletionDup[T any](a, b *Delta[T]) *Delta[T] {
+	if b.Type != Deleted || a.Type != Deleted {
+		return nil
+	}
+	// Do more sophisticated checks, or is this sufficient?
+	if b.FinalStateUnknown {
+		return a
+	}
+	return b
+}
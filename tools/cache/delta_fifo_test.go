@@ -0,0 +1,962 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type testFifoObject struct {
+	name string
+	val  interface{}
+}
+
+func mkFifoObj(name string, val interface{}) testFifoObject {
+	return testFifoObject{name: name, val: val}
+}
+
+func testFifoObjectKeyFunc(obj testFifoObject) (string, error) {
+	return obj.name, nil
+}
+
+func newTestFIFO(knownObjects KeyListerGetter[testFifoObject]) *DeltaFIFO[testFifoObject] {
+	return NewDeltaFIFOWithOptions(DeltaFIFOOptions[testFifoObject]{
+		KeyFunction:  testFifoObjectKeyFunc,
+		KnownObjects: knownObjects,
+	})
+}
+
+// literalListerGetter is a KeyListerGetter that is based on a
+// function that returns a slice of objects to list and get.
+// The function must list the same objects every time.
+type literalListerGetter func() []testFifoObject
+
+var _ KeyListerGetter[testFifoObject] = literalListerGetter(nil)
+
+// ListKeys just calls kl.
+func (kl literalListerGetter) ListKeys() []string {
+	result := []string{}
+	for _, fifoObj := range kl() {
+		result = append(result, fifoObj.name)
+	}
+	return result
+}
+
+// GetByKey returns the key if it exists in the list returned by kl.
+func (kl literalListerGetter) GetByKey(key string) (testFifoObject, bool, error) {
+	for _, v := range kl() {
+		if v.name == key {
+			return v, true, nil
+		}
+	}
+	return testFifoObject{}, false, nil
+}
+
+// testPop pops an item, failing the test if none shows up in time.
+func testPop(t *testing.T, f *DeltaFIFO[testFifoObject]) Deltas[testFifoObject] {
+	t.Helper()
+	var (
+		deltas Deltas[testFifoObject]
+		err    error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deltas, err = f.Pop(func(Deltas[testFifoObject], bool) error { return nil })
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		f.Close()
+		<-done
+		t.Fatalf("timed out waiting for an item to pop")
+	}
+	if err != nil {
+		t.Fatalf("unexpected error popping: %v", err)
+	}
+	return deltas
+}
+
+// testPopIfAvailable returns the next item or false if the queue is empty.
+func testPopIfAvailable(f *DeltaFIFO[testFifoObject]) (Deltas[testFifoObject], bool) {
+	if len(f.ListKeys()) == 0 {
+		return nil, false
+	}
+	deltas, err := f.Pop(func(Deltas[testFifoObject], bool) error { return nil })
+	return deltas, err == nil
+}
+
+func TestDeltaFIFO_basic(t *testing.T) {
+	f := newTestFIFO(nil)
+	const amount = 500
+	go func() {
+		for i := 0; i < amount; i++ {
+			f.Add(mkFifoObj(string([]rune{'a', rune(i)}), i+1))
+		}
+	}()
+	go func() {
+		for u := uint64(0); u < amount; u++ {
+			f.Add(mkFifoObj(string([]rune{'b', rune(u)}), u+1))
+		}
+	}()
+
+	lastInt := 0
+	lastUint := uint64(0)
+	for i := 0; i < amount*2; i++ {
+		switch obj := testPop(t, f).Newest().Object.val.(type) {
+		case int:
+			if obj <= lastInt {
+				t.Errorf("got %v (int) out of order, last was %v", obj, lastInt)
+			}
+			lastInt = obj
+		case uint64:
+			if obj <= lastUint {
+				t.Errorf("got %v (uint) out of order, last was %v", obj, lastUint)
+			} else {
+				lastUint = obj
+			}
+		default:
+			t.Fatalf("unexpected type %#v", obj)
+		}
+	}
+}
+
+func TestDeltaFIFO_requeueOnPop(t *testing.T) {
+	f := newTestFIFO(nil)
+
+	f.Add(mkFifoObj("foo", 10))
+	_, err := f.Pop(func(deltas Deltas[testFifoObject], isInInitialList bool) error {
+		if deltas[0].Object.name != "foo" {
+			t.Fatalf("unexpected object: %#v", deltas)
+		}
+		return ErrRequeue{Err: nil}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok, err := f.GetByKey("foo"); !ok || err != nil {
+		t.Fatalf("item should have been requeued: %t %v", ok, err)
+	}
+
+	_, err = f.Pop(func(deltas Deltas[testFifoObject], isInInitialList bool) error {
+		return ErrRequeue{Err: fmt.Errorf("test error")}
+	})
+	if err == nil || err.Error() != "test error" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok, err := f.GetByKey("foo"); !ok || err != nil {
+		t.Fatalf("item should have been requeued: %t %v", ok, err)
+	}
+
+	_, err = f.Pop(func(deltas Deltas[testFifoObject], isInInitialList bool) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok, err := f.GetByKey("foo"); ok || err != nil {
+		t.Fatalf("item should have been removed: %t %v", ok, err)
+	}
+}
+
+func TestDeltaFIFO_addUpdate(t *testing.T) {
+	f := newTestFIFO(nil)
+	f.Add(mkFifoObj("foo", 10))
+	f.Update(mkFifoObj("foo", 12))
+	f.Delete(mkFifoObj("foo", 15))
+
+	if e, a := []testFifoObject{mkFifoObj("foo", 15)}, f.List(); !reflect.DeepEqual(e, a) {
+		t.Errorf("Expected %+v, got %+v", e, a)
+	}
+	if e, a := []string{"foo"}, f.ListKeys(); !reflect.DeepEqual(e, a) {
+		t.Errorf("Expected %+v, got %+v", e, a)
+	}
+
+	got := make(chan testFifoObject, 2)
+	go func() {
+		for {
+			obj, err := f.Pop(func(Deltas[testFifoObject], bool) error { return nil })
+			if err != nil {
+				return
+			}
+			got <- obj.Newest().Object
+		}
+	}()
+
+	first := <-got
+	if e, a := 15, first.val; e != a {
+		t.Errorf("Didn't get updated value (%v), got %v", e, a)
+	}
+	select {
+	case unexpected := <-got:
+		t.Errorf("Got second value %v", unexpected.val)
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, exists, _ := f.Get(mkFifoObj("foo", ""))
+	if exists {
+		t.Errorf("item did not get removed")
+	}
+	f.Close()
+}
+
+func TestDeltaFIFO_enqueueingNoLister(t *testing.T) {
+	f := newTestFIFO(nil)
+	f.Add(mkFifoObj("foo", 10))
+	f.Update(mkFifoObj("bar", 15))
+	f.Add(mkFifoObj("qux", 17))
+	f.Delete(mkFifoObj("qux", 18))
+
+	// This delete does not enqueue anything because baz doesn't exist.
+	f.Delete(mkFifoObj("baz", 20))
+
+	expectList := []int{10, 15, 18}
+	for _, expect := range expectList {
+		if e, a := expect, testPop(t, f).Newest().Object.val; e != a {
+			t.Errorf("Didn't get updated value (%v), got %v", e, a)
+		}
+	}
+	if e, a := 0, len(f.items); e != a {
+		t.Errorf("queue unexpectedly not empty: %v != %v\n%#v", e, a, f.items)
+	}
+}
+
+func TestDeltaFIFO_enqueueingWithLister(t *testing.T) {
+	f := newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5), mkFifoObj("bar", 6), mkFifoObj("baz", 7)}
+	}))
+	f.Add(mkFifoObj("foo", 10))
+	f.Update(mkFifoObj("bar", 15))
+
+	// This delete does enqueue the deletion, because "baz" is in the key lister.
+	f.Delete(mkFifoObj("baz", 20))
+
+	expectList := []int{10, 15, 20}
+	for _, expect := range expectList {
+		if e, a := expect, testPop(t, f).Newest().Object.val; e != a {
+			t.Errorf("Didn't get updated value (%v), got %v", e, a)
+		}
+	}
+	if e, a := 0, len(f.items); e != a {
+		t.Errorf("queue unexpectedly not empty: %v != %v", e, a)
+	}
+}
+
+func TestDeltaFIFO_addReplace(t *testing.T) {
+	f := newTestFIFO(nil)
+	f.Add(mkFifoObj("foo", 10))
+	f.Replace([]testFifoObject{mkFifoObj("foo", 15)}, "0")
+	got := make(chan testFifoObject, 2)
+	go func() {
+		for {
+			obj, err := f.Pop(func(Deltas[testFifoObject], bool) error { return nil })
+			if err != nil {
+				return
+			}
+			got <- obj.Newest().Object
+		}
+	}()
+
+	first := <-got
+	if e, a := 15, first.val; e != a {
+		t.Errorf("Didn't get updated value (%v), got %v", e, a)
+	}
+	select {
+	case unexpected := <-got:
+		t.Errorf("Got second value %v", unexpected.val)
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, exists, _ := f.Get(mkFifoObj("foo", ""))
+	if exists {
+		t.Errorf("item did not get removed")
+	}
+	f.Close()
+}
+
+func TestDeltaFIFO_ResyncNonExisting(t *testing.T) {
+	f := newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5)}
+	}))
+	f.Delete(mkFifoObj("foo", 10))
+	f.Resync()
+
+	deltas := f.items["foo"]
+	if len(deltas) != 1 {
+		t.Fatalf("unexpected deltas length: %v", deltas)
+	}
+	if deltas[0].Type != Deleted {
+		t.Errorf("unexpected delta: %v", deltas[0])
+	}
+}
+
+func TestDeltaFIFO_Resync(t *testing.T) {
+	f := newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5), mkFifoObj("bar", 6)}
+	}))
+	f.Update(mkFifoObj("bar", 16))
+	f.Resync()
+
+	// foo has nothing queued so it is synced, bar's queued update wins over
+	// the stale known state.
+	expected := map[string]Deltas[testFifoObject]{
+		"foo": {{Type: Sync, Object: mkFifoObj("foo", 5)}},
+		"bar": {{Type: Updated, Object: mkFifoObj("bar", 16)}},
+	}
+	if !reflect.DeepEqual(expected, f.items) {
+		t.Errorf("expected %#v, got %#v", expected, f.items)
+	}
+}
+
+func TestDeltaFIFO_Transformer(t *testing.T) {
+	known := []testFifoObject{mkFifoObj("foo", "known"), mkFifoObj("baz", "known")}
+	transformed := []string{}
+	f := NewDeltaFIFOWithOptions(DeltaFIFOOptions[testFifoObject]{
+		KeyFunction:  testFifoObjectKeyFunc,
+		KnownObjects: literalListerGetter(func() []testFifoObject { return known }),
+		Transformer: func(obj testFifoObject) (testFifoObject, error) {
+			transformed = append(transformed, obj.name)
+			if obj.val == "bad" {
+				return obj, fmt.Errorf("cannot transform %s", obj.name)
+			}
+			obj.val = fmt.Sprintf("%v!", obj.val)
+			return obj, nil
+		},
+	})
+
+	f.Add(mkFifoObj("foo", 1))
+	f.Update(mkFifoObj("bar", 2))
+	f.Delete(mkFifoObj("foo", 3))
+	f.Resync()
+	// bar is not known, so Replace queues a tombstone for baz only.
+	f.Replace([]testFifoObject{mkFifoObj("foo", 4), mkFifoObj("bar", 5)}, "0")
+	if err := f.Add(mkFifoObj("qux", "bad")); err == nil {
+		t.Error("expected the transform error to be returned")
+	}
+
+	// Resyncs and tombstones reuse objects which were already transformed.
+	if e, a := []string{"foo", "bar", "foo", "foo", "bar", "qux"}, transformed; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected transforms of %v, got %v", e, a)
+	}
+	expected := map[string]Deltas[testFifoObject]{
+		"foo": {
+			{Type: Added, Object: mkFifoObj("foo", "1!")},
+			{Type: Deleted, Object: mkFifoObj("foo", "3!")},
+			{Type: Replaced, Object: mkFifoObj("foo", "4!")},
+		},
+		"bar": {
+			{Type: Updated, Object: mkFifoObj("bar", "2!")},
+			{Type: Replaced, Object: mkFifoObj("bar", "5!")},
+		},
+		"baz": {
+			{Type: Sync, Object: mkFifoObj("baz", "known")},
+			{Type: Deleted, Object: mkFifoObj("baz", "known"), FinalStateUnknown: true},
+		},
+	}
+	if !reflect.DeepEqual(expected, f.items) {
+		t.Errorf("expected %#v, got %#v", expected, f.items)
+	}
+}
+
+func TestDeltaFIFO_ResyncNoKnownObjects(t *testing.T) {
+	f := newTestFIFO(nil)
+	f.Add(mkFifoObj("foo", 5))
+	if err := f.Resync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := (Deltas[testFifoObject]{{Type: Added, Object: mkFifoObj("foo", 5)}}), f.items["foo"]; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %#v, got %#v", e, a)
+	}
+}
+
+func TestDeltaFIFO_DeleteExistingNonPropagated(t *testing.T) {
+	f := newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{}
+	}))
+	f.Add(mkFifoObj("foo", 5))
+	f.Delete(mkFifoObj("foo", 6))
+
+	deltas := f.items["foo"]
+	if len(deltas) != 2 {
+		t.Fatalf("unexpected deltas length: %v", deltas)
+	}
+	if deltas[len(deltas)-1].Type != Deleted {
+		t.Errorf("unexpected delta: %v", deltas[len(deltas)-1])
+	}
+}
+
+func TestDeltaFIFO_ReplaceMakesDeletions(t *testing.T) {
+	// We test with only one pre-existing object because there is no
+	// promise about how their deletes are ordered.
+
+	// Try it with a pre-existing Delete
+	f := newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5), mkFifoObj("bar", 6), mkFifoObj("baz", 7)}
+	}))
+	f.Delete(mkFifoObj("baz", 10))
+	f.Replace([]testFifoObject{mkFifoObj("foo", 5)}, "0")
+
+	expectedList := []Deltas[testFifoObject]{
+		{{Type: Deleted, Object: mkFifoObj("baz", 10)}},
+		{{Type: Replaced, Object: mkFifoObj("foo", 5)}},
+		// Since "bar" didn't have a delete event and wasn't in the Replace list
+		// it should get a tombstone key with the right Obj.
+		{{Type: Deleted, Object: mkFifoObj("bar", 6), FinalStateUnknown: true}},
+	}
+
+	for _, expected := range expectedList {
+		cur := testPop(t, f)
+		if e, a := expected, cur; !reflect.DeepEqual(e, a) {
+			t.Errorf("Expected %#v, got %#v", e, a)
+		}
+	}
+
+	// Now try starting with an Add instead of a Delete
+	f = newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5), mkFifoObj("bar", 6), mkFifoObj("baz", 7)}
+	}))
+	f.Add(mkFifoObj("baz", 10))
+	f.Replace([]testFifoObject{mkFifoObj("foo", 5)}, "0")
+
+	expectedList = []Deltas[testFifoObject]{
+		{{Type: Added, Object: mkFifoObj("baz", 10)},
+			{Type: Deleted, Object: mkFifoObj("baz", 10), FinalStateUnknown: true}},
+		{{Type: Replaced, Object: mkFifoObj("foo", 5)}},
+		// Since "bar" didn't have a delete event and wasn't in the Replace list
+		// it should get a tombstone key with the right Obj.
+		{{Type: Deleted, Object: mkFifoObj("bar", 6), FinalStateUnknown: true}},
+	}
+
+	for _, expected := range expectedList {
+		cur := testPop(t, f)
+		if e, a := expected, cur; !reflect.DeepEqual(e, a) {
+			t.Errorf("Expected %#v, got %#v", e, a)
+		}
+	}
+
+	// Now try deleting and recreating the object in the queue, then delete it by a Replace call
+	f = newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5), mkFifoObj("bar", 6), mkFifoObj("baz", 7)}
+	}))
+	f.Delete(mkFifoObj("bar", 6))
+	f.Add(mkFifoObj("bar", 100))
+	f.Replace([]testFifoObject{mkFifoObj("foo", 5)}, "0")
+
+	expectedList = []Deltas[testFifoObject]{
+		{
+			{Type: Deleted, Object: mkFifoObj("bar", 6)},
+			{Type: Added, Object: mkFifoObj("bar", 100)},
+			// Since "bar" has a newer object in the queue than in the state,
+			// it should get a tombstone key with the latest object from the queue
+			{Type: Deleted, Object: mkFifoObj("bar", 100), FinalStateUnknown: true},
+		},
+		{{Type: Replaced, Object: mkFifoObj("foo", 5)}},
+		{{Type: Deleted, Object: mkFifoObj("baz", 7), FinalStateUnknown: true}},
+	}
+
+	for _, expected := range expectedList {
+		cur := testPop(t, f)
+		if e, a := expected, cur; !reflect.DeepEqual(e, a) {
+			t.Errorf("Expected %#v, got %#v", e, a)
+		}
+	}
+
+	// Now try syncing it first to ensure the delete use the latest version
+	f = newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5), mkFifoObj("bar", 6), mkFifoObj("baz", 7)}
+	}))
+	f.Update(mkFifoObj("bar", 100))
+	f.Resync()
+	f.Replace([]testFifoObject{mkFifoObj("foo", 5)}, "0")
+
+	expectedList = []Deltas[testFifoObject]{
+		{
+			{Type: Updated, Object: mkFifoObj("bar", 100)},
+			// Since "bar" has a newer object in the queue than in the state,
+			// it should get a tombstone key with the latest object from the queue
+			{Type: Deleted, Object: mkFifoObj("bar", 100), FinalStateUnknown: true},
+		},
+		{
+			{Type: Sync, Object: mkFifoObj("foo", 5)},
+			{Type: Replaced, Object: mkFifoObj("foo", 5)},
+		},
+		{
+			{Type: Sync, Object: mkFifoObj("baz", 7)},
+			{Type: Deleted, Object: mkFifoObj("baz", 7), FinalStateUnknown: true},
+		},
+	}
+
+	for _, expected := range expectedList {
+		cur := testPop(t, f)
+		if e, a := expected, cur; !reflect.DeepEqual(e, a) {
+			t.Errorf("Expected %#v, got %#v", e, a)
+		}
+	}
+
+	// Now try starting without an explicit KeyListerGetter
+	f = newTestFIFO(nil)
+	f.Add(mkFifoObj("baz", 10))
+	f.Replace([]testFifoObject{mkFifoObj("foo", 5)}, "0")
+
+	expectedList = []Deltas[testFifoObject]{
+		{{Type: Added, Object: mkFifoObj("baz", 10)},
+			{Type: Deleted, Object: mkFifoObj("baz", 10), FinalStateUnknown: true}},
+		{{Type: Replaced, Object: mkFifoObj("foo", 5)}},
+	}
+
+	for _, expected := range expectedList {
+		cur := testPop(t, f)
+		if e, a := expected, cur; !reflect.DeepEqual(e, a) {
+			t.Errorf("Expected %#v, got %#v", e, a)
+		}
+	}
+}
+
+// TestDeltaFIFO_ReplaceMakesDeletionsForObjectsOnlyInQueue checks that
+// Replace does not miss deleting objects that have been deleted from the
+// consumer's store but are still in the queue.
+func TestDeltaFIFO_ReplaceMakesDeletionsForObjectsOnlyInQueue(t *testing.T) {
+	obj := mkFifoObj("foo", 2)
+	objV2 := mkFifoObj("foo", 3)
+	table := []struct {
+		name           string
+		operations     func(f *DeltaFIFO[testFifoObject])
+		expectedDeltas Deltas[testFifoObject]
+	}{
+		{
+			name: "Added object should be deleted on Replace",
+			operations: func(f *DeltaFIFO[testFifoObject]) {
+				f.Add(obj)
+				f.Replace([]testFifoObject{}, "0")
+			},
+			expectedDeltas: Deltas[testFifoObject]{
+				{Type: Added, Object: obj},
+				{Type: Deleted, Object: obj, FinalStateUnknown: true},
+			},
+		},
+		{
+			name: "Replaced object should have only a single Delete",
+			operations: func(f *DeltaFIFO[testFifoObject]) {
+				f.Replace([]testFifoObject{obj}, "0")
+				f.Replace([]testFifoObject{}, "0")
+				f.Replace([]testFifoObject{}, "0")
+			},
+			expectedDeltas: Deltas[testFifoObject]{
+				{Type: Replaced, Object: obj},
+				{Type: Deleted, Object: obj, FinalStateUnknown: true},
+			},
+		},
+		{
+			name: "Deleted object should have only a single Delete",
+			operations: func(f *DeltaFIFO[testFifoObject]) {
+				f.Add(obj)
+				f.Delete(obj)
+				f.Replace([]testFifoObject{}, "0")
+			},
+			expectedDeltas: Deltas[testFifoObject]{
+				{Type: Added, Object: obj},
+				{Type: Deleted, Object: obj},
+			},
+		},
+		{
+			name: "Synced objects should be deleted on Replace",
+			operations: func(f *DeltaFIFO[testFifoObject]) {
+				f.Add(obj)
+				f.Update(objV2)
+				f.Resync()
+				f.Replace([]testFifoObject{}, "0")
+			},
+			expectedDeltas: Deltas[testFifoObject]{
+				{Type: Added, Object: obj},
+				{Type: Updated, Object: objV2},
+				{Type: Deleted, Object: objV2, FinalStateUnknown: true},
+			},
+		},
+		{
+			name: "Added objects should have a single Delete on multiple Replaces",
+			operations: func(f *DeltaFIFO[testFifoObject]) {
+				f.Add(obj)
+				f.Replace([]testFifoObject{}, "0")
+				f.Replace([]testFifoObject{}, "1")
+			},
+			expectedDeltas: Deltas[testFifoObject]{
+				{Type: Added, Object: obj},
+				{Type: Deleted, Object: obj, FinalStateUnknown: true},
+			},
+		},
+	}
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			// Test with a DeltaFIFO with a backing KnownObjects which is
+			// empty: the object is only in the queue.
+			fWithKnownObjects := newTestFIFO(literalListerGetter(func() []testFifoObject {
+				return []testFifoObject{}
+			}))
+			tt.operations(fWithKnownObjects)
+			actualDeltasWithKnownObjects := testPop(t, fWithKnownObjects)
+			if !reflect.DeepEqual(tt.expectedDeltas, actualDeltasWithKnownObjects) {
+				t.Errorf("expected %#v, got %#v", tt.expectedDeltas, actualDeltasWithKnownObjects)
+			}
+			if len(fWithKnownObjects.items) != 0 {
+				t.Errorf("expected no extra deltas (empty map), got %#v", fWithKnownObjects.items)
+			}
+
+			// Test with a DeltaFIFO without a backing KnownObjects
+			fWithoutKnownObjects := newTestFIFO(nil)
+			tt.operations(fWithoutKnownObjects)
+			actualDeltasWithoutKnownObjects := testPop(t, fWithoutKnownObjects)
+			if !reflect.DeepEqual(tt.expectedDeltas, actualDeltasWithoutKnownObjects) {
+				t.Errorf("expected %#v, got %#v", tt.expectedDeltas, actualDeltasWithoutKnownObjects)
+			}
+			if len(fWithoutKnownObjects.items) != 0 {
+				t.Errorf("expected no extra deltas (empty map), got %#v", fWithoutKnownObjects.items)
+			}
+		})
+	}
+}
+
+func TestDeltaFIFO_UpdateResyncRace(t *testing.T) {
+	f := newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5)}
+	}))
+	f.Update(mkFifoObj("foo", 6))
+	f.Resync()
+
+	expectedList := []Deltas[testFifoObject]{
+		{{Type: Updated, Object: mkFifoObj("foo", 6)}},
+	}
+
+	for _, expected := range expectedList {
+		cur := testPop(t, f)
+		if e, a := expected, cur; !reflect.DeepEqual(e, a) {
+			t.Errorf("Expected %#v, got %#v", e, a)
+		}
+	}
+}
+
+func TestDeltaFIFO_HasSyncedCorrectOnDeletion(t *testing.T) {
+	f := newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5), mkFifoObj("bar", 6), mkFifoObj("baz", 7)}
+	}))
+	f.Replace([]testFifoObject{mkFifoObj("foo", 5)}, "0")
+
+	expectedList := []Deltas[testFifoObject]{
+		{{Type: Replaced, Object: mkFifoObj("foo", 5)}},
+		// Since "bar" didn't have a delete event and wasn't in the Replace list
+		// it should get a tombstone key with the right Obj.
+		{{Type: Deleted, Object: mkFifoObj("bar", 6), FinalStateUnknown: true}},
+		{{Type: Deleted, Object: mkFifoObj("baz", 7), FinalStateUnknown: true}},
+	}
+
+	for _, expected := range expectedList {
+		if f.HasSynced() {
+			t.Errorf("Expected HasSynced to be false")
+		}
+		cur := testPop(t, f)
+		if e, a := expected, cur; !reflect.DeepEqual(e, a) {
+			t.Errorf("Expected %#v, got %#v", e, a)
+		}
+	}
+	if !f.HasSynced() {
+		t.Errorf("Expected HasSynced to be true")
+	}
+}
+
+func TestDeltaFIFO_detectLineJumpers(t *testing.T) {
+	f := newTestFIFO(nil)
+
+	f.Add(mkFifoObj("foo", 10))
+	f.Add(mkFifoObj("bar", 1))
+	f.Add(mkFifoObj("foo", 11))
+	f.Add(mkFifoObj("foo", 13))
+	f.Add(mkFifoObj("zab", 30))
+
+	if e, a := 13, testPop(t, f).Newest().Object.val; a != e {
+		t.Fatalf("expected %d, got %d", e, a)
+	}
+
+	f.Add(mkFifoObj("foo", 14)) // ensure foo doesn't jump back in line
+
+	if e, a := 1, testPop(t, f).Newest().Object.val; a != e {
+		t.Fatalf("expected %d, got %d", e, a)
+	}
+
+	if e, a := 30, testPop(t, f).Newest().Object.val; a != e {
+		t.Fatalf("expected %d, got %d", e, a)
+	}
+
+	if e, a := 14, testPop(t, f).Newest().Object.val; a != e {
+		t.Fatalf("expected %d, got %d", e, a)
+	}
+}
+
+func TestDeltaFIFO_addIfNotPresent(t *testing.T) {
+	f := newTestFIFO(nil)
+
+	emptyDeltas := Deltas[testFifoObject]{}
+	if err := f.AddIfNotPresent(emptyDeltas); err == nil || !errors.Is(err, ErrZeroLengthDeltasObject) {
+		t.Errorf("Expected error '%v', got %v", ErrZeroLengthDeltasObject, err)
+	}
+
+	f.Add(mkFifoObj("b", 3))
+	b3 := testPop(t, f)
+	f.Add(mkFifoObj("c", 4))
+	c4 := testPop(t, f)
+	if e, a := 0, len(f.items); e != a {
+		t.Fatalf("Expected %v, got %v items in queue", e, a)
+	}
+
+	f.Add(mkFifoObj("a", 1))
+	f.Add(mkFifoObj("b", 2))
+	f.AddIfNotPresent(b3)
+	f.AddIfNotPresent(c4)
+
+	if e, a := 3, len(f.items); a != e {
+		t.Fatalf("expected queue length %d, got %d", e, a)
+	}
+
+	expectedValues := []int{1, 2, 4}
+	for _, expected := range expectedValues {
+		if actual := testPop(t, f).Newest().Object.val; actual != expected {
+			t.Fatalf("expected value %d, got %d", expected, actual)
+		}
+	}
+}
+
+func TestDeltaFIFO_KeyOf(t *testing.T) {
+	f := NewDeltaFIFOWithOptions(DeltaFIFOOptions[*testObject]{})
+	if key, err := f.KeyOf(newTestObject("ns", "a", "", "")); err != nil || key != "ns/a" {
+		t.Errorf("expected the default key func to produce ns/a, got %q, %v", key, err)
+	}
+
+	g := NewDeltaFIFOWithOptions(DeltaFIFOOptions[testFifoObject]{})
+	var keyErr KeyError
+	if err := g.Add(mkFifoObj("a", 1)); !errors.As(err, &keyErr) {
+		t.Errorf("expected a KeyError for an object without metadata, got %v", err)
+	}
+}
+
+func TestDeltaFIFO_HasSynced(t *testing.T) {
+	tests := []struct {
+		actions        []func(f *DeltaFIFO[testFifoObject])
+		expectedSynced bool
+	}{
+		{
+			actions:        []func(f *DeltaFIFO[testFifoObject]){},
+			expectedSynced: false,
+		},
+		{
+			actions: []func(f *DeltaFIFO[testFifoObject]){
+				func(f *DeltaFIFO[testFifoObject]) { f.Add(mkFifoObj("a", 1)) },
+			},
+			expectedSynced: true,
+		},
+		{
+			actions: []func(f *DeltaFIFO[testFifoObject]){
+				func(f *DeltaFIFO[testFifoObject]) { f.Replace([]testFifoObject{}, "0") },
+			},
+			expectedSynced: true,
+		},
+		{
+			actions: []func(f *DeltaFIFO[testFifoObject]){
+				func(f *DeltaFIFO[testFifoObject]) {
+					f.Replace([]testFifoObject{mkFifoObj("a", 1), mkFifoObj("b", 2)}, "0")
+				},
+			},
+			expectedSynced: false,
+		},
+		{
+			actions: []func(f *DeltaFIFO[testFifoObject]){
+				func(f *DeltaFIFO[testFifoObject]) {
+					f.Replace([]testFifoObject{mkFifoObj("a", 1), mkFifoObj("b", 2)}, "0")
+				},
+				func(f *DeltaFIFO[testFifoObject]) { testPopIfAvailable(f) },
+			},
+			expectedSynced: false,
+		},
+		{
+			actions: []func(f *DeltaFIFO[testFifoObject]){
+				func(f *DeltaFIFO[testFifoObject]) {
+					f.Replace([]testFifoObject{mkFifoObj("a", 1), mkFifoObj("b", 2)}, "0")
+				},
+				func(f *DeltaFIFO[testFifoObject]) { testPopIfAvailable(f) },
+				func(f *DeltaFIFO[testFifoObject]) { testPopIfAvailable(f) },
+			},
+			expectedSynced: true,
+		},
+		{
+			// This test case won't happen in practice since a Reflector, the only producer for delta_fifo today, always passes a complete snapshot consistent in time;
+			// there cannot be duplicate keys in the list or apiserver is broken.
+			actions: []func(f *DeltaFIFO[testFifoObject]){
+				func(f *DeltaFIFO[testFifoObject]) {
+					f.Replace([]testFifoObject{mkFifoObj("a", 1), mkFifoObj("a", 2)}, "0")
+				},
+				func(f *DeltaFIFO[testFifoObject]) { testPopIfAvailable(f) },
+			},
+			expectedSynced: true,
+		},
+		{
+			// Deletions inferred by the first Replace are part of the
+			// initial population.
+			actions: []func(f *DeltaFIFO[testFifoObject]){
+				func(f *DeltaFIFO[testFifoObject]) {
+					f.knownObjects = literalListerGetter(func() []testFifoObject {
+						return []testFifoObject{mkFifoObj("b", 2)}
+					})
+					f.Replace([]testFifoObject{mkFifoObj("a", 1)}, "0")
+				},
+				func(f *DeltaFIFO[testFifoObject]) { testPopIfAvailable(f) },
+			},
+			expectedSynced: false,
+		},
+	}
+
+	for i, test := range tests {
+		f := newTestFIFO(nil)
+
+		for _, action := range test.actions {
+			action(f)
+		}
+		if e, a := test.expectedSynced, f.HasSynced(); a != e {
+			t.Errorf("test case %v failed, expected: %v , got %v", i, e, a)
+		}
+	}
+}
+
+func TestDeltaFIFO_PopIsInInitialList(t *testing.T) {
+	f := newTestFIFO(nil)
+	f.Replace([]testFifoObject{mkFifoObj("a", 1)}, "0")
+	f.Add(mkFifoObj("b", 2))
+
+	var initial []bool
+	for i := 0; i < 2; i++ {
+		f.Pop(func(_ Deltas[testFifoObject], isInInitialList bool) error {
+			initial = append(initial, isInInitialList)
+			return nil
+		})
+	}
+	if e, a := []bool{true, false}, initial; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+// TestDeltaFIFO_PopShouldUnblockWhenClosed checks that any blocking Pop on an empty queue
+// should unblock and return after Close is called.
+func TestDeltaFIFO_PopShouldUnblockWhenClosed(t *testing.T) {
+	f := newTestFIFO(literalListerGetter(func() []testFifoObject {
+		return []testFifoObject{mkFifoObj("foo", 5)}
+	}))
+
+	c := make(chan struct{})
+	const jobs = 10
+	for i := 0; i < jobs; i++ {
+		go func() {
+			f.Pop(func(Deltas[testFifoObject], bool) error {
+				return nil
+			})
+			c <- struct{}{}
+		}()
+	}
+
+	runtime.Gosched()
+	f.Close()
+
+	for i := 0; i < jobs; i++ {
+		select {
+		case <-c:
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("timed out waiting for Pop to return after Close")
+		}
+	}
+	if !f.IsClosed() {
+		t.Error("expected the queue to be closed")
+	}
+	if _, err := f.Pop(func(Deltas[testFifoObject], bool) error { return nil }); err != ErrFIFOClosed {
+		t.Errorf("expected ErrFIFOClosed, got %v", err)
+	}
+}
+
+func TestDeltaFIFO_GetReturnsCopy(t *testing.T) {
+	f := newTestFIFO(nil)
+	f.Add(mkFifoObj("foo", 1))
+	deltas, exists, err := f.Get(mkFifoObj("foo", nil))
+	if err != nil || !exists {
+		t.Fatalf("expected foo to be queued, got %v %v", exists, err)
+	}
+	deltas[0].Type = Deleted
+	if f.items["foo"][0].Type != Added {
+		t.Error("modifying the result of Get changed the queue")
+	}
+}
+
+func TestDedupDeltas(t *testing.T) {
+	obj := mkFifoObj("foo", 2)
+	table := []struct {
+		name     string
+		in       Deltas[testFifoObject]
+		expected Deltas[testFifoObject]
+	}{
+		{
+			name:     "empty",
+			in:       Deltas[testFifoObject]{},
+			expected: Deltas[testFifoObject]{},
+		},
+		{
+			name:     "single",
+			in:       Deltas[testFifoObject]{{Type: Added, Object: obj}},
+			expected: Deltas[testFifoObject]{{Type: Added, Object: obj}},
+		},
+		{
+			name:     "two different",
+			in:       Deltas[testFifoObject]{{Type: Added, Object: obj}, {Type: Updated, Object: obj}},
+			expected: Deltas[testFifoObject]{{Type: Added, Object: obj}, {Type: Updated, Object: obj}},
+		},
+		{
+			name:     "two updates are kept",
+			in:       Deltas[testFifoObject]{{Type: Updated, Object: obj}, {Type: Updated, Object: obj}},
+			expected: Deltas[testFifoObject]{{Type: Updated, Object: obj}, {Type: Updated, Object: obj}},
+		},
+		{
+			name:     "two deletes",
+			in:       Deltas[testFifoObject]{{Type: Deleted, Object: obj}, {Type: Deleted, Object: mkFifoObj("foo", 3)}},
+			expected: Deltas[testFifoObject]{{Type: Deleted, Object: obj}},
+		},
+		{
+			name:     "tombstone then delete",
+			in:       Deltas[testFifoObject]{{Type: Deleted, Object: obj, FinalStateUnknown: true}, {Type: Deleted, Object: mkFifoObj("foo", 3)}},
+			expected: Deltas[testFifoObject]{{Type: Deleted, Object: mkFifoObj("foo", 3)}},
+		},
+		{
+			name:     "delete then tombstone",
+			in:       Deltas[testFifoObject]{{Type: Deleted, Object: obj}, {Type: Deleted, Object: mkFifoObj("foo", 3), FinalStateUnknown: true}},
+			expected: Deltas[testFifoObject]{{Type: Deleted, Object: obj}},
+		},
+		{
+			name:     "only the last two are compared",
+			in:       Deltas[testFifoObject]{{Type: Deleted, Object: obj}, {Type: Added, Object: obj}, {Type: Deleted, Object: obj}},
+			expected: Deltas[testFifoObject]{{Type: Deleted, Object: obj}, {Type: Added, Object: obj}, {Type: Deleted, Object: obj}},
+		},
+	}
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			if e, a := tt.expected, dedupDeltas(tt.in); !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %#v, got %#v", e, a)
+			}
+		})
+	}
+}
+
+func TestDeltasOldestNewest(t *testing.T) {
+	var empty Deltas[testFifoObject]
+	if empty.Oldest() != nil || empty.Newest() != nil {
+		t.Error("expected no oldest or newest delta of empty Deltas")
+	}
+	d := Deltas[testFifoObject]{{Type: Added, Object: mkFifoObj("foo", 1)}, {Type: Updated, Object: mkFifoObj("foo", 2)}}
+	if e, a := Added, d.Oldest().Type; e != a {
+		t.Errorf("expected oldest %v, got %v", e, a)
+	}
+	if e, a := Updated, d.Newest().Type; e != a {
+		t.Errorf("expected newest %v, got %v", e, a)
+	}
+}
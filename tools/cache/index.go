@@ -0,0 +1,52 @@
+package cache
+
+import (
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+)
+
+// Indexer extends Store with multiple indices and restricts each
+// accumulator to simply hold the current object (rather than a list
+// of object states).
+//
+// There are three kinds of strings here:
+//  1. a storage key, as defined in the Store interface,
+//  2. a name of an index, and
+//  3. an "indexed value", which is produced by an IndexFunc and
+//     can be a field value or any other string computed from the object.
+type Indexer[T any] interface {
+	Store[T]
+	// Index returns the stored objects whose set of indexed values
+	// intersects the set of indexed values of the given object, for
+	// the named index
+	Index(indexName string, obj T) ([]T, error)
+	// IndexKeys returns the storage keys of the stored objects whose
+	// set of indexed values for the named index includes the given
+	// indexed value
+	IndexKeys(indexName, indexedValue string) ([]string, error)
+	// ListIndexFuncValues returns all the indexed values of the given index
+	ListIndexFuncValues(indexName string) []string
+	// ByIndex returns the stored objects whose set of indexed values
+	// for the named index includes the given indexed value
+	ByIndex(indexName, indexedValue string) ([]T, error)
+	// GetIndexers return the indexers
+	GetIndexers() Indexers[T]
+
+	// AddIndexers adds more indexers to this store. This supports adding indexes after the store already has items.
+	AddIndexers(newIndexers Indexers[T]) error
+}
+
+// IndexFunc knows how to compute a set of indexed values for an object.
+type IndexFunc[T any] func(obj T) ([]string, error)
+
+// Indexers maps a name to an IndexFunc
+type Indexers[T any] map[string]IndexFunc[T]
+
+const (
+	// NamespaceIndex is the lookup name for the most common index function, which is to index by the namespace field.
+	NamespaceIndex string = "namespace"
+)
+
+// MetaNamespaceIndexFunc is a default index function that indexes based on an object's namespace
+func MetaNamespaceIndexFunc[T metav1.Object](obj T) ([]string, error) {
+	return []string{obj.GetNamespace()}, nil
+}
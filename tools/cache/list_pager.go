@@ -0,0 +1,95 @@
+package cache
+
+import (
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	"github.com/ForbiddenR/jxutils/clock"
+)
+
+// defaultPageSize is the number of objects a Reflector asks for per page
+// when its page size is not set.
+const defaultPageSize = 500
+
+// listPager lists a collection in pages of at most pageSize objects,
+// following the continue token of each page until the last one, and
+// observes how long each page took.
+type listPager[T any] struct {
+	lister   Lister[T]
+	pageSize int64
+	clock    clock.PassiveClock
+
+	pageDuration HistogramMetric
+}
+
+// list returns the whole collection, and whether more than one page was
+// needed to get it. A server which does not support paging answers the first
+// page with everything and no continue token, which ends the list as well.
+// If the continue token expires half way, because the snapshot the pages are
+// read from is too old, the collection is listed again in full at the
+// originally requested resource version, once. Listing the pages again could
+// outlive the new continue token just the same. The list is abandoned with
+// errorStopRequested if stopCh is closed between two requests.
+func (p *listPager[T]) list(options metav1.ListOptions, stopCh <-chan struct{}) (*ListResult[T], bool, error) {
+	if options.Limit == 0 {
+		options.Limit = p.pageSize
+	}
+	requestedResourceVersion := options.ResourceVersion
+	var items []T
+	paginated := false
+	for {
+		page, err := p.listPage(options)
+		if err != nil {
+			if !IsExpiredError(err) || !paginated {
+				return nil, paginated, err
+			}
+			if stopped(stopCh) {
+				return nil, paginated, errorStopRequested
+			}
+			// The list expired while we were processing, fall back to a
+			// full list at the requested resource version.
+			options.Limit = 0
+			options.Continue = ""
+			options.ResourceVersion = requestedResourceVersion
+			result, err := p.listPage(options)
+			return result, true, err
+		}
+
+		// The first page is the whole list if there is no continue
+		// token, don't copy the items then.
+		if len(page.Continue) == 0 && !paginated {
+			return page, false, nil
+		}
+
+		items = append(items, page.Items...)
+		if len(page.Continue) == 0 {
+			return &ListResult[T]{ListMeta: page.ListMeta, Items: items}, true, nil
+		}
+		paginated = true
+
+		// Set the next loop up. The continue token carries the resource
+		// version of the first page, which must not be set along with it.
+		options.Continue = page.Continue
+		options.ResourceVersion = ""
+		if stopped(stopCh) {
+			return nil, paginated, errorStopRequested
+		}
+	}
+}
+
+// stopped reports whether stopCh is closed.
+func stopped(stopCh <-chan struct{}) bool {
+	select {
+	case <-stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// listPage lists one page, observing how long it took.
+func (p *listPager[T]) listPage(options metav1.ListOptions) (*ListResult[T], error) {
+	start := p.clock.Now()
+	defer func() {
+		p.pageDuration.Observe(p.clock.Since(start).Seconds())
+	}()
+	return p.lister.List(options)
+}
@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	"github.com/ForbiddenR/jxclient-go/internal/clocktesting"
+	apierrors "github.com/ForbiddenR/jxclient-go/pkg/api/errors"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
+)
+
+// pagingLister serves count objects at resourceVersion in pages of the
+// requested limit, with continue tokens holding the offset of the next page.
+type pagingLister struct {
+	count           int
+	resourceVersion string
+	// ignoreLimit makes the lister behave like a server which does not
+	// support paging.
+	ignoreLimit bool
+	// expireAt is the offset whose continue token is reported expired.
+	expireAt int
+	// onList is optionally called after each page is served.
+	onList func()
+
+	lock    sync.Mutex
+	options []metav1.ListOptions
+}
+
+func (l *pagingLister) List(options metav1.ListOptions) (*ListResult[*testObject], error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.options = append(l.options, options)
+	if l.onList != nil {
+		defer l.onList()
+	}
+
+	start := 0
+	if options.Continue != "" {
+		offset, err := strconv.Atoi(options.Continue)
+		if err != nil {
+			return nil, err
+		}
+		if offset == l.expireAt {
+			return nil, apierrors.NewResourceExpired("the continue token is too old")
+		}
+		start = offset
+	}
+	end := l.count
+	if options.Limit > 0 && !l.ignoreLimit && start+int(options.Limit) < end {
+		end = start + int(options.Limit)
+	}
+	result := listResult(l.resourceVersion)
+	for i := start; i < end; i++ {
+		result.Items = append(result.Items, newTestObject("", fmt.Sprintf("item-%03d", i), l.resourceVersion, ""))
+	}
+	if end < l.count {
+		result.Continue = strconv.Itoa(end)
+	}
+	return result, nil
+}
+
+func (l *pagingLister) requests() []metav1.ListOptions {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]metav1.ListOptions(nil), l.options...)
+}
+
+// fakeHistogram records its observations.
+type fakeHistogram struct {
+	lock         sync.Mutex
+	observations []float64
+}
+
+func (h *fakeHistogram) Observe(v float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.observations = append(h.observations, v)
+}
+
+func (h *fakeHistogram) observed() []float64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]float64(nil), h.observations...)
+}
+
+type fakeReflectorMetrics struct {
+	pageDuration, listedItems fakeHistogram
+}
+
+func (m *fakeReflectorMetrics) NewListPageDurationMetric(name string) HistogramMetric {
+	return &m.pageDuration
+}
+
+func (m *fakeReflectorMetrics) NewListedItemsMetric(name string) HistogramMetric {
+	return &m.listedItems
+}
+
+func newTestPager(lister Lister[*testObject], pageSize int64) (*listPager[*testObject], *fakeHistogram) {
+	pageDuration := &fakeHistogram{}
+	return &listPager[*testObject]{
+		lister:       lister,
+		pageSize:     pageSize,
+		clock:        clocktesting.NewFakeClock(time.Now()),
+		pageDuration: pageDuration,
+	}, pageDuration
+}
+
+func TestListPager(t *testing.T) {
+	lister := &pagingLister{count: 25, resourceVersion: "7", expireAt: -1}
+	pager, pageDuration := newTestPager(lister, 10)
+
+	list, paginated, err := pager.list(metav1.ListOptions{ResourceVersion: "0"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !paginated {
+		t.Error("expected the list to be paginated")
+	}
+	if e, a := 25, len(list.Items); e != a {
+		t.Fatalf("expected %d items, got %d", e, a)
+	}
+	for i, item := range list.Items {
+		if e, a := fmt.Sprintf("item-%03d", i), item.Name; e != a {
+			t.Errorf("expected item %d to be %s, got %s", i, e, a)
+		}
+	}
+	if e, a := "7", list.ResourceVersion; e != a {
+		t.Errorf("expected resource version %q, got %q", e, a)
+	}
+	if list.Continue != "" {
+		t.Errorf("expected no continue token on the result, got %q", list.Continue)
+	}
+
+	// Only the first page is read at the requested resource version, the
+	// others continue from the previous page.
+	expected := []metav1.ListOptions{
+		{ResourceVersion: "0", Limit: 10},
+		{Limit: 10, Continue: "10"},
+		{Limit: 10, Continue: "20"},
+	}
+	if a := lister.requests(); !reflect.DeepEqual(expected, a) {
+		t.Errorf("expected requests %+v, got %+v", expected, a)
+	}
+	if e, a := 3, len(pageDuration.observed()); e != a {
+		t.Errorf("expected %d page durations, got %d", e, a)
+	}
+}
+
+func TestListPagerUnsupported(t *testing.T) {
+	lister := &pagingLister{count: 25, resourceVersion: "7", ignoreLimit: true, expireAt: -1}
+	pager, pageDuration := newTestPager(lister, 10)
+
+	list, paginated, err := pager.list(metav1.ListOptions{ResourceVersion: "0"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if paginated {
+		t.Error("expected the list not to be paginated")
+	}
+	if e, a := 25, len(list.Items); e != a {
+		t.Errorf("expected %d items, got %d", e, a)
+	}
+	if e, a := 1, len(lister.requests()); e != a {
+		t.Errorf("expected %d requests, got %d", e, a)
+	}
+	if e, a := 1, len(pageDuration.observed()); e != a {
+		t.Errorf("expected %d page durations, got %d", e, a)
+	}
+}
+
+func TestListPagerContinueExpired(t *testing.T) {
+	lister := &pagingLister{count: 25, resourceVersion: "7", expireAt: 20}
+	pager, _ := newTestPager(lister, 10)
+
+	list, paginated, err := pager.list(metav1.ListOptions{ResourceVersion: "5"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !paginated {
+		t.Error("expected the list to be paginated")
+	}
+	if e, a := 25, len(list.Items); e != a {
+		t.Errorf("expected %d items, got %d", e, a)
+	}
+	for i, item := range list.Items {
+		if e, a := fmt.Sprintf("item-%03d", i), item.Name; e != a {
+			t.Errorf("expected item %d to be %s, got %s", i, e, a)
+		}
+	}
+	// The full list is requested once at the original resource version,
+	// even though the continue token would expire again.
+	expected := []metav1.ListOptions{
+		{ResourceVersion: "5", Limit: 10},
+		{Limit: 10, Continue: "10"},
+		{Limit: 10, Continue: "20"},
+		{ResourceVersion: "5"},
+	}
+	if a := lister.requests(); !reflect.DeepEqual(expected, a) {
+		t.Errorf("expected requests %+v, got %+v", expected, a)
+	}
+}
+
+func TestListPagerStopped(t *testing.T) {
+	stopCh := make(chan struct{})
+	lister := &pagingLister{count: 25, resourceVersion: "7", expireAt: -1}
+	lister.onList = func() { close(stopCh) }
+	pager, _ := newTestPager(lister, 10)
+
+	if _, _, err := pager.list(metav1.ListOptions{ResourceVersion: "0"}, stopCh); err != errorStopRequested {
+		t.Errorf("expected the list to be stopped, got %v", err)
+	}
+	if e, a := 1, len(lister.requests()); e != a {
+		t.Errorf("expected %d requests, got %d", e, a)
+	}
+}
+
+func TestListPagerKeepsLabelSelector(t *testing.T) {
+	lister := &pagingLister{count: 25, resourceVersion: "7", expireAt: 20}
+	pager, _ := newTestPager(lister, 10)
+
+	if _, _, err := pager.list(metav1.ListOptions{ResourceVersion: "5", LabelSelector: "app=web"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Every page, and the full relist, are restricted by the selector.
+	for i, options := range lister.requests() {
+		if e, a := "app=web", options.LabelSelector; e != a {
+			t.Errorf("expected request %d to select %q, got %q", i, e, a)
+		}
+	}
+}
+
+func TestListPagerFirstPageExpired(t *testing.T) {
+	lister := ListWatch[*testObject]{
+		ListFunc: func(metav1.ListOptions) (*ListResult[*testObject], error) {
+			return nil, apierrors.NewResourceExpired("too old resource version")
+		},
+	}
+	pager, _ := newTestPager(&lister, 10)
+	// The resource version itself is too old, there is no list to fall
+	// back to.
+	if _, _, err := pager.list(metav1.ListOptions{ResourceVersion: "5"}, nil); !apierrors.IsResourceExpired(err) {
+		t.Errorf("expected an expired error, got %v", err)
+	}
+}
+
+func TestReflectorListPaginated(t *testing.T) {
+	lister := &pagingLister{count: 1200, resourceVersion: "3", expireAt: -1}
+	lw := &ListWatch[*testObject]{
+		ListFunc: lister.List,
+		WatchFunc: func(metav1.ListOptions) (watch.Interface[*testObject], error) {
+			return watch.NewFake[*testObject](), nil
+		},
+	}
+	metrics := &fakeReflectorMetrics{}
+	store := NewStore(MetaNamespaceKeyFunc[*testObject])
+	r := NewReflectorWithOptions[*testObject](lw, &testObject{}, store, ReflectorOptions{MetricsProvider: metrics})
+
+	stopCh := make(chan struct{})
+	lw.WatchFunc = func(metav1.ListOptions) (watch.Interface[*testObject], error) {
+		close(stopCh)
+		return watch.NewFake[*testObject](), nil
+	}
+	if err := r.ListAndWatch(stopCh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 1200, len(store.ListKeys()); e != a {
+		t.Errorf("expected %d items in the store, got %d", e, a)
+	}
+	// The default page size is 500.
+	if e, a := 3, len(lister.requests()); e != a {
+		t.Errorf("expected %d pages, got %d", e, a)
+	}
+	if e, a := 3, len(metrics.pageDuration.observed()); e != a {
+		t.Errorf("expected %d page durations, got %d", e, a)
+	}
+	if e, a := []float64{1200}, metrics.listedItems.observed(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected listed items %v, got %v", e, a)
+	}
+	if e, a := "3", r.LastSyncResourceVersion(); e != a {
+		t.Errorf("expected last sync resource version %q, got %q", e, a)
+	}
+}
+
+func TestReflectorListStopped(t *testing.T) {
+	stopCh := make(chan struct{})
+	lister := &pagingLister{count: 1200, resourceVersion: "3", expireAt: -1}
+	lister.onList = func() { close(stopCh) }
+	lw := &ListWatch[*testObject]{
+		ListFunc: lister.List,
+		WatchFunc: func(metav1.ListOptions) (watch.Interface[*testObject], error) {
+			t.Error("unexpected watch")
+			return watch.NewFake[*testObject](), nil
+		},
+	}
+	store := NewStore(MetaNamespaceKeyFunc[*testObject])
+	r := NewReflector[*testObject](lw, &testObject{}, store, 0)
+
+	if err := r.ListAndWatch(stopCh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 1, len(lister.requests()); e != a {
+		t.Errorf("expected %d pages, got %d", e, a)
+	}
+	if keys := store.ListKeys(); len(keys) != 0 {
+		t.Errorf("expected the store to stay empty, got %d items", len(keys))
+	}
+	if e, a := "", r.LastSyncResourceVersion(); e != a {
+		t.Errorf("expected last sync resource version %q, got %q", e, a)
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"log"
+
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	"github.com/ForbiddenR/jxclient-go/pkg/labels"
+)
+
+// ListAll returns the objects of store whose labels match selector. Use
+// labels.Everything() to list all the objects.
+func ListAll[T metav1.Object](store Store[T], selector labels.Selector) []T {
+	return filterBySelector(store.List(), selector)
+}
+
+// ListAllByNamespace returns the objects of indexer in namespace whose labels
+// match selector. It uses the NamespaceIndex when the indexer has one, and
+// falls back to scanning every object otherwise.
+func ListAllByNamespace[T metav1.Object](indexer Indexer[T], namespace string, selector labels.Selector) []T {
+	if namespace == metav1.NamespaceAll {
+		return ListAll[T](indexer, selector)
+	}
+
+	items, err := indexer.ByIndex(NamespaceIndex, namespace)
+	if err != nil {
+		// Ignore error; do slow search without index.
+		log.Printf("cache: can not retrieve list of objects using index: %v", err)
+		for _, obj := range indexer.List() {
+			if obj.GetNamespace() == namespace {
+				items = append(items, obj)
+			}
+		}
+	}
+	return filterBySelector(items, selector)
+}
+
+// ListAllByIndex returns the objects of indexer whose indexed values for the
+// named index include indexedValue and whose labels match selector. It
+// returns an error if the indexer has no such index.
+func ListAllByIndex[T metav1.Object](indexer Indexer[T], indexName, indexedValue string, selector labels.Selector) ([]T, error) {
+	items, err := indexer.ByIndex(indexName, indexedValue)
+	if err != nil {
+		return nil, err
+	}
+	return filterBySelector(items, selector), nil
+}
+
+func filterBySelector[T metav1.Object](items []T, selector labels.Selector) []T {
+	if selector.Empty() {
+		return items
+	}
+	ret := make([]T, 0, len(items))
+	for _, obj := range items {
+		if selector.Matches(labels.Set(obj.GetLabels())) {
+			ret = append(ret, obj)
+		}
+	}
+	return ret
+}
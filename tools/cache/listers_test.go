@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ForbiddenR/jxclient-go/pkg/labels"
+)
+
+func newLabeledObject(namespace, name string, objLabels map[string]string) *testObject {
+	obj := newTestObject(namespace, name, "1", "")
+	obj.Labels = objLabels
+	return obj
+}
+
+func objectKeys(objs []*testObject) []string {
+	keys := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		key, _ := MetaNamespaceKeyFunc(obj)
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newListersTestIndexer(indexers Indexers[*testObject]) Indexer[*testObject] {
+	indexer := NewIndexer(MetaNamespaceKeyFunc[*testObject], indexers)
+	indexer.Add(newLabeledObject("ns1", "a", map[string]string{"app": "x"}))
+	indexer.Add(newLabeledObject("ns1", "b", map[string]string{"app": "y"}))
+	indexer.Add(newLabeledObject("ns2", "c", map[string]string{"app": "x"}))
+	indexer.Add(newLabeledObject("", "d", nil))
+	return indexer
+}
+
+func TestListAll(t *testing.T) {
+	indexer := newListersTestIndexer(nil)
+	if e, a := []string{"d", "ns1/a", "ns1/b", "ns2/c"}, objectKeys(ListAll[*testObject](indexer, labels.Everything())); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := []string{"ns1/a", "ns2/c"}, objectKeys(ListAll[*testObject](indexer, labels.SelectorFromSet(labels.Set{"app": "x"}))); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestListAllByNamespace(t *testing.T) {
+	for name, indexers := range map[string]Indexers[*testObject]{
+		"indexed":   {NamespaceIndex: MetaNamespaceIndexFunc[*testObject]},
+		"unindexed": nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			indexer := newListersTestIndexer(indexers)
+			for _, tc := range []struct {
+				namespace string
+				selector  labels.Selector
+				expected  []string
+			}{
+				{namespace: "ns1", selector: labels.Everything(), expected: []string{"ns1/a", "ns1/b"}},
+				{namespace: "ns1", selector: labels.SelectorFromSet(labels.Set{"app": "y"}), expected: []string{"ns1/b"}},
+				{namespace: "ns3", selector: labels.Everything(), expected: []string{}},
+				{namespace: "", selector: labels.SelectorFromSet(labels.Set{"app": "x"}), expected: []string{"ns1/a", "ns2/c"}},
+			} {
+				if a := objectKeys(ListAllByNamespace(indexer, tc.namespace, tc.selector)); !reflect.DeepEqual(tc.expected, a) {
+					t.Errorf("namespace %q, selector %q: expected %v, got %v", tc.namespace, tc.selector, tc.expected, a)
+				}
+			}
+		})
+	}
+}
+
+func TestListAllByIndex(t *testing.T) {
+	indexer := newListersTestIndexer(Indexers[*testObject]{"app": func(obj *testObject) ([]string, error) {
+		return []string{obj.Labels["app"]}, nil
+	}})
+	objs, err := ListAllByIndex(indexer, "app", "x", labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := []string{"ns1/a", "ns2/c"}, objectKeys(objs); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if _, err := ListAllByIndex(indexer, "missing", "x", labels.Everything()); err == nil {
+		t.Error("expected an error for a missing index")
+	}
+}
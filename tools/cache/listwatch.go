@@ -0,0 +1,59 @@
+package cache
+
+import (
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
+)
+
+// ListResult is what a ListerWatcher lists: the objects and the resource
+// version of the collection they were read at.
+type ListResult[T any] struct {
+	metav1.ListMeta
+
+	Items []T
+}
+
+// Lister is any object that knows how to perform an initial list.
+type Lister[T any] interface {
+	// List should return a list type object; the Items field will be extracted, and the
+	// ResourceVersion field will be used to start the watch in the right place.
+	List(options metav1.ListOptions) (*ListResult[T], error)
+}
+
+// Watcher is any object that knows how to start a watch on a resource.
+type Watcher[T any] interface {
+	// Watch should begin a watch at the specified version.
+	Watch(options metav1.ListOptions) (watch.Interface[T], error)
+}
+
+// ListerWatcher is any object that knows how to perform an initial list and start a watch on a resource.
+type ListerWatcher[T any] interface {
+	Lister[T]
+	Watcher[T]
+}
+
+// ListFunc knows how to list resources
+type ListFunc[T any] func(options metav1.ListOptions) (*ListResult[T], error)
+
+// WatchFunc knows how to watch resources
+type WatchFunc[T any] func(options metav1.ListOptions) (watch.Interface[T], error)
+
+// ListWatch knows how to list and watch a set of resources. It satisfies the ListerWatcher interface.
+// It is a convenience function for users of NewSharedIndexInformer and the like.
+// ListFunc and WatchFunc must not be nil
+type ListWatch[T any] struct {
+	ListFunc  ListFunc[T]
+	WatchFunc WatchFunc[T]
+}
+
+var _ ListerWatcher[any] = &ListWatch[any]{}
+
+// List a set of apiserver resources
+func (lw *ListWatch[T]) List(options metav1.ListOptions) (*ListResult[T], error) {
+	return lw.ListFunc(options)
+}
+
+// Watch a set of apiserver resources
+func (lw *ListWatch[T]) Watch(options metav1.ListOptions) (watch.Interface[T], error) {
+	return lw.WatchFunc(options)
+}
@@ -0,0 +1,68 @@
+package cache
+
+// This file provides abstractions for setting the provider (e.g., prometheus)
+// of metrics.
+
+// SettableGaugeMetric represents a single numerical value that can arbitrarily go up
+// and down.
+type SettableGaugeMetric interface {
+	Set(float64)
+}
+
+// CounterMetric represents a single numerical value that only ever
+// goes up.
+type CounterMetric interface {
+	Inc()
+}
+
+// HistogramMetric counts individual observations.
+type HistogramMetric interface {
+	Observe(float64)
+}
+
+// ListenerMetricsProvider generates the metrics of an event handler
+// registration.
+type ListenerMetricsProvider interface {
+	// NewPendingNotificationsMetric returns the gauge of notifications
+	// waiting for the handler.
+	NewPendingNotificationsMetric(name string) SettableGaugeMetric
+	// NewDroppedNotificationsMetric returns the counter of notifications
+	// discarded because the handler fell behind.
+	NewDroppedNotificationsMetric(name string) CounterMetric
+}
+
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
+
+type noopListenerMetricsProvider struct{}
+
+func (noopListenerMetricsProvider) NewPendingNotificationsMetric(name string) SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (noopListenerMetricsProvider) NewDroppedNotificationsMetric(name string) CounterMetric {
+	return noopMetric{}
+}
+
+// ReflectorMetricsProvider generates the metrics of a Reflector.
+type ReflectorMetricsProvider interface {
+	// NewListPageDurationMetric returns the histogram of how long, in
+	// seconds, each page of a list took.
+	NewListPageDurationMetric(name string) HistogramMetric
+	// NewListedItemsMetric returns the histogram of how many items each
+	// complete list returned.
+	NewListedItemsMetric(name string) HistogramMetric
+}
+
+type noopReflectorMetricsProvider struct{}
+
+func (noopReflectorMetricsProvider) NewListPageDurationMetric(name string) HistogramMetric {
+	return noopMetric{}
+}
+
+func (noopReflectorMetricsProvider) NewListedItemsMetric(name string) HistogramMetric {
+	return noopMetric{}
+}
@@ -0,0 +1,537 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	apierrors "github.com/ForbiddenR/jxclient-go/pkg/api/errors"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
+	"github.com/ForbiddenR/jxutils/clock"
+)
+
+const (
+	// defaultInitialBackoff is how long a Reflector waits after its first
+	// failed list and watch.
+	defaultInitialBackoff = 800 * time.Millisecond
+	// defaultMaxBackoff caps the wait between failed lists and watches.
+	defaultMaxBackoff = 30 * time.Second
+	// backoffResetDuration is how long a Reflector must go without failing
+	// for its backoff to start over from the initial one.
+	backoffResetDuration = 2 * time.Minute
+
+	// minWatchTimeout is the shortest timeout requested for a watch. Each
+	// watch asks for a random timeout in [minWatchTimeout, 2*minWatchTimeout)
+	// so that the watches of many reflectors do not all end together.
+	minWatchTimeout = 5 * time.Minute
+	// minWatchDuration is how long a watch must last, or how many events it
+	// must deliver, not to be considered broken.
+	minWatchDuration = time.Second
+)
+
+var (
+	// errorStopRequested is returned by the watch handler when stopCh is closed.
+	errorStopRequested = errors.New("stop requested")
+	// errorVeryShortWatch is returned when a watch ended right away without
+	// delivering anything, which is usually a sign of a broken server.
+	errorVeryShortWatch = errors.New("very short watch")
+)
+
+// ReflectorStore is the subset of a Store which a Reflector writes to. Both
+// Store and DeltaFIFO implement it.
+type ReflectorStore[T any] interface {
+	// Add adds the given object to the accumulator associated with the given object's key
+	Add(obj T) error
+
+	// Update updates the given object in the accumulator associated with the given object's key
+	Update(obj T) error
+
+	// Delete deletes the given object from the accumulator associated with the given object's key
+	Delete(obj T) error
+
+	// Replace will delete the contents of the store, using instead the
+	// given list. Store takes ownership of the list, you should not reference
+	// it after calling this function.
+	Replace(list []T, resourceVersion string) error
+
+	// Resync is meaningless in the terms appearing here but has
+	// meaning in some implementations that have non-trivial
+	// additional behavior (e.g., DeltaFIFO).
+	Resync() error
+}
+
+var (
+	_ ReflectorStore[any] = Store[any](nil)
+	_ ReflectorStore[any] = &DeltaFIFO[any]{}
+)
+
+// WatchErrorHandler is called whenever ListAndWatch drops the connection with
+// an error, and whenever a watch ends because the resource version it
+// resumed from expired. After calling it the reflector lists and watches
+// again, possibly after a backoff.
+type WatchErrorHandler[T metav1.Object] func(r *Reflector[T], err error)
+
+// DefaultWatchErrorHandler is the default implementation of
+// WatchErrorHandler. It logs err, telling expected disconnects apart from
+// failures which need attention.
+func DefaultWatchErrorHandler[T metav1.Object](r *Reflector[T], err error) {
+	switch {
+	case IsExpiredError(err):
+		// Don't log this as an error: it happens regularly when a watch is
+		// resumed after the server compacted its history.
+		log.Printf("cache: %s: watch of %s closed with: %v", r.name, r.typeDescription, err)
+	case errors.Is(err, io.EOF):
+		// The watch closed normally.
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		log.Printf("cache: %s: watch for %s closed with unexpected EOF: %v", r.name, r.typeDescription, err)
+	case apierrors.IsUnauthorized(err):
+		log.Printf("cache: %s: failed to authenticate to list and watch %s: %v", r.name, r.typeDescription, err)
+	case apierrors.IsForbidden(err):
+		log.Printf("cache: %s: not authorized to list and watch %s: %v", r.name, r.typeDescription, err)
+	default:
+		log.Printf("cache: %s: failed to list and watch %s: %v", r.name, r.typeDescription, err)
+	}
+}
+
+// Reflector watches a specified resource and causes all changes to be reflected in the given store.
+type Reflector[T metav1.Object] struct {
+	// name identifies this reflector.
+	name string
+	// typeDescription describes the objects the reflector lists and watches.
+	typeDescription string
+	// The destination to sync up with the watch source
+	store ReflectorStore[T]
+	// listerWatcher is used to perform lists and watches.
+	listerWatcher ListerWatcher[T]
+	// pager lists the listerWatcher page by page.
+	pager *listPager[T]
+	// listedItems observes the size of every complete list.
+	listedItems HistogramMetric
+	// backoff manages the waits between the list and watch attempts of Run.
+	backoff *backoff
+	// clock allows for testability
+	clock clock.Clock
+	// resyncPeriod is how often the store is resynced, zero means never.
+	resyncPeriod time.Duration
+	// ShouldResync is invoked periodically and whenever it returns `true` the Store's Resync operation is invoked.
+	// A nil ShouldResync resyncs every resyncPeriod.
+	ShouldResync func() bool
+	// WatchErrorHandler is called whenever ListAndWatch drops the
+	// connection with an error. A nil WatchErrorHandler uses
+	// DefaultWatchErrorHandler.
+	WatchErrorHandler WatchErrorHandler[T]
+	// lastSyncResourceVersion is the resource version token last
+	// observed when doing a sync with the underlying store
+	// it is thread safe, but not synchronized with the underlying store
+	lastSyncResourceVersion string
+	// isLastSyncResourceVersionUnavailable is true if the previous list or watch request with
+	// lastSyncResourceVersion failed with an "expired" or "too large resource version" error.
+	isLastSyncResourceVersionUnavailable bool
+	// lastSyncResourceVersionMutex guards read/write access to lastSyncResourceVersion
+	lastSyncResourceVersionMutex sync.RWMutex
+}
+
+// ReflectorOptions configures a Reflector.
+type ReflectorOptions struct {
+	// Name is the Reflector's name. If unset/unspecified, the name defaults to
+	// the description of the expected type.
+	Name string
+
+	// ResyncPeriod is the Reflector's resync period. If unset/unspecified, the resync period defaults to 0
+	// (do not resync).
+	ResyncPeriod time.Duration
+
+	// InitialBackoff is how long the Reflector waits after a failed list and
+	// watch, doubling after each consecutive failure up to MaxBackoff. If
+	// unset/unspecified, they default to 800ms and 30s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// PageSize is the number of objects requested per page when listing.
+	// Servers which do not support paging answer with the full list
+	// regardless. If unset/unspecified, it defaults to 500.
+	PageSize int64
+
+	// MetricsProvider generates the metrics of the Reflector, registered
+	// under its name. If unset/unspecified, no metrics are recorded.
+	MetricsProvider ReflectorMetricsProvider
+
+	// Clock allows tests to control time. If unset defaults to clock.RealClock{}
+	Clock clock.Clock
+}
+
+// NewReflector creates a new Reflector with its name defaulted to the
+// description of expectedType. The indicated `resyncPeriod` is used as in
+// NewReflectorWithOptions.
+func NewReflector[T metav1.Object](lw ListerWatcher[T], expectedType T, store ReflectorStore[T], resyncPeriod time.Duration) *Reflector[T] {
+	return NewReflectorWithOptions(lw, expectedType, store, ReflectorOptions{ResyncPeriod: resyncPeriod})
+}
+
+// NewReflectorWithOptions creates a new Reflector object which will keep the
+// given store up to date with the server's contents for the given
+// resource. Reflector promises to only put things in the store that
+// have the type of expectedType. If resyncPeriod is non-zero, then the
+// reflector will periodically consult its ShouldResync function to
+// determine whether to invoke the Store's Resync operation;
+// `ShouldResync==nil` means always "yes".  This enables you to use
+// reflectors to periodically process everything as well as
+// incrementally processing the things that change.
+func NewReflectorWithOptions[T metav1.Object](lw ListerWatcher[T], expectedType T, store ReflectorStore[T], options ReflectorOptions) *Reflector[T] {
+	reflectorClock := options.Clock
+	if reflectorClock == nil {
+		reflectorClock = &clock.RealClock{}
+	}
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = defaultInitialBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultMaxBackoff
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = defaultPageSize
+	}
+	if options.MetricsProvider == nil {
+		options.MetricsProvider = noopReflectorMetricsProvider{}
+	}
+	typeDescription := fmt.Sprintf("%T", expectedType)
+	if options.Name == "" {
+		options.Name = typeDescription
+	}
+	return &Reflector[T]{
+		name:            options.Name,
+		typeDescription: typeDescription,
+		store:           store,
+		listerWatcher:   lw,
+		pager: &listPager[T]{
+			lister:       lw,
+			pageSize:     options.PageSize,
+			clock:        reflectorClock,
+			pageDuration: options.MetricsProvider.NewListPageDurationMetric(options.Name),
+		},
+		listedItems: options.MetricsProvider.NewListedItemsMetric(options.Name),
+		backoff: &backoff{
+			initial: options.InitialBackoff,
+			max:     options.MaxBackoff,
+			reset:   backoffResetDuration,
+			clock:   reflectorClock,
+		},
+		clock:        reflectorClock,
+		resyncPeriod: options.ResyncPeriod,
+	}
+}
+
+// Run repeatedly uses the reflector's ListAndWatch to fetch all the
+// objects and subsequent deltas.
+// Run will exit when stopCh is closed.
+func (r *Reflector[T]) Run(stopCh <-chan struct{}) {
+	for {
+		if err := r.ListAndWatch(stopCh); err != nil {
+			r.handleWatchError(err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-r.clock.After(r.backoff.next()):
+		}
+	}
+}
+
+// ListAndWatch first lists all items and get the resource version at the moment of call,
+// and then use the resource version to watch.
+// It returns error if ListAndWatch didn't even try to initialize watch.
+func (r *Reflector[T]) ListAndWatch(stopCh <-chan struct{}) error {
+	if err := r.list(stopCh); err != nil {
+		if err == errorStopRequested {
+			return nil
+		}
+		return err
+	}
+
+	resyncerrc := make(chan error, 1)
+	cancelCh := make(chan struct{})
+	defer close(cancelCh)
+	go r.startResync(stopCh, cancelCh, resyncerrc)
+	return r.watch(stopCh, resyncerrc)
+}
+
+// list simply lists all items and records a resource version obtained from the server at the moment of the call.
+// the resource version can be used for further progress notification (aka. watch).
+// Large collections are listed in pages, see ReflectorOptions.PageSize.
+// It returns errorStopRequested if stopCh is closed before the list is
+// complete.
+func (r *Reflector[T]) list(stopCh <-chan struct{}) error {
+	start := r.clock.Now()
+	options := metav1.ListOptions{ResourceVersion: r.relistResourceVersion()}
+	list, paginated, err := r.pager.list(options, stopCh)
+	if IsExpiredError(err) {
+		// The resource version we listed at is not available anymore,
+		// fall back to a consistent read from the server.
+		r.setIsLastSyncResourceVersionUnavailable(true)
+		list, paginated, err = r.pager.list(metav1.ListOptions{ResourceVersion: r.relistResourceVersion()}, stopCh)
+	}
+	if err == errorStopRequested {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", r.typeDescription, err)
+	}
+	r.setIsLastSyncResourceVersionUnavailable(false) // list was successful
+	r.listedItems.Observe(float64(len(list.Items)))
+	if paginated {
+		log.Printf("cache: %s: listed %d %s in pages of %d in %v", r.name, len(list.Items), r.typeDescription, r.pager.pageSize, r.clock.Since(start))
+	}
+
+	if err := r.store.Replace(list.Items, list.ResourceVersion); err != nil {
+		return fmt.Errorf("unable to sync list result: %w", err)
+	}
+	r.setLastSyncResourceVersion(list.ResourceVersion)
+	return nil
+}
+
+// watch simply starts a watch request with the server, resuming from the
+// last synced resource version whenever a watch ends. It returns nil when
+// the watch expired and a new list is needed, or when stopCh is closed.
+func (r *Reflector[T]) watch(stopCh <-chan struct{}, resyncerrc chan error) error {
+	for {
+		// give the stopCh a chance to stop the loop, even in case of continue statements further down on errors
+		select {
+		case <-stopCh:
+			return nil
+		default:
+		}
+
+		timeoutSeconds := int64(minWatchTimeout.Seconds() * (rand.Float64() + 1.0))
+		options := metav1.ListOptions{
+			ResourceVersion: r.LastSyncResourceVersion(),
+			// We want to avoid situations of hanging watchers. Stop any watchers that do not
+			// receive any events within the timeout window.
+			TimeoutSeconds: &timeoutSeconds,
+		}
+
+		w, err := r.listerWatcher.Watch(options)
+		if err != nil {
+			if apierrors.IsTooManyRequests(err) {
+				// The server asked us to slow down, try watching
+				// again once the backoff has passed.
+				select {
+				case <-stopCh:
+					return nil
+				case <-r.clock.After(r.backoff.next()):
+					continue
+				}
+			}
+			if IsExpiredError(err) {
+				r.setIsLastSyncResourceVersionUnavailable(true)
+				r.handleWatchError(err)
+				return nil
+			}
+			return fmt.Errorf("failed to watch %s: %w", r.typeDescription, err)
+		}
+
+		err = r.watchHandler(w, stopCh, resyncerrc)
+		w.Stop()
+		switch {
+		case err == nil:
+			// The watch ended, resume it from where it left off.
+		case err == errorStopRequested:
+			return nil
+		case IsExpiredError(err):
+			// Don't set LastSyncResourceVersionUnavailable just yet: the
+			// watch may have moved past the list, so relisting from the
+			// last resource version seen is tried first.
+			r.handleWatchError(err)
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// handleWatchError hands err to the WatchErrorHandler.
+func (r *Reflector[T]) handleWatchError(err error) {
+	if r.WatchErrorHandler != nil {
+		r.WatchErrorHandler(r, err)
+		return
+	}
+	DefaultWatchErrorHandler(r, err)
+}
+
+// startResync periodically calls r.store.Resync() method.
+// Note that this method is blocking and should be
+// called in a separate goroutine.
+func (r *Reflector[T]) startResync(stopCh <-chan struct{}, cancelCh <-chan struct{}, resyncerrc chan error) {
+	resyncCh, cleanup := r.resyncChan()
+	defer func() {
+		cleanup() // Call the last one written into cleanup
+	}()
+	for {
+		select {
+		case <-resyncCh:
+		case <-stopCh:
+			return
+		case <-cancelCh:
+			return
+		}
+		if r.ShouldResync == nil || r.ShouldResync() {
+			if err := r.store.Resync(); err != nil {
+				resyncerrc <- err
+				return
+			}
+		}
+		cleanup()
+		resyncCh, cleanup = r.resyncChan()
+	}
+}
+
+// resyncChan returns a channel which will receive something when a resync is
+// required, and a cleanup function.
+func (r *Reflector[T]) resyncChan() (<-chan time.Time, func() bool) {
+	if r.resyncPeriod == 0 {
+		return nil, func() bool { return false }
+	}
+	// The cleanup function is required: imagine the scenario where watches
+	// always fail so we end up listing frequently. Then, if we don't
+	// manually stop the timer, we could end up with many timers active
+	// concurrently.
+	t := r.clock.NewTimer(r.resyncPeriod)
+	return t.C(), t.Stop
+}
+
+// watchHandler watches w and sets the last synced resource version.
+func (r *Reflector[T]) watchHandler(w watch.Interface[T], stopCh <-chan struct{}, errc chan error) error {
+	eventCount := 0
+	start := r.clock.Now()
+loop:
+	for {
+		select {
+		case <-stopCh:
+			return errorStopRequested
+		case err := <-errc:
+			return err
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				break loop
+			}
+			if event.Type == watch.Error {
+				return event.Err
+			}
+			var err error
+			switch event.Type {
+			case watch.Added:
+				err = r.store.Add(event.Object)
+			case watch.Modified:
+				err = r.store.Update(event.Object)
+			case watch.Deleted:
+				err = r.store.Delete(event.Object)
+			case watch.Bookmark:
+				// A `Bookmark` means watch has synced here, just update the resourceVersion
+			default:
+				log.Printf("cache: %s: unable to understand watch event %#v", r.name, event)
+				continue
+			}
+			if err != nil {
+				log.Printf("cache: %s: unable to %s watch event object (%#v) to store: %v", r.name, event.Type, event.Object, err)
+			}
+			r.setLastSyncResourceVersion(event.Object.GetResourceVersion())
+			eventCount++
+		}
+	}
+
+	watchDuration := r.clock.Since(start)
+	if watchDuration < minWatchDuration && eventCount == 0 {
+		return fmt.Errorf("%w - watch lasted less than a second and no items received", errorVeryShortWatch)
+	}
+	return nil
+}
+
+// Name returns the name of the reflector.
+func (r *Reflector[T]) Name() string {
+	return r.name
+}
+
+// TypeDescription returns the description of the objects the reflector
+// lists and watches.
+func (r *Reflector[T]) TypeDescription() string {
+	return r.typeDescription
+}
+
+// LastSyncResourceVersion is the resource version observed when last sync with the underlying store
+// The value returned is not synchronized with access to the underlying store and is not thread-safe
+func (r *Reflector[T]) LastSyncResourceVersion() string {
+	r.lastSyncResourceVersionMutex.RLock()
+	defer r.lastSyncResourceVersionMutex.RUnlock()
+	return r.lastSyncResourceVersion
+}
+
+func (r *Reflector[T]) setLastSyncResourceVersion(v string) {
+	r.lastSyncResourceVersionMutex.Lock()
+	defer r.lastSyncResourceVersionMutex.Unlock()
+	r.lastSyncResourceVersion = v
+}
+
+// relistResourceVersion determines the resource version the reflector should list or relist from.
+// Returns either the lastSyncResourceVersion so that this reflector will relist with a resource
+// versions no older than has already been observed in relist results or watch events, or, if the last relist resulted
+// in an HTTP 410 (Gone) status code, returns "" so that the relist will use the latest resource version available
+// on the server.
+func (r *Reflector[T]) relistResourceVersion() string {
+	r.lastSyncResourceVersionMutex.RLock()
+	defer r.lastSyncResourceVersionMutex.RUnlock()
+
+	if r.isLastSyncResourceVersionUnavailable {
+		// The lastSyncResourceVersion is unavailable, we set ResourceVersion="" and list again to re-establish
+		// the reflector to the latest available ResourceVersion, using a consistent read from the server.
+		return ""
+	}
+	if r.lastSyncResourceVersion == "" {
+		// For performance reasons, initial list performed by reflector uses "0" as resource version to allow it to
+		// be served from the server's cache.
+		return "0"
+	}
+	return r.lastSyncResourceVersion
+}
+
+// setIsLastSyncResourceVersionUnavailable sets if the last list or watch request with lastSyncResourceVersion returned
+// "expired" or "too large resource version" error.
+func (r *Reflector[T]) setIsLastSyncResourceVersionUnavailable(isUnavailable bool) {
+	r.lastSyncResourceVersionMutex.Lock()
+	defer r.lastSyncResourceVersionMutex.Unlock()
+	r.isLastSyncResourceVersionUnavailable = isUnavailable
+}
+
+// IsExpiredError reports whether err means the requested resource version is
+// too old. Servers may report it as either Expired or Gone. A
+// WatchErrorHandler can use it to tell the expected expiries apart, which the
+// reflector recovers from by relisting.
+func IsExpiredError(err error) bool {
+	return apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+}
+
+// backoff is an exponential backoff with jitter which starts over from its
+// initial duration once it has not been needed for its reset duration.
+type backoff struct {
+	initial, max, reset time.Duration
+	clock               clock.PassiveClock
+
+	duration         time.Duration
+	lastBackoffStart time.Time
+}
+
+// next returns how long to wait before the next attempt.
+func (b *backoff) next() time.Duration {
+	now := b.clock.Now()
+	if b.duration == 0 || now.Sub(b.lastBackoffStart) > b.reset {
+		b.duration = b.initial
+	} else if b.duration *= 2; b.duration > b.max {
+		b.duration = b.max
+	}
+	b.lastBackoffStart = now
+	// Jitter by up to the duration itself so that reflectors failing
+	// together do not retry together.
+	return b.duration + time.Duration(rand.Float64()*float64(b.duration))
+}
@@ -0,0 +1,459 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	"github.com/ForbiddenR/jxclient-go/internal/clocktesting"
+	apierrors "github.com/ForbiddenR/jxclient-go/pkg/api/errors"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
+)
+
+// testListWatch records the options of every list and watch and answers
+// them with the given functions.
+type testListWatch struct {
+	lock        sync.Mutex
+	listOptions []metav1.ListOptions
+	watchFrom   []string
+
+	listFunc  func(options metav1.ListOptions) (*ListResult[*testObject], error)
+	watchFunc func(options metav1.ListOptions) (watch.Interface[*testObject], error)
+}
+
+func (lw *testListWatch) List(options metav1.ListOptions) (*ListResult[*testObject], error) {
+	lw.lock.Lock()
+	lw.listOptions = append(lw.listOptions, options)
+	lw.lock.Unlock()
+	return lw.listFunc(options)
+}
+
+func (lw *testListWatch) Watch(options metav1.ListOptions) (watch.Interface[*testObject], error) {
+	lw.lock.Lock()
+	lw.watchFrom = append(lw.watchFrom, options.ResourceVersion)
+	lw.lock.Unlock()
+	return lw.watchFunc(options)
+}
+
+func (lw *testListWatch) listedFrom() []string {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	var resourceVersions []string
+	for _, options := range lw.listOptions {
+		resourceVersions = append(resourceVersions, options.ResourceVersion)
+	}
+	return resourceVersions
+}
+
+func (lw *testListWatch) watchedFrom() []string {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	return append([]string(nil), lw.watchFrom...)
+}
+
+func listResult(resourceVersion string, items ...*testObject) *ListResult[*testObject] {
+	return &ListResult[*testObject]{ListMeta: metav1.ListMeta{ResourceVersion: resourceVersion}, Items: items}
+}
+
+func storeKeys(store Store[*testObject]) []string {
+	keys := store.ListKeys()
+	sort.Strings(keys)
+	return keys
+}
+
+func TestReflectorListAndWatch(t *testing.T) {
+	watchers := make(chan *watch.FakeWatcher[*testObject], 2)
+	lw := &testListWatch{
+		listFunc: func(metav1.ListOptions) (*ListResult[*testObject], error) {
+			return listResult("1", newTestObject("", "a", "1", "x")), nil
+		},
+		watchFunc: func(metav1.ListOptions) (watch.Interface[*testObject], error) {
+			w := watch.NewFake[*testObject]()
+			watchers <- w
+			return w, nil
+		},
+	}
+	store := NewStore(MetaNamespaceKeyFunc[*testObject])
+	r := NewReflector[*testObject](lw, &testObject{}, store, 0)
+
+	stopCh := make(chan struct{})
+	errCh := make(chan error)
+	go func() { errCh <- r.ListAndWatch(stopCh) }()
+
+	w := <-watchers
+	w.Add(newTestObject("", "b", "2", "y"))
+	w.Modify(newTestObject("", "a", "3", "z"))
+	w.Delete(newTestObject("", "b", "4", "y"))
+	// The watch ends, it is resumed from the last resource version
+	// without listing again.
+	w.Stop()
+	w = <-watchers
+	w.Add(newTestObject("", "c", "5", "w"))
+	close(stopCh)
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if e, a := []string{"a", "c"}, storeKeys(store); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if a, _, _ := store.GetByKey("a"); a.Value != "z" {
+		t.Errorf("expected a to be updated, got %v", a)
+	}
+	if e, a := []string{"0"}, lw.listedFrom(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected to list from %v, got %v", e, a)
+	}
+	if e, a := []string{"1", "4"}, lw.watchedFrom(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected to watch from %v, got %v", e, a)
+	}
+	if e, a := "5", r.LastSyncResourceVersion(); e != a {
+		t.Errorf("expected last sync resource version %q, got %q", e, a)
+	}
+}
+
+func TestReflectorWatchExpired(t *testing.T) {
+	lw := &testListWatch{
+		listFunc: func(options metav1.ListOptions) (*ListResult[*testObject], error) {
+			switch options.ResourceVersion {
+			case "0":
+				return listResult("10", newTestObject("", "a", "10", "x")), nil
+			case "10":
+				// The resource version of the first list is too old
+				// by now as well.
+				return nil, apierrors.NewResourceExpired("too old resource version: 10")
+			default:
+				return listResult("20", newTestObject("", "b", "20", "y")), nil
+			}
+		},
+		watchFunc: func(options metav1.ListOptions) (watch.Interface[*testObject], error) {
+			w := watch.NewFakeWithChanSize[*testObject](1)
+			w.Error(apierrors.NewResourceExpired("too old resource version: " + options.ResourceVersion))
+			return w, nil
+		},
+	}
+	store := NewStore(MetaNamespaceKeyFunc[*testObject])
+	r := NewReflector[*testObject](lw, &testObject{}, store, 0)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// An expired watch is not an error, it requires listing again.
+	if err := r.ListAndWatch(stopCh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.ListAndWatch(stopCh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The relist is first tried from the last resource version, then
+	// falls back to the latest one.
+	if e, a := []string{"0", "10", ""}, lw.listedFrom(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected to list from %v, got %v", e, a)
+	}
+	if e, a := []string{"10", "20"}, lw.watchedFrom(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected to watch from %v, got %v", e, a)
+	}
+	if e, a := []string{"b"}, storeKeys(store); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "20", r.LastSyncResourceVersion(); e != a {
+		t.Errorf("expected last sync resource version %q, got %q", e, a)
+	}
+	if e, a := "20", r.relistResourceVersion(); e != a {
+		t.Errorf("expected to relist from %q once listing succeeded, got %q", e, a)
+	}
+}
+
+func TestReflectorErrors(t *testing.T) {
+	errList := errors.New("list failed")
+	errWatch := errors.New("watch failed")
+	errEvent := apierrors.NewUnauthorized("token expired")
+
+	for _, tc := range []struct {
+		name      string
+		listErr   error
+		watchErr  error
+		eventErr  error
+		expectErr error
+	}{
+		{name: "list", listErr: errList, expectErr: errList},
+		{name: "watch", watchErr: errWatch, expectErr: errWatch},
+		{name: "watch event", eventErr: errEvent, expectErr: errEvent},
+		{name: "very short watch", expectErr: errorVeryShortWatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lw := &testListWatch{
+				listFunc: func(metav1.ListOptions) (*ListResult[*testObject], error) {
+					if tc.listErr != nil {
+						return nil, tc.listErr
+					}
+					return listResult("1"), nil
+				},
+				watchFunc: func(metav1.ListOptions) (watch.Interface[*testObject], error) {
+					if tc.watchErr != nil {
+						return nil, tc.watchErr
+					}
+					w := watch.NewFakeWithChanSize[*testObject](1)
+					if tc.eventErr != nil {
+						w.Error(tc.eventErr)
+					} else {
+						w.Stop()
+					}
+					return w, nil
+				},
+			}
+			r := NewReflector[*testObject](lw, &testObject{}, NewStore(MetaNamespaceKeyFunc[*testObject]), 0)
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			if err := r.ListAndWatch(stopCh); !errors.Is(err, tc.expectErr) {
+				t.Errorf("expected %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestReflectorWatchTooManyRequests(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	watches := 0
+	w := watch.NewFake[*testObject]()
+	lw := &testListWatch{
+		listFunc: func(metav1.ListOptions) (*ListResult[*testObject], error) {
+			return listResult("1"), nil
+		},
+		watchFunc: func(metav1.ListOptions) (watch.Interface[*testObject], error) {
+			watches++
+			if watches == 1 {
+				return nil, apierrors.NewTooManyRequests("slow down")
+			}
+			return w, nil
+		},
+	}
+	store := NewStore(MetaNamespaceKeyFunc[*testObject])
+	r := NewReflectorWithOptions[*testObject](lw, &testObject{}, store, ReflectorOptions{Clock: fakeClock})
+	stopCh := make(chan struct{})
+	errCh := make(chan error)
+	go func() { errCh <- r.ListAndWatch(stopCh) }()
+
+	// The watch is retried after backing off, without listing again.
+	waitForWaiters(t, fakeClock)
+	fakeClock.Step(2 * defaultInitialBackoff)
+	w.Add(newTestObject("", "a", "2", "x"))
+	close(stopCh)
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := []string{"0"}, lw.listedFrom(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected to list from %v, got %v", e, a)
+	}
+	if e, a := []string{"a"}, storeKeys(store); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+// countingStore counts the resyncs of a store.
+type countingStore struct {
+	Store[*testObject]
+	lock    sync.Mutex
+	resyncs int
+}
+
+func (s *countingStore) Resync() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.resyncs++
+	return nil
+}
+
+func (s *countingStore) resyncCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.resyncs
+}
+
+func TestReflectorResync(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	lw := &testListWatch{
+		listFunc: func(metav1.ListOptions) (*ListResult[*testObject], error) {
+			return listResult("1"), nil
+		},
+		watchFunc: func(metav1.ListOptions) (watch.Interface[*testObject], error) {
+			return watch.NewFake[*testObject](), nil
+		},
+	}
+	store := &countingStore{Store: NewStore(MetaNamespaceKeyFunc[*testObject])}
+	r := NewReflectorWithOptions[*testObject](lw, &testObject{}, store, ReflectorOptions{
+		ResyncPeriod: time.Minute,
+		Clock:        fakeClock,
+	})
+	var shouldResync sync.Mutex
+	resync := false
+	r.ShouldResync = func() bool {
+		shouldResync.Lock()
+		defer shouldResync.Unlock()
+		return resync
+	}
+
+	stopCh := make(chan struct{})
+	errCh := make(chan error)
+	go func() { errCh <- r.ListAndWatch(stopCh) }()
+
+	// ShouldResync declines the first resync.
+	waitForWaiters(t, fakeClock)
+	fakeClock.Step(time.Minute)
+	waitForWaiters(t, fakeClock)
+	if e, a := 0, store.resyncCount(); e != a {
+		t.Errorf("expected %d resyncs, got %d", e, a)
+	}
+
+	shouldResync.Lock()
+	resync = true
+	shouldResync.Unlock()
+	fakeClock.Step(time.Minute)
+	waitForWaiters(t, fakeClock)
+	if e, a := 1, store.resyncCount(); e != a {
+		t.Errorf("expected %d resyncs, got %d", e, a)
+	}
+
+	close(stopCh)
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestReflectorRunBacksOff(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	lists := make(chan struct{}, 10)
+	lw := &testListWatch{
+		listFunc: func(metav1.ListOptions) (*ListResult[*testObject], error) {
+			lists <- struct{}{}
+			return nil, fmt.Errorf("server unavailable")
+		},
+	}
+	r := NewReflectorWithOptions[*testObject](lw, &testObject{}, NewStore(MetaNamespaceKeyFunc[*testObject]), ReflectorOptions{
+		InitialBackoff: time.Second,
+		MaxBackoff:     4 * time.Second,
+		Clock:          fakeClock,
+	})
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(stopCh)
+	}()
+
+	<-lists
+	waitForWaiters(t, fakeClock)
+	// The first backoff is at most twice the initial one with jitter.
+	fakeClock.Step(2 * time.Second)
+	<-lists
+	waitForWaiters(t, fakeClock)
+	// The second one is at least twice the initial one.
+	fakeClock.Step(2*time.Second - time.Nanosecond)
+	select {
+	case <-lists:
+		t.Fatalf("listed again before the backoff passed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	fakeClock.Step(3 * time.Second)
+	<-lists
+
+	close(stopCh)
+	<-done
+}
+
+func TestReflectorWatchErrorHandler(t *testing.T) {
+	errList := apierrors.NewUnauthorized("token expired")
+	lists := 0
+	lw := &testListWatch{
+		listFunc: func(metav1.ListOptions) (*ListResult[*testObject], error) {
+			lists++
+			if lists == 2 {
+				return nil, errList
+			}
+			return listResult("1"), nil
+		},
+		watchFunc: func(options metav1.ListOptions) (watch.Interface[*testObject], error) {
+			return nil, apierrors.NewGone("too old resource version: " + options.ResourceVersion)
+		},
+	}
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	r := NewReflectorWithOptions[*testObject](lw, &testObject{}, NewStore(MetaNamespaceKeyFunc[*testObject]), ReflectorOptions{Clock: fakeClock})
+	handled := make(chan error, 10)
+	r.WatchErrorHandler = func(hr *Reflector[*testObject], err error) {
+		if hr != r {
+			t.Errorf("expected the handler to be called with its reflector")
+		}
+		handled <- err
+	}
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(stopCh)
+	}()
+
+	// The expired watch is handled even though it is not an error of
+	// ListAndWatch, then the failed relist is.
+	if err := <-handled; !IsExpiredError(err) {
+		t.Errorf("expected an expired error, got %v", err)
+	}
+	waitForWaiters(t, fakeClock)
+	fakeClock.Step(defaultMaxBackoff)
+	if err := <-handled; !errors.Is(err, errList) {
+		t.Errorf("expected %v, got %v", errList, err)
+	}
+
+	close(stopCh)
+	<-done
+}
+
+func TestBackoff(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	b := &backoff{initial: time.Second, max: 4 * time.Second, reset: time.Minute, clock: fakeClock}
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if d := b.next(); d < expected || d > 2*expected {
+			t.Errorf("expected a backoff in [%v, %v], got %v", expected, 2*expected, d)
+		}
+		fakeClock.Step(time.Second)
+	}
+
+	// Without failures for longer than the reset duration, the backoff
+	// starts over.
+	fakeClock.Step(2 * time.Minute)
+	if d := b.next(); d < time.Second || d > 2*time.Second {
+		t.Errorf("expected the backoff to be reset, got %v", d)
+	}
+}
+
+// waitForWaiters waits until something waits on the fake clock.
+func waitForWaiters(t *testing.T, c *clocktesting.FakeClock) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !c.HasWaiters() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the clock to be waited on")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestIsExpiredError(t *testing.T) {
+	for _, test := range []struct {
+		err      error
+		expected bool
+	}{
+		{err: apierrors.NewResourceExpired("too old resource version"), expected: true},
+		{err: apierrors.NewGone("too old resource version"), expected: true},
+		{err: fmt.Errorf("watch: %w", apierrors.NewGone("too old resource version")), expected: true},
+		{err: apierrors.NewUnauthorized("token expired"), expected: false},
+		{err: errors.New("connection refused"), expected: false},
+	} {
+		if a := IsExpiredError(test.err); a != test.expected {
+			t.Errorf("IsExpiredError(%v): expected %v, got %v", test.err, test.expected, a)
+		}
+	}
+}
@@ -0,0 +1,590 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
+	"github.com/ForbiddenR/jxclient-go/internal/clocktesting"
+	apierrors "github.com/ForbiddenR/jxclient-go/pkg/api/errors"
+	watch "github.com/ForbiddenR/jxclient-go/pkg/watch"
+)
+
+type testObject struct {
+	metav1.ObjectMeta
+
+	Value string
+}
+
+func newTestObject(namespace, name, resourceVersion, value string) *testObject {
+	return &testObject{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, ResourceVersion: resourceVersion},
+		Value:      value,
+	}
+}
+
+// fakeListerWatcher lists the objects it was given, and hands out the
+// watchers it was given one per Watch call.
+type fakeListerWatcher struct {
+	lock     sync.Mutex
+	lists    [][]*testObject
+	watchers []*watch.FakeWatcher[*testObject]
+	// watchFrom records the resource versions watches were started from.
+	watchFrom []string
+}
+
+func (lw *fakeListerWatcher) List(options metav1.ListOptions) (*ListResult[*testObject], error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if len(lw.lists) == 0 {
+		return nil, fmt.Errorf("no more lists")
+	}
+	items := lw.lists[0]
+	if len(lw.lists) > 1 {
+		lw.lists = lw.lists[1:]
+	}
+	resourceVersion := "0"
+	for _, item := range items {
+		if item.ResourceVersion > resourceVersion {
+			resourceVersion = item.ResourceVersion
+		}
+	}
+	return &ListResult[*testObject]{ListMeta: metav1.ListMeta{ResourceVersion: resourceVersion}, Items: items}, nil
+}
+
+func (lw *fakeListerWatcher) Watch(options metav1.ListOptions) (watch.Interface[*testObject], error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if len(lw.watchers) == 0 {
+		return nil, fmt.Errorf("no more watchers")
+	}
+	w := lw.watchers[0]
+	lw.watchers = lw.watchers[1:]
+	lw.watchFrom = append(lw.watchFrom, options.ResourceVersion)
+	return w, nil
+}
+
+// eventRecorder records the events of a handler, with their flags.
+type eventRecorder struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (r *eventRecorder) record(event string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) OnAdd(obj *testObject, isInInitialList bool) {
+	if isInInitialList {
+		r.record("initial " + obj.Name + "=" + obj.Value)
+		return
+	}
+	r.record("add " + obj.Name + "=" + obj.Value)
+}
+
+func (r *eventRecorder) OnUpdate(oldObj, newObj *testObject) {
+	r.record("update " + oldObj.Name + "=" + oldObj.Value + "->" + newObj.Value)
+}
+
+func (r *eventRecorder) OnDelete(obj *testObject, tombstone *DeletedFinalStateUnknown[*testObject]) {
+	if tombstone != nil {
+		r.record("tombstone " + tombstone.Key + "=" + tombstone.Obj.Value)
+		return
+	}
+	r.record("delete " + obj.Name + "=" + obj.Value)
+}
+
+// wait waits for n events, sorting the first sorted ones as their order is
+// not defined.
+func (r *eventRecorder) wait(t *testing.T, n, sorted int) []string {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		r.lock.Lock()
+		events := append([]string(nil), r.events...)
+		r.lock.Unlock()
+		if len(events) >= n {
+			sort.Strings(events[:sorted])
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d events, got %v", n, events)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func byValue(obj *testObject) ([]string, error) {
+	return []string{obj.Value}, nil
+}
+
+func TestSharedIndexInformer(t *testing.T) {
+	w := watch.NewFake[*testObject]()
+	lw := &fakeListerWatcher{
+		lists: [][]*testObject{{
+			newTestObject("ns", "a", "1", "x"),
+			newTestObject("ns", "b", "2", "y"),
+		}},
+		watchers: []*watch.FakeWatcher[*testObject]{w},
+	}
+	informer := NewSharedIndexInformer[*testObject](lw, &testObject{}, 0, Indexers[*testObject]{"value": byValue}).(*sharedIndexInformer[*testObject])
+
+	recorder := &eventRecorder{}
+	registration, err := informer.AddEventHandler(recorder)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		informer.Run(stopCh)
+	}()
+
+	recorder.wait(t, 2, 2)
+	deadline := time.Now().Add(testTimeout)
+	for !registration.HasSynced() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the registration to sync")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !informer.HasSynced() {
+		t.Error("expected the informer to have synced")
+	}
+
+	w.Add(newTestObject("ns", "c", "3", "x"))
+	w.Modify(newTestObject("ns", "a", "4", "z"))
+	w.Delete(newTestObject("ns", "b", "5", "y"))
+
+	expected := []string{"initial a=x", "initial b=y", "add c=x", "update a=x->z", "delete b=y"}
+	if events := recorder.wait(t, len(expected), 2); !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+	if rv := informer.LastSyncResourceVersion(); rv != "5" {
+		t.Errorf("Expected last synced resource version 5, got %q", rv)
+	}
+	if keys := lw.watchFrom; !reflect.DeepEqual([]string{"2"}, keys) {
+		t.Errorf("Expected the watch to start from resource version 2, got %v", keys)
+	}
+
+	objs, err := informer.GetIndexer().ByIndex("value", "x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(objs) != 1 || objs[0].Name != "c" {
+		t.Errorf("Expected only c to be indexed by x, got %v", objs)
+	}
+
+	// A late handler is told about every cached object.
+	late := &eventRecorder{}
+	if _, err := informer.AddEventHandler(late); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if events := late.wait(t, 2, 2); !reflect.DeepEqual([]string{"initial a=z", "initial c=x"}, events) {
+		t.Errorf("late handler got %v", events)
+	}
+
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("informer did not stop")
+	}
+	if !informer.IsStopped() {
+		t.Error("expected the informer to be stopped")
+	}
+	if _, err := informer.AddEventHandler(&eventRecorder{}); err == nil {
+		t.Error("expected adding a handler to a stopped informer to fail")
+	}
+	if !w.IsStopped() {
+		t.Error("expected the watch to be stopped")
+	}
+}
+
+func TestSharedIndexInformerRelist(t *testing.T) {
+	w1 := watch.NewFake[*testObject]()
+	w2 := watch.NewFake[*testObject]()
+	lw := &fakeListerWatcher{
+		lists: [][]*testObject{
+			{newTestObject("", "a", "1", "x"), newTestObject("", "b", "2", "y")},
+			{newTestObject("", "a", "3", "z"), newTestObject("", "c", "4", "w")},
+		},
+		watchers: []*watch.FakeWatcher[*testObject]{w1, w2},
+	}
+	informer := NewSharedIndexInformer[*testObject](lw, &testObject{}, 0, nil).(*sharedIndexInformer[*testObject])
+	informer.initialBackoff = time.Millisecond
+
+	recorder := &eventRecorder{}
+	if _, err := informer.AddEventHandler(recorder); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Run(stopCh)
+
+	recorder.wait(t, 2, 2)
+	// The watch expires, the objects are listed again and b was deleted in
+	// the meantime.
+	w1.Error(apierrors.NewResourceExpired("too old resource version"))
+	events := recorder.wait(t, 5, 2)
+	sort.Strings(events[2:])
+	expected := []string{"initial a=x", "initial b=y", "add c=w", "tombstone b=y", "update a=x->z"}
+	if !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
+
+func TestSharedIndexInformerRelistUnchanged(t *testing.T) {
+	w1 := watch.NewFake[*testObject]()
+	w2 := watch.NewFake[*testObject]()
+	lw := &fakeListerWatcher{
+		lists: [][]*testObject{
+			{newTestObject("", "a", "1", "x")},
+			{newTestObject("", "a", "1", "x"), newTestObject("", "c", "2", "w")},
+		},
+		watchers: []*watch.FakeWatcher[*testObject]{w1, w2},
+	}
+	informer := NewSharedIndexInformer[*testObject](lw, &testObject{}, 0, nil).(*sharedIndexInformer[*testObject])
+	informer.initialBackoff = time.Millisecond
+
+	recorder := &eventRecorder{}
+	if _, err := informer.AddEventHandler(recorder); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Run(stopCh)
+
+	recorder.wait(t, 1, 1)
+	w1.Error(apierrors.NewResourceExpired("too old resource version"))
+	// a is replaced at the same resource version. That is delivered like a
+	// resync, which goes to handlers that have not been checked for one
+	// yet, so the handler is updated even though nothing changed.
+	events := recorder.wait(t, 3, 0)
+	expected := []string{"initial a=x", "update a=x->x", "add c=w"}
+	if !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
+
+func TestSharedIndexInformerAddIndexers(t *testing.T) {
+	informer := NewSharedIndexInformer[*testObject](&fakeListerWatcher{}, &testObject{}, 0, Indexers[*testObject]{"value": byValue})
+	if err := informer.AddIndexers(Indexers[*testObject]{"value": byValue}); err == nil {
+		t.Error("expected conflicting indexers to be rejected")
+	}
+	if err := informer.AddIndexers(Indexers[*testObject]{"other": byValue}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := informer.AddEventHandlerWithOptions(&eventRecorder{}, HandlerOptions[*testObject]{OverflowPolicy: OverflowCoalesce}); err == nil {
+		t.Error("expected invalid handler options to be rejected")
+	}
+}
+
+func TestSharedIndexInformerSetTransform(t *testing.T) {
+	w := watch.NewFake[*testObject]()
+	lw := &fakeListerWatcher{
+		lists:    [][]*testObject{{newTestObject("", "a", "1", "large")}},
+		watchers: []*watch.FakeWatcher[*testObject]{w},
+	}
+	informer := NewSharedIndexInformer[*testObject](lw, &testObject{}, 0, nil)
+	transforms := 0
+	err := informer.SetTransform(func(obj *testObject) (*testObject, error) {
+		transforms++
+		obj.Value = "small"
+		return obj, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recorder := &eventRecorder{}
+	if _, err := informer.AddEventHandler(recorder); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Run(stopCh)
+
+	recorder.wait(t, 1, 1)
+	w.Modify(newTestObject("", "a", "2", "larger"))
+	events := recorder.wait(t, 2, 0)
+	expected := []string{"initial a=small", "update a=small->small"}
+	if !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+	obj, exists, err := informer.GetStore().GetByKey("a")
+	if err != nil || !exists {
+		t.Fatalf("expected a in the store, got exists=%v err=%v", exists, err)
+	}
+	if e, a := "small", obj.Value; e != a {
+		t.Errorf("expected the cached value %q, got %q", e, a)
+	}
+	if e, a := 2, transforms; e != a {
+		t.Errorf("expected %d transforms, got %d", e, a)
+	}
+
+	if err := informer.SetTransform(nil); err == nil {
+		t.Error("expected SetTransform to fail after the informer started")
+	}
+}
+
+func TestSharedIndexInformerSetWatchErrorHandler(t *testing.T) {
+	w := watch.NewFake[*testObject]()
+	lw := &fakeListerWatcher{
+		lists:    [][]*testObject{{newTestObject("", "a", "1", "x")}},
+		watchers: []*watch.FakeWatcher[*testObject]{w},
+	}
+	informer := NewSharedIndexInformer[*testObject](lw, &testObject{}, 0, nil)
+	handled := make(chan error, 1)
+	err := informer.SetWatchErrorHandler(func(r *Reflector[*testObject], err error) {
+		select {
+		case handled <- err:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Run(stopCh)
+
+	w.Error(apierrors.NewUnauthorized("token expired"))
+	select {
+	case err := <-handled:
+		if !apierrors.IsUnauthorized(err) {
+			t.Errorf("expected an unauthorized error, got %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the watch error handler")
+	}
+
+	if err := informer.SetWatchErrorHandler(nil); err == nil {
+		t.Error("expected SetWatchErrorHandler to fail after the informer started")
+	}
+}
+
+func (r *eventRecorder) count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.events)
+}
+
+func TestResyncCheckPeriod(t *testing.T) {
+	informer := NewSharedIndexInformer[*testObject](&fakeListerWatcher{}, &testObject{}, 12*time.Hour, nil).(*sharedIndexInformer[*testObject])
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	informer.clock = fakeClock
+	informer.processor.clock = fakeClock
+
+	resyncPeriodOf := func(handle ResourceEventHandlerRegisteration) time.Duration {
+		listener := handle.(*processorListener[*testObject])
+		listener.resyncLock.Lock()
+		defer listener.resyncLock.Unlock()
+		return listener.resyncPeriod
+	}
+
+	// listener 1, never resync
+	handle1, _ := informer.AddEventHandlerWithResyncPeriod(&eventRecorder{}, 0)
+	if e, a := 12*time.Hour, informer.resyncCheckPeriod; e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+	if e, a := time.Duration(0), resyncPeriodOf(handle1); e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+
+	// listener 2, resync every minute
+	handle2, _ := informer.AddEventHandlerWithResyncPeriod(&eventRecorder{}, 1*time.Minute)
+	if e, a := 1*time.Minute, informer.resyncCheckPeriod; e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+	if e, a := 1*time.Minute, resyncPeriodOf(handle2); e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+
+	// listener 3, resync every 55 seconds, lowers the check period
+	handle3, _ := informer.AddEventHandlerWithResyncPeriod(&eventRecorder{}, 55*time.Second)
+	if e, a := 55*time.Second, informer.resyncCheckPeriod; e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+	for i, e := range []time.Duration{0, 1 * time.Minute, 55 * time.Second} {
+		if a := resyncPeriodOf([]ResourceEventHandlerRegisteration{handle1, handle2, handle3}[i]); e != a {
+			t.Errorf("listener %d: expected %d, got %d", i+1, e, a)
+		}
+	}
+
+	// listener 4, uses the informer's default resync period
+	handle4, _ := informer.AddEventHandler(&eventRecorder{})
+	if e, a := 12*time.Hour, resyncPeriodOf(handle4); e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+
+	// listener 5, too short a period is raised to the minimum
+	handle5, _ := informer.AddEventHandlerWithResyncPeriod(&eventRecorder{}, time.Millisecond)
+	if e, a := minimumResyncPeriod, informer.resyncCheckPeriod; e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+	if e, a := minimumResyncPeriod, resyncPeriodOf(handle5); e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+
+	// Once started, the check period no longer changes and shorter periods
+	// are raised to it.
+	informer.resyncCheckPeriod = 10 * time.Second
+	informer.started = true
+	handle6, _ := informer.AddEventHandlerWithResyncPeriod(&eventRecorder{}, 2*time.Second)
+	if e, a := 10*time.Second, informer.resyncCheckPeriod; e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+	if e, a := 10*time.Second, resyncPeriodOf(handle6); e != a {
+		t.Errorf("expected %d, got %d", e, a)
+	}
+}
+
+func TestDetermineResyncPeriod(t *testing.T) {
+	for _, tc := range []struct {
+		desired, check, expected time.Duration
+	}{
+		{desired: 0, check: time.Minute, expected: 0},
+		{desired: time.Minute, check: 0, expected: 0},
+		{desired: time.Second, check: time.Minute, expected: time.Minute},
+		{desired: time.Hour, check: time.Minute, expected: time.Hour},
+	} {
+		if a := determineResyncPeriod(tc.desired, tc.check); a != tc.expected {
+			t.Errorf("determineResyncPeriod(%v, %v): expected %v, got %v", tc.desired, tc.check, tc.expected, a)
+		}
+	}
+}
+
+func TestSharedIndexInformerResync(t *testing.T) {
+	lw := &fakeListerWatcher{
+		lists:    [][]*testObject{{newTestObject("", "a", "1", "x"), newTestObject("", "b", "2", "y")}},
+		watchers: []*watch.FakeWatcher[*testObject]{watch.NewFake[*testObject]()},
+	}
+	informer := NewSharedIndexInformer[*testObject](lw, &testObject{}, 10*time.Minute, nil).(*sharedIndexInformer[*testObject])
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	informer.clock = fakeClock
+	informer.processor.clock = fakeClock
+
+	everyMinute := &eventRecorder{}
+	everyThreeMinutes := &eventRecorder{}
+	never := &eventRecorder{}
+	for handler, period := range map[*eventRecorder]time.Duration{
+		everyMinute:       time.Minute,
+		everyThreeMinutes: 3 * time.Minute,
+		never:             0,
+	} {
+		if _, err := informer.AddEventHandlerWithResyncPeriod(handler, period); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Run(stopCh)
+
+	for _, handler := range []*eventRecorder{everyMinute, everyThreeMinutes, never} {
+		handler.wait(t, 2, 2)
+	}
+	// waitForTimer waits for the resync check timer to be armed.
+	waitForTimer := func() {
+		deadline := time.Now().Add(testTimeout)
+		for !fakeClock.HasWaiters() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the resync timer")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	resync := []string{"update a=x->x", "update b=y->y"}
+	for minute := 1; minute <= 3; minute++ {
+		waitForTimer()
+		fakeClock.Step(time.Minute)
+		events := everyMinute.wait(t, 2+2*minute, 0)
+		sort.Strings(events[len(events)-2:])
+		if a := events[len(events)-2:]; !reflect.DeepEqual(resync, a) {
+			t.Errorf("minute %d: expected %v, got %v", minute, resync, a)
+		}
+	}
+	events := everyThreeMinutes.wait(t, 4, 0)
+	sort.Strings(events[2:])
+	if a := events[2:]; !reflect.DeepEqual(resync, a) {
+		t.Errorf("expected %v, got %v", resync, a)
+	}
+
+	// Let the last notifications be delivered.
+	time.Sleep(10 * time.Millisecond)
+	if n := everyMinute.count(); n != 8 {
+		t.Errorf("expected 3 resyncs every minute, got %d events", n)
+	}
+	if n := everyThreeMinutes.count(); n != 4 {
+		t.Errorf("expected a single resync after three minutes, got %d events", n)
+	}
+	if n := never.count(); n != 2 {
+		t.Errorf("expected no resync, got %d events", n)
+	}
+}
+
+func TestSharedIndexInformerAddEventHandlerWhileStopping(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		lw := &fakeListerWatcher{
+			lists:    [][]*testObject{{newTestObject("ns", "a", "1", "x")}},
+			watchers: []*watch.FakeWatcher[*testObject]{watch.NewFake[*testObject]()},
+		}
+		informer := NewSharedIndexInformer[*testObject](lw, &testObject{}, 0, Indexers[*testObject]{})
+		stopCh := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			informer.Run(stopCh)
+		}()
+		deadline := time.Now().Add(testTimeout)
+		for !informer.HasSynced() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the informer to sync")
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		// Keep adding handlers until the informer refuses them, while it
+		// is being stopped.
+		var adders, adding sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			adders.Add(1)
+			adding.Add(1)
+			go func() {
+				defer adders.Done()
+				for n := 0; ; n++ {
+					if _, err := informer.AddEventHandler(&eventRecorder{}); err != nil {
+						return
+					}
+					if n == 0 {
+						adding.Done()
+					}
+				}
+			}()
+		}
+		adding.Wait()
+		added := make(chan struct{})
+		go func() {
+			defer close(added)
+			adders.Wait()
+		}()
+		close(stopCh)
+		for _, ch := range []chan struct{}{done, added} {
+			select {
+			case <-ch:
+			case <-time.After(testTimeout):
+				t.Fatal("timed out waiting for the informer to stop")
+			}
+		}
+	}
+}
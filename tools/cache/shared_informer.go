@@ -1,64 +1,942 @@
 package cache
 
 import (
-	"reflect"
+	"context"
+	"fmt"
+	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	metav1 "github.com/ForbiddenR/jxclient-go/apis/meta/v1"
 	"github.com/ForbiddenR/jxutils/buffer"
+	"github.com/ForbiddenR/jxutils/clock"
 )
 
-type SharedInformer interface {
+// Informer is the part of a SharedInformer which does not depend on the type
+// of its objects, so that informers for different types can be started and
+// stopped together.
+type Informer interface {
+	// Run starts and runs the shared informer, returning after it stops.
+	// The informer will be stopped when stopCh is closed.
+	Run(stopCh <-chan struct{})
+
+	// IsStopped reports whether the informer has already been stopped.
+	// Adding event handlers to already stopped informers is no possible.
+	// An informer already stopped will never be started again.
+	IsStopped() bool
+
+	// HasSynced returns true if the shared informer's store has been
+	// informed by at least one full LIST of the authoritative state
+	// of the informer's object collection.
+	HasSynced() bool
+}
+
+// SharedInformer provides eventually consistent linkage of its
+// clients to the authoritative state of a given collection of
+// objects of type T.
+type SharedInformer[T metav1.Object] interface {
+	Informer
+
 	// AddEventHandler adds event handler to the shared informer using
 	// the shared informer's resync period. Events to a single handler are
 	// delivered sequentially, but there is no coordination between
 	// different handlers.
-	AddEventHandler(handler ResourceEventHandler) (ResourceEventHandlerRegisteration, error)
+	AddEventHandler(handler ResourceEventHandler[T]) (ResourceEventHandlerRegisteration, error)
 	// AddEventHandlerWithResyncPeriod adds an event handler to the
 	// shared informer with the requested resync period; zero means
 	// this handler does not care about resyncs. The resync operation
 	// consists of delivering to the handler an update notification
 	// for every object in the informer's local cache; it does not add
+	// any interactions with the authoritative storage. Some
 	// informers do no resyncs at all, not even for handlers added
 	// with a non-zero resyncPeriod. For an informer that does
 	// resyncs, and for each handler that requests resyncs, that
 	// informer develops a nominal resync period that is no shorter
+	// than the requested period but may be longer. The actual time
 	// between any two resyncs may be longer that the nominal period
 	// because the implementation takes time to do work and there may
 	// be competing load and scheduling noise.
 	// It returns a registration handle for the handler that can be used to remove
 	// the handler again and an error if the handler cannot be added.
-	AddEventHandlerWithResyncPeriod(handler ResourceEventHandler, resyncPeriod time.Duration) (ResourceEventHandlerRegisteration, error)
+	AddEventHandlerWithResyncPeriod(handler ResourceEventHandler[T], resyncPeriod time.Duration) (ResourceEventHandlerRegisteration, error)
+	// AddEventHandlerWithOptions is a variant of AddEventHandler where
+	// all optional parameters can be passed in as a struct.
+	AddEventHandlerWithOptions(handler ResourceEventHandler[T], options HandlerOptions[T]) (ResourceEventHandlerRegisteration, error)
 	// RemoveEventHandler removes a formerly added event handler given by
 	// its registration handle.
 	// This function is guaranteed to be idempotent, and thread-safe.
 	RemoveEventHandler(handle ResourceEventHandlerRegisteration) error
-	// Run starts and runs the shared informer, returning after it stops.
-	// The informer will be stopped when stopCh is closed.
-	Run(stopCh <-chan struct{})
+	// GetStore returns the informer's local cache as a Store.
+	GetStore() Store[T]
 
-	// IsStopped reports whether the informer has already been stopped.
-	// Adding event handlers to already stopped informers is no possible.
-	// An informer already stopped will never be started again.
-	IsStopped() bool
+	// LastSyncResourceVersion is the resource version observed when last synced with the underlying
+	// store. The value returned is not synchronized with access to the underlying store and is not
+	// thread-safe.
+	LastSyncResourceVersion() string
+
+	// SetTransform sets a transform function which is applied once to every
+	// object before it is put into the local cache and before any handler
+	// sees it. Use it to strip fields the consumers of the informer never
+	// read, so that the memory of long-running components stays bounded.
+	//
+	// The transform must be set before the informer is started.
+	SetTransform(handler TransformFunc[T]) error
+
+	// SetWatchErrorHandler sets the handler called whenever the informer's
+	// list and watch drops the connection with an error, or its watch
+	// expired. The informer lists and watches again afterwards, so the
+	// handler is for visibility only: it can tell a routine expiry or EOF
+	// apart from persistent failures such as an invalid token.
+	//
+	// The handler must be set before the informer is started. If it is
+	// not set, DefaultWatchErrorHandler is used.
+	SetWatchErrorHandler(handler WatchErrorHandler[T]) error
+}
+
+// SharedIndexInformer provides add and get Indexers ability based on SharedInformer.
+type SharedIndexInformer[T metav1.Object] interface {
+	SharedInformer[T]
+	// AddIndexers add indexers to the informer before it starts.
+	AddIndexers(indexers Indexers[T]) error
+	GetIndexer() Indexer[T]
+}
+
+// InformerSynced is a function that can be used to determine if an informer has synced.  This is useful for determining if caches have synced.
+type InformerSynced func() bool
+
+// NamedInformerSynced is an InformerSynced along with the name the cache is
+// logged by while it has not synced, e.g. "esam.v1.AccessVerify".
+type NamedInformerSynced struct {
+	Name   string
+	Synced InformerSynced
+}
+
+const (
+	// syncedPollPeriod controls how often you look at the status of your sync funcs
+	syncedPollPeriod = 100 * time.Millisecond
+
+	// syncedLogPeriod controls how often WaitForNamedCacheSync logs the
+	// caches it is still waiting for.
+	syncedLogPeriod = 10 * time.Second
+)
+
+// WaitForNamedCacheSync is a wrapper around WaitForCacheSync that generates log messages
+// indicating that the caller identified by name is waiting for syncs, followed by
+// either a successful or failed sync. While waiting, it periodically logs the
+// names of the caches which have not synced yet.
+func WaitForNamedCacheSync(controllerName string, stopCh <-chan struct{}, cacheSyncs ...NamedInformerSynced) bool {
+	return waitForNamedCacheSync(controllerName, stopCh, syncedPollPeriod, syncedLogPeriod, cacheSyncs)
+}
+
+// WaitForCacheSync waits for caches to populate.  It returns true if it was successful, false
+// if the controller should shutdown
+// callers should prefer WaitForNamedCacheSync()
+func WaitForCacheSync(stopCh <-chan struct{}, cacheSyncs ...InformerSynced) bool {
+	return waitForCacheSync(stopCh, syncedPollPeriod, 0, unnamed(cacheSyncs), nil)
+}
+
+// WaitForCacheSyncWithContext is a variant of WaitForCacheSync which gives up
+// when ctx is done.
+func WaitForCacheSyncWithContext(ctx context.Context, cacheSyncs ...InformerSynced) bool {
+	return waitForCacheSync(ctx.Done(), syncedPollPeriod, 0, unnamed(cacheSyncs), nil)
+}
+
+// unnamed wraps cacheSyncs for waitForCacheSync, which only needs names to
+// log them.
+func unnamed(cacheSyncs []InformerSynced) []NamedInformerSynced {
+	named := make([]NamedInformerSynced, len(cacheSyncs))
+	for i, syncFunc := range cacheSyncs {
+		named[i] = NamedInformerSynced{Synced: syncFunc}
+	}
+	return named
+}
+
+func waitForNamedCacheSync(controllerName string, stopCh <-chan struct{}, pollPeriod, logPeriod time.Duration, cacheSyncs []NamedInformerSynced) bool {
+	log.Printf("cache: waiting for caches to sync for %s", controllerName)
+
+	synced := waitForCacheSync(stopCh, pollPeriod, logPeriod, cacheSyncs, func(pending []string) {
+		log.Printf("cache: still waiting for %d of %d caches to sync for %s: %s", len(pending), len(cacheSyncs), controllerName, strings.Join(pending, ", "))
+	})
+	if !synced {
+		log.Printf("cache: unable to sync caches for %s", controllerName)
+		return false
+	}
+
+	log.Printf("cache: caches are synced for %s", controllerName)
+	return true
+}
+
+// waitForCacheSync polls cacheSyncs every pollPeriod until they have all
+// synced, or stopCh is closed. If logPeriod is not zero, logPending is called
+// that often with the names of the caches which have not synced yet.
+func waitForCacheSync(stopCh <-chan struct{}, pollPeriod, logPeriod time.Duration, cacheSyncs []NamedInformerSynced, logPending func(pending []string)) bool {
+	// Caches which synced are not asked again, a cache does not become
+	// unsynced.
+	synced := make([]bool, len(cacheSyncs))
+	pending := func() []string {
+		var names []string
+		for i, cacheSync := range cacheSyncs {
+			if !synced[i] {
+				if synced[i] = cacheSync.Synced(); !synced[i] {
+					names = append(names, cacheSync.Name)
+				}
+			}
+		}
+		return names
+	}
+
+	poll := time.NewTicker(pollPeriod)
+	defer poll.Stop()
+	var logCh <-chan time.Time
+	if logPeriod > 0 {
+		logTicker := time.NewTicker(logPeriod)
+		defer logTicker.Stop()
+		logCh = logTicker.C
+	}
+
+	for {
+		names := pending()
+		if len(names) == 0 {
+			return true
+		}
+		select {
+		case <-stopCh:
+			return false
+		case <-logCh:
+			logPending(names)
+		case <-poll.C:
+		}
+	}
+}
+
+// NewSharedInformer creates a new instance for the ListerWatcher. See NewSharedIndexInformer for details.
+func NewSharedInformer[T metav1.Object](lw ListerWatcher[T], exampleObject T, defaultEventHandlerResyncPeriod time.Duration) SharedInformer[T] {
+	return NewSharedIndexInformer(lw, exampleObject, defaultEventHandlerResyncPeriod, Indexers[T]{})
+}
+
+// NewSharedIndexInformer creates a new instance for the ListerWatcher and specified Indexers.
+// The created informer will not do resyncs if defaultEventHandlerResyncPeriod is zero.
+// Otherwise: for each handler that with a non-zero requested resync period, whether added
+// before or after the informer starts, the nominal resync period is the requested resync period
+// rounded up to a multiple of the informer's resync checking period. exampleObject is only used
+// to describe the objects in errors.
+func NewSharedIndexInformer[T metav1.Object](lw ListerWatcher[T], exampleObject T, defaultEventHandlerResyncPeriod time.Duration, indexers Indexers[T]) SharedIndexInformer[T] {
+	return NewSharedIndexInformerWithOptions(lw, exampleObject, SharedIndexInformerOptions[T]{
+		ResyncPeriod: defaultEventHandlerResyncPeriod,
+		Indexers:     indexers,
+	})
+}
+
+// SharedIndexInformerOptions configures a sharedIndexInformer.
+type SharedIndexInformerOptions[T any] struct {
+	// ResyncPeriod is the default event handler resync period and resync check
+	// period. If unset/unspecified, these are defaulted to 0 (do not resync).
+	ResyncPeriod time.Duration
+
+	// Indexers is the sharedIndexInformer's indexers. If unset/unspecified, no indexers are configured.
+	Indexers Indexers[T]
+
+	// ListPageSize is the number of objects requested per page when
+	// listing, so that large collections are not read in one response.
+	// If unset/unspecified, it defaults to 500.
+	ListPageSize int64
+
+	// MetricsProvider generates the metrics of the informer's Reflector,
+	// such as the duration of each list page. If unset/unspecified, no
+	// metrics are recorded.
+	MetricsProvider ReflectorMetricsProvider
+}
+
+// NewSharedIndexInformerWithOptions creates a new instance for the ListerWatcher.
+// See NewSharedIndexInformer for details.
+func NewSharedIndexInformerWithOptions[T metav1.Object](lw ListerWatcher[T], exampleObject T, options SharedIndexInformerOptions[T]) SharedIndexInformer[T] {
+	realClock := &clock.RealClock{}
+	indexer := NewIndexer(MetaNamespaceKeyFunc[T], options.Indexers)
+	return &sharedIndexInformer[T]{
+		processor: &sharedProcessor[T]{clock: realClock},
+		indexer:   indexer,
+		fifo: NewDeltaFIFOWithOptions(DeltaFIFOOptions[T]{
+			KeyFunction:  MetaNamespaceKeyFunc[T],
+			KnownObjects: indexer,
+		}),
+		listerWatcher:                   lw,
+		objectType:                      exampleObject,
+		objectDescription:               fmt.Sprintf("%T", exampleObject),
+		resyncCheckPeriod:               options.ResyncPeriod,
+		defaultEventHandlerResyncPeriod: options.ResyncPeriod,
+		listPageSize:                    options.ListPageSize,
+		metricsProvider:                 options.MetricsProvider,
+		clock:                           realClock,
+	}
+}
+
+// sharedIndexInformer lists and watches the objects of its ListerWatcher,
+// queues the changes in a DeltaFIFO, and pops them into an indexed local
+// cache while fanning them out to its handlers through a sharedProcessor.
+type sharedIndexInformer[T metav1.Object] struct {
+	indexer Indexer[T]
+	// fifo accumulates the deltas observed by listAndWatch until they are
+	// popped by processLoop. Its known objects are the indexer's.
+	fifo *DeltaFIFO[T]
+
+	processor *sharedProcessor[T]
+
+	listerWatcher ListerWatcher[T]
+	// reflector feeds the fifo from the listerWatcher once the informer
+	// runs.
+	reflector *Reflector[T]
+
+	// objectType is an example object of the type this informer is expected
+	// to handle, and objectDescription describes it in errors.
+	objectType        T
+	objectDescription string
+
+	// resyncCheckPeriod is how often we want to check if any of our listeners need a resync.
+	resyncCheckPeriod time.Duration
+	// defaultEventHandlerResyncPeriod is the default resync period for any handlers added via
+	// AddEventHandler (i.e. they don't specify one and just want to use the shared informer's default
+	// value).
+	defaultEventHandlerResyncPeriod time.Duration
+	// clock allows for testability
+	clock clock.Clock
+
+	// initialBackoff is the initial backoff of the reflector, zero means
+	// its default.
+	initialBackoff time.Duration
+	// listPageSize and metricsProvider are passed on to the reflector.
+	listPageSize    int64
+	metricsProvider ReflectorMetricsProvider
+
+	// watchErrorHandler is passed on to the reflector, see
+	// SetWatchErrorHandler.
+	watchErrorHandler WatchErrorHandler[T]
+
+	started, stopped bool
+	startedLock      sync.Mutex
+
+	// blockDeltas gives a way to stop all event distribution so that a late event handler
+	// can safely join the shared informer.
+	blockDeltas sync.Mutex
+}
+
+const (
+	// minimumResyncPeriod is the shortest resync period a handler can get,
+	// shorter requested periods are raised to it.
+	minimumResyncPeriod = 1 * time.Second
+
+	// initialBufferSize is the initial number of event notifications that can be buffered.
+	initialBufferSize = 1024
+)
+
+func (s *sharedIndexInformer[T]) Run(stopCh <-chan struct{}) {
+	if s.HasStarted() {
+		log.Printf("cache: the sharedIndexInformer for %s has started, run more than once is not allowed", s.objectDescription)
+		return
+	}
+
+	func() {
+		s.startedLock.Lock()
+		defer s.startedLock.Unlock()
+
+		s.reflector = NewReflectorWithOptions[T](s.listerWatcher, s.objectType, s.fifo, ReflectorOptions{
+			ResyncPeriod:    s.resyncCheckPeriod,
+			InitialBackoff:  s.initialBackoff,
+			PageSize:        s.listPageSize,
+			MetricsProvider: s.metricsProvider,
+			Clock:           s.clock,
+		})
+		s.reflector.ShouldResync = s.processor.shouldResync
+		s.reflector.WatchErrorHandler = s.watchErrorHandler
+		s.started = true
+	}()
+
+	s.processor.startListeners()
+	defer s.processor.stopListeners()
+	// Deferred after stopListeners so it runs first: a handler added
+	// once the listeners are stopped would never be started.
+	defer func() {
+		s.startedLock.Lock()
+		defer s.startedLock.Unlock()
+		s.stopped = true // Don't want any new listeners
+	}()
+
+	// The process loop must be done distributing before the listeners
+	// stop, so it is closed and waited for first.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.processLoop()
+	}()
+	defer wg.Wait()
+	defer s.fifo.Close()
+
+	s.reflector.Run(stopCh)
+}
+
+// processLoop pops the deltas of the fifo into the indexer and the
+// handlers until the fifo is closed.
+func (s *sharedIndexInformer[T]) processLoop() {
+	for {
+		if _, err := s.fifo.Pop(s.HandleDeltas); err != nil {
+			if err == ErrFIFOClosed {
+				return
+			}
+			log.Printf("cache: failed to handle deltas of %s: %v", s.objectDescription, err)
+		}
+	}
+}
+
+func (s *sharedIndexInformer[T]) HasStarted() bool {
+	s.startedLock.Lock()
+	defer s.startedLock.Unlock()
+	return s.started
+}
+
+func (s *sharedIndexInformer[T]) IsStopped() bool {
+	s.startedLock.Lock()
+	defer s.startedLock.Unlock()
+	return s.stopped
+}
+
+func (s *sharedIndexInformer[T]) HasSynced() bool {
+	return s.fifo.HasSynced()
+}
+
+func (s *sharedIndexInformer[T]) LastSyncResourceVersion() string {
+	s.startedLock.Lock()
+	defer s.startedLock.Unlock()
+
+	if s.reflector == nil {
+		return ""
+	}
+	return s.reflector.LastSyncResourceVersion()
+}
+
+func (s *sharedIndexInformer[T]) GetStore() Store[T] {
+	return s.indexer
+}
+
+func (s *sharedIndexInformer[T]) GetIndexer() Indexer[T] {
+	return s.indexer
+}
+
+func (s *sharedIndexInformer[T]) AddIndexers(indexers Indexers[T]) error {
+	s.startedLock.Lock()
+	defer s.startedLock.Unlock()
+
+	if s.started {
+		return fmt.Errorf("informer has already started")
+	}
+
+	return s.indexer.AddIndexers(indexers)
+}
+
+func (s *sharedIndexInformer[T]) SetTransform(handler TransformFunc[T]) error {
+	s.startedLock.Lock()
+	defer s.startedLock.Unlock()
+
+	if s.started {
+		return fmt.Errorf("informer has already started")
+	}
+
+	s.fifo.transformer = handler
+	return nil
+}
+
+func (s *sharedIndexInformer[T]) SetWatchErrorHandler(handler WatchErrorHandler[T]) error {
+	s.startedLock.Lock()
+	defer s.startedLock.Unlock()
+
+	if s.started {
+		return fmt.Errorf("informer has already started")
+	}
+
+	s.watchErrorHandler = handler
+	return nil
+}
+
+func (s *sharedIndexInformer[T]) AddEventHandler(handler ResourceEventHandler[T]) (ResourceEventHandlerRegisteration, error) {
+	return s.AddEventHandlerWithOptions(handler, HandlerOptions[T]{})
+}
+
+func (s *sharedIndexInformer[T]) AddEventHandlerWithResyncPeriod(handler ResourceEventHandler[T], resyncPeriod time.Duration) (ResourceEventHandlerRegisteration, error) {
+	return s.AddEventHandlerWithOptions(handler, HandlerOptions[T]{ResyncPeriod: &resyncPeriod})
+}
+
+func (s *sharedIndexInformer[T]) AddEventHandlerWithOptions(handler ResourceEventHandler[T], options HandlerOptions[T]) (ResourceEventHandlerRegisteration, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	s.startedLock.Lock()
+	defer s.startedLock.Unlock()
+
+	if s.stopped {
+		return nil, fmt.Errorf("handler %v was not added to shared informer because it has stopped already", handler)
+	}
+
+	resyncPeriod := s.defaultEventHandlerResyncPeriod
+	if options.ResyncPeriod != nil {
+		resyncPeriod = *options.ResyncPeriod
+	}
+	if resyncPeriod > 0 {
+		if resyncPeriod < minimumResyncPeriod {
+			log.Printf("cache: resyncPeriod %v is too small. Changing it to the minimum allowed value of %v", resyncPeriod, minimumResyncPeriod)
+			resyncPeriod = minimumResyncPeriod
+		}
+
+		if resyncPeriod < s.resyncCheckPeriod {
+			if s.started {
+				log.Printf("cache: resyncPeriod %v is smaller than resyncCheckPeriod %v and the informer has already started. Changing it to %v", resyncPeriod, s.resyncCheckPeriod, s.resyncCheckPeriod)
+				resyncPeriod = s.resyncCheckPeriod
+			} else {
+				// if the event handler's resyncPeriod is smaller than the current resyncCheckPeriod, update
+				// resyncCheckPeriod to match resyncPeriod and adjust the resync periods of all the listeners
+				// accordingly
+				s.resyncCheckPeriod = resyncPeriod
+				s.processor.resyncCheckPeriodChanged(resyncPeriod)
+			}
+		}
+	}
+
+	listener := newProcessListener(handler, resyncPeriod, determineResyncPeriod(resyncPeriod, s.resyncCheckPeriod), s.clock.Now(), initialBufferSize, s.HasSynced, options)
+
+	if !s.started {
+		return s.processor.addListener(listener), nil
+	}
+
+	// in order to safely join, we have to
+	// 1. stop sending add/update/delete notifications
+	// 2. do a list against the store
+	// 3. send synthetic "Add" events to the new handler
+	// 4. unblock
+	s.blockDeltas.Lock()
+	defer s.blockDeltas.Unlock()
+
+	handle := s.processor.addListener(listener)
+	for _, item := range s.indexer.List() {
+		listener.add(&notification[T]{kind: addNotification, newObj: item, isInInitialList: true})
+	}
+	return handle, nil
+}
+
+func (s *sharedIndexInformer[T]) RemoveEventHandler(handle ResourceEventHandlerRegisteration) error {
+	s.startedLock.Lock()
+	defer s.startedLock.Unlock()
+
+	// in order to safely remove, we have to
+	// 1. stop sending add/update/delete notifications
+	// 2. remove and stop listener
+	// 3. unblock
+	s.blockDeltas.Lock()
+	defer s.blockDeltas.Unlock()
+	return s.processor.removeListener(handle)
+}
+
+// HandleDeltas applies the deltas of one object, as popped from the fifo,
+// to the indexer and distributes the matching notifications.
+func (s *sharedIndexInformer[T]) HandleDeltas(deltas Deltas[T], isInInitialList bool) error {
+	s.blockDeltas.Lock()
+	defer s.blockDeltas.Unlock()
+
+	for _, d := range deltas {
+		obj := d.Object
+		switch d.Type {
+		case Sync, Replaced, Added, Updated:
+			if old, exists, err := s.indexer.Get(obj); err == nil && exists {
+				if err := s.indexer.Update(obj); err != nil {
+					return err
+				}
+				// A resync, or a relist which found the object
+				// unchanged, only goes to the listeners due for a
+				// resync.
+				isSync := d.Type == Sync ||
+					d.Type == Replaced && old.GetResourceVersion() == obj.GetResourceVersion()
+				s.processor.distribute(&notification[T]{kind: updateNotification, oldObj: old, newObj: obj}, isSync)
+			} else {
+				if err := s.indexer.Add(obj); err != nil {
+					return err
+				}
+				s.processor.distribute(&notification[T]{kind: addNotification, newObj: obj, isInInitialList: isInInitialList}, false)
+			}
+		case Deleted:
+			if err := s.indexer.Delete(obj); err != nil {
+				return err
+			}
+			n := &notification[T]{kind: deleteNotification, oldObj: obj}
+			if d.FinalStateUnknown {
+				key, err := s.fifo.KeyOf(obj)
+				if err != nil {
+					return KeyError{obj, err}
+				}
+				n.tombstone = &DeletedFinalStateUnknown[T]{Key: key, Obj: obj}
+			}
+			s.processor.distribute(n, false)
+		}
+	}
+	return nil
 }
 
 type ResourceEventHandlerRegisteration interface {
 	// HasSynced reports if both the parent has synced and all pre-sync
 	// events have been dellivered.
 	HasSynced() bool
+
+	// Healthy reports false once the handler has been evicted under
+	// OverflowEvict. An evicted handler receives no more notifications.
+	Healthy() bool
+}
+
+// OverflowPolicy decides what happens to the notifications for a handler
+// once HandlerOptions.MaxPending of them are waiting for it.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops accepting notifications until the handler catches
+	// up. This blocks the informer, and with it every other handler.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest waiting notification to make
+	// room for the new one.
+	OverflowDropOldest
+	// OverflowCoalesce keeps at most one waiting notification per object
+	// key, merged so that the handler sees the latest state of the object.
+	// Once a notification for yet another object arrives while MaxPending
+	// are waiting, it blocks like OverflowBlock.
+	OverflowCoalesce
+	// OverflowEvict discards every waiting notification and stops delivering
+	// to the handler, which is then reported as not Healthy.
+	OverflowEvict
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "Block"
+	case OverflowDropOldest:
+		return "DropOldest"
+	case OverflowCoalesce:
+		return "Coalesce"
+	case OverflowEvict:
+		return "Evict"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// HandlerOptions holds the optional parameters of an event handler
+// registration.
+type HandlerOptions[T any] struct {
+	// Name identifies the registration in metrics. Metrics are only
+	// reported for named registrations.
+	Name string
+
+	// ResyncPeriod requests a resync period for the handler, see
+	// AddEventHandlerWithResyncPeriod. If nil, the resync period of the
+	// informer is used.
+	ResyncPeriod *time.Duration
+
+	// MaxPending caps the number of notifications waiting for the handler.
+	// Zero means no cap, so a stalled handler makes them pile up without
+	// bound.
+	MaxPending int
+
+	// OverflowPolicy applies once MaxPending notifications are waiting.
+	OverflowPolicy OverflowPolicy
+
+	// KeyFunc identifies objects for OverflowCoalesce, which requires it.
+	// Notifications for objects it fails on are never coalesced.
+	KeyFunc KeyFunc[T]
+
+	// MetricsProvider reports the depth of the pending notifications and
+	// the number of dropped ones. Defaults to no metrics.
+	MetricsProvider ListenerMetricsProvider
+}
+
+func (o HandlerOptions[T]) validate() error {
+	if o.MaxPending < 0 {
+		return fmt.Errorf("MaxPending must not be negative, got %d", o.MaxPending)
+	}
+	switch o.OverflowPolicy {
+	case OverflowBlock, OverflowDropOldest, OverflowEvict:
+	case OverflowCoalesce:
+		if o.KeyFunc == nil {
+			return fmt.Errorf("OverflowPolicy %v requires a KeyFunc", o.OverflowPolicy)
+		}
+	default:
+		return fmt.Errorf("unknown OverflowPolicy %v", o.OverflowPolicy)
+	}
+	return nil
+}
+
+// sharedProcessor has a collection of processorListener and can
+// distribute a notification object to its listeners. There are two
+// kinds of distribute operations. The sync distributions go to a
+// subset of the listeners that (a) is recomputed in the occasional
+// calls to shouldResync and (b) every listener is initially put in.
+// The non-sync distributions go to every listener.
+type sharedProcessor[T any] struct {
+	listenersStarted bool
+	listenersLock    sync.RWMutex
+	// Map from listeners to whether or not they are currently syncing
+	listeners map[*processorListener[T]]bool
+	clock     clock.PassiveClock
+	wg        sync.WaitGroup
+}
+
+func (p *sharedProcessor[T]) addListener(listener *processorListener[T]) ResourceEventHandlerRegisteration {
+	p.listenersLock.Lock()
+	defer p.listenersLock.Unlock()
+
+	if p.listeners == nil {
+		p.listeners = make(map[*processorListener[T]]bool)
+	}
+
+	p.listeners[listener] = true
+
+	if p.listenersStarted {
+		p.start(listener.run)
+		p.start(listener.pop)
+	}
+
+	return listener
+}
+
+func (p *sharedProcessor[T]) removeListener(handle ResourceEventHandlerRegisteration) error {
+	p.listenersLock.Lock()
+	defer p.listenersLock.Unlock()
+
+	listener, ok := handle.(*processorListener[T])
+	if !ok {
+		return fmt.Errorf("invalid key type %T", handle)
+	} else if listener == nil {
+		// Nothing to do in this case
+		return nil
+	} else if _, exists := p.listeners[listener]; !exists {
+		// This listener was already removed, or never belonged here.
+		return nil
+	}
+
+	delete(p.listeners, listener)
+
+	if p.listenersStarted {
+		close(listener.addCh)
+	}
+
+	return nil
+}
+
+// distribute hands obj to every listener, or only to the listeners which are
+// currently syncing if sync is true. It blocks while a listener is not
+// accepting notifications, but never on a listener's handler.
+func (p *sharedProcessor[T]) distribute(obj *notification[T], sync bool) {
+	p.listenersLock.RLock()
+	defer p.listenersLock.RUnlock()
+
+	for listener, isSyncing := range p.listeners {
+		switch {
+		case !sync:
+			// non-sync messages are delivered to every listener
+			listener.add(obj)
+		case isSyncing:
+			// sync messages are delivered to every syncing listener
+			listener.add(obj)
+		default:
+			// skipping a sync obj for a non-syncing listener
+		}
+	}
+}
+
+// run starts every listener and blocks until stopCh is closed. It then stops
+// the listeners, dropping the notifications they have not handled yet, and
+// waits for their goroutines to exit. A handler busy with a notification
+// finishes it first.
+func (p *sharedProcessor[T]) run(stopCh <-chan struct{}) {
+	p.startListeners()
+	<-stopCh
+	p.stopListeners()
+}
+
+// startListeners starts every listener, and every listener added later on.
+// Notifications can only be distributed once the listeners are started.
+func (p *sharedProcessor[T]) startListeners() {
+	p.listenersLock.Lock()
+	defer p.listenersLock.Unlock()
+	for listener := range p.listeners {
+		p.start(listener.run)
+		p.start(listener.pop)
+	}
+	p.listenersStarted = true
+}
+
+// stopListeners stops every listener, see run.
+func (p *sharedProcessor[T]) stopListeners() {
+	p.listenersLock.Lock()
+	defer p.listenersLock.Unlock()
+	for listener := range p.listeners {
+		close(listener.addCh) // Tell .pop() to stop. .pop() will tell .run() to stop
+	}
+
+	// Wipe out list of listeners since they are now closed
+	// (processorListener cannot be re-used)
+	p.listeners = nil
+
+	// Reset to false since no listeners are running
+	p.listenersStarted = false
+
+	p.wg.Wait() // Wait for all .pop() and .run() to stop
+}
+
+// shouldResync queries every listener to determine if any of them need a resync, based on each
+// listener's resyncPeriod.
+func (p *sharedProcessor[T]) shouldResync() bool {
+	p.listenersLock.Lock()
+	defer p.listenersLock.Unlock()
+
+	resyncNeeded := false
+	now := p.clock.Now()
+	for listener := range p.listeners {
+		// need to loop through all the listeners to see if they need to resync so we can prepare any
+		// listeners that are going to be resyncing.
+		shouldResync := listener.shouldResync(now)
+		p.listeners[listener] = shouldResync
+
+		if shouldResync {
+			resyncNeeded = true
+			listener.determineNextResync(now)
+		}
+	}
+	return resyncNeeded
+}
+
+func (p *sharedProcessor[T]) resyncCheckPeriodChanged(resyncCheckPeriod time.Duration) {
+	p.listenersLock.RLock()
+	defer p.listenersLock.RUnlock()
+
+	for listener := range p.listeners {
+		resyncPeriod := determineResyncPeriod(listener.requestedResyncPeriod, resyncCheckPeriod)
+		listener.setResyncPeriod(resyncPeriod)
+	}
+}
+
+func (p *sharedProcessor[T]) start(f func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		f()
+	}()
+}
+
+type notificationKind int
+
+const (
+	addNotification notificationKind = iota
+	updateNotification
+	deleteNotification
+)
+
+// notification is an event waiting to be delivered to a listener.
+type notification[T any] struct {
+	kind   notificationKind
+	oldObj T
+	newObj T
+
+	isInInitialList bool
+	// tombstone is set on a delete whose final state is unknown.
+	tombstone *DeletedFinalStateUnknown[T]
+}
+
+// dispatch calls the method of handler matching the notification.
+func (n *notification[T]) dispatch(handler ResourceEventHandler[T]) {
+	switch n.kind {
+	case addNotification:
+		handler.OnAdd(n.newObj, n.isInInitialList)
+	case updateNotification:
+		handler.OnUpdate(n.oldObj, n.newObj)
+	case deleteNotification:
+		handler.OnDelete(n.oldObj, n.tombstone)
+	}
 }
 
+// object returns the state of the object the notification is about.
+func (n *notification[T]) object() T {
+	if n.kind == deleteNotification {
+		return n.oldObj
+	}
+	return n.newObj
+}
+
+// initialListAdd reports whether the notification is counted by the sync
+// tracker of its listener.
+func (n *notification[T]) initialListAdd() bool {
+	return n.kind == addNotification && n.isInInitialList
+}
+
+// merge folds next, a later notification about the same object, into n. The
+// handler has not seen n yet, so an add followed by an update is still an
+// add, and an update from the state it last saw is kept as such. An add
+// followed by a delete cancels out, in which case merge leaves n as is and
+// returns false.
+func (n *notification[T]) merge(next *notification[T]) bool {
+	switch {
+	case n.kind == addNotification && next.kind == deleteNotification:
+		// The handler never saw the object.
+		return false
+	case n.kind == addNotification && next.kind == updateNotification,
+		n.kind == updateNotification && next.kind == updateNotification:
+		n.newObj = next.newObj
+	case n.kind == deleteNotification && next.kind == addNotification:
+		// The handler last saw the deleted object, or an older state
+		// of it.
+		n.kind = updateNotification
+		n.newObj = next.newObj
+		n.tombstone = nil
+	default:
+		*n = *next
+	}
+	return true
+}
+
+// processorListener relays notifications from a sharedProcessor to
+// one ResourceEventHandler --- using two goroutines, two unbuffered
+// channels, and a ring buffer. The `add(notification)`
+// function sends the given notification to `addCh`. One goroutine
+// runs `pop()`, which pumps notifications from `addCh` to `nextCh`
+// using storage in the ring buffer while `nextCh` is not keeping up.
+// Another goroutine runs `run()`, which receives notifications from
+// `nextCh` and synchronously invokes the appropriate handler method.
 type processorListener[T any] struct {
-	nextCh chan T
-	addCh  chan T
+	nextCh chan *notification[T]
+	addCh  chan *notification[T]
+
+	handler ResourceEventHandler[T]
+
+	syncTracker *singleFileTracker
 
-	handler ResourceEventHandler
+	// pendingNotifications is a ring buffer that holds all notifications not yet distrubuted.
+	// There is one per listener. Unless maxPending caps it, a failing/stalled listener will
+	// have infinite pendingNotifications added until we OOM.
+	pendingNotifications buffer.RingGrowing[*notification[T]]
 
-	// pendingNotifications is an unbounded ring buffer that holds all notifications not yet distrubuted.
-	// There is one per listener, but a failing/stalled listener will hav infinite pendingNotifications
-	// added until we OOM.
-	pendingNotifications buffer.RingGrowing[T]
+	// The fields below are only used by pop.
+
+	// pending counts the notifications accepted but not yet handed to run,
+	// including the one pop is trying to send.
+	pending    int
+	maxPending int
+	policy     OverflowPolicy
+	keyFunc    KeyFunc[T]
+	// pendingByKey indexes the pending notifications for OverflowCoalesce.
+	// These are copies owned by the listener, as the notifications received
+	// are shared with the other listeners.
+	pendingByKey map[string]*notification[T]
+
+	evicted atomic.Bool
+
+	pendingMetric SettableGaugeMetric
+	droppedMetric CounterMetric
 
 	requestedResyncPeriod time.Duration
 
@@ -69,52 +947,286 @@ type processorListener[T any] struct {
 	resyncLock sync.Mutex
 }
 
-func newProcessListener[T any](handler ResourceEventHandler, requestedResyncPeriod, resyncPeriod time.Duration, now time.Time, bufferSize int) *processorListener[T] {
+// HasSynced returns true if the source informer has synced, and all
+// corresponding events have been delivered.
+func (p *processorListener[T]) HasSynced() bool {
+	return p.syncTracker.HasSynced()
+}
+
+// Healthy returns false once the listener has been evicted.
+func (p *processorListener[T]) Healthy() bool {
+	return !p.evicted.Load()
+}
+
+func newProcessListener[T any](handler ResourceEventHandler[T], requestedResyncPeriod, resyncPeriod time.Duration, now time.Time, bufferSize int, hasSynced func() bool, options HandlerOptions[T]) *processorListener[T] {
+	provider := options.MetricsProvider
+	if provider == nil || options.Name == "" {
+		provider = noopListenerMetricsProvider{}
+	}
 	ret := &processorListener[T]{
-		nextCh:                make(chan T),
-		addCh:                 make(chan T),
+		nextCh:                make(chan *notification[T]),
+		addCh:                 make(chan *notification[T]),
 		handler:               handler,
-		pendingNotifications:  *buffer.NewRingGrowing[T](bufferSize),
+		syncTracker:           &singleFileTracker{UpstreamHasSynced: hasSynced},
+		pendingNotifications:  *buffer.NewRingGrowing[*notification[T]](bufferSize),
+		maxPending:            options.MaxPending,
+		policy:                options.OverflowPolicy,
+		pendingMetric:         provider.NewPendingNotificationsMetric(options.Name),
+		droppedMetric:         provider.NewDroppedNotificationsMetric(options.Name),
 		requestedResyncPeriod: requestedResyncPeriod,
 		resyncPeriod:          resyncPeriod,
 	}
+	if options.OverflowPolicy == OverflowCoalesce {
+		ret.keyFunc = options.KeyFunc
+		ret.pendingByKey = make(map[string]*notification[T])
+	}
+
+	ret.determineNextResync(now)
 
 	return ret
 }
 
-func (p *processorListener[T]) add(notification T) {
+func (p *processorListener[T]) add(notification *notification[T]) {
+	if notification.initialListAdd() {
+		p.syncTracker.Start()
+	}
 	p.addCh <- notification
 }
 
 func (p *processorListener[T]) pop() {
 	defer close(p.nextCh) // Tell .run() to stop
 
-	var nextCh chan<- T
-	var notification T
+	// next is the notification waiting to be sent, if hasNext.
+	var next *notification[T]
+	var hasNext bool
+	// held is a notification for a new object received by a full
+	// OverflowCoalesce listener, waiting for room.
+	var held *notification[T]
+	enqueue := func(n *notification[T]) {
+		n = p.track(n)
+		if !hasNext {
+			// Optimize the case -skip adding to pendingNotifications
+			next, hasNext = n, true
+		} else {
+			p.pendingNotifications.WriteOne(n)
+		}
+	}
 	for {
+		var nextCh chan<- *notification[T]
+		if hasNext {
+			nextCh = p.nextCh
+		}
+		addCh := p.addCh
+		if held != nil || (p.full() && p.policy == OverflowBlock) {
+			// Block the informer until the handler catches up.
+			addCh = nil
+		}
 		select {
-		case nextCh <- notification:
+		case nextCh <- next:
 			// Notification dispatched
-			var ok bool
-			notification, ok = p.pendingNotifications.ReadOne()
-			if !ok { // Nothing to pop
-				nextCh = nil // Disable this select case
+			p.untrack(next)
+			next, hasNext = p.pendingNotifications.ReadOne()
+			if held != nil {
+				enqueue(held)
+				held = nil
 			}
-		case notificationToAdd, ok := <-p.addCh:
+		case notificationToAdd, ok := <-addCh:
 			if !ok {
 				return
 			}
-			if reflect.ValueOf(notification).IsNil() {
-				// Optimize the case -skip adding to pendingNotifications
-				notification = notificationToAdd
-				nextCh = p.nextCh
-			}else {
-				p.pendingNotifications.WriteOne(notificationToAdd)
+			if p.evicted.Load() {
+				p.drop(notificationToAdd)
+				continue
 			}
+			if merged, cancelled := p.coalesce(notificationToAdd); merged {
+				if cancelled != nil {
+					if hasNext && next == cancelled {
+						next, hasNext = p.pendingNotifications.ReadOne()
+					} else {
+						p.unbuffer(cancelled)
+					}
+					p.untrack(cancelled)
+				}
+				continue
+			}
+			if p.full() {
+				switch p.policy {
+				case OverflowCoalesce:
+					held = notificationToAdd
+					continue
+				case OverflowEvict:
+					p.evict(next, hasNext)
+					next, hasNext = nil, false
+					p.drop(notificationToAdd)
+					continue
+				default:
+					// OverflowDropOldest, the oldest notification is the
+					// one waiting to be sent.
+					p.drop(next)
+					p.untrack(next)
+					next, hasNext = p.pendingNotifications.ReadOne()
+				}
+			}
+			enqueue(notificationToAdd)
 		}
 	}
 }
 
+// full reports whether maxPending notifications are waiting.
+func (p *processorListener[T]) full() bool {
+	return p.maxPending > 0 && p.pending >= p.maxPending
+}
+
+// key returns the coalescing key of n, if there is one.
+func (p *processorListener[T]) key(n *notification[T]) (string, bool) {
+	if p.keyFunc == nil {
+		return "", false
+	}
+	key, err := p.keyFunc(n.object())
+	return key, err == nil
+}
+
+// coalesce merges n into the pending notification for the same object, if
+// there is one. If the two cancel out, the pending notification is returned
+// as cancelled and must no longer be delivered.
+func (p *processorListener[T]) coalesce(n *notification[T]) (merged bool, cancelled *notification[T]) {
+	key, ok := p.key(n)
+	if !ok {
+		return false, nil
+	}
+	pending, ok := p.pendingByKey[key]
+	if !ok {
+		return false, nil
+	}
+	// Both may have been counted by the sync tracker, the merged one is
+	// counted at most once.
+	tracked := 0
+	if pending.initialListAdd() {
+		tracked++
+	}
+	if n.initialListAdd() {
+		tracked++
+	}
+	if !pending.merge(n) {
+		cancelled = pending
+	} else if pending.initialListAdd() {
+		tracked--
+	}
+	for ; tracked > 0; tracked-- {
+		p.syncTracker.Finished()
+	}
+	return true, cancelled
+}
+
+// unbuffer removes n from pendingNotifications, keeping the order of the
+// others.
+func (p *processorListener[T]) unbuffer(n *notification[T]) {
+	var kept []*notification[T]
+	for buffered, ok := p.pendingNotifications.ReadOne(); ok; buffered, ok = p.pendingNotifications.ReadOne() {
+		if buffered != n {
+			kept = append(kept, buffered)
+		}
+	}
+	for _, buffered := range kept {
+		p.pendingNotifications.WriteOne(buffered)
+	}
+}
+
+// track counts n as pending and returns the notification to store for it.
+func (p *processorListener[T]) track(n *notification[T]) *notification[T] {
+	if key, ok := p.key(n); ok {
+		owned := *n
+		n = &owned
+		p.pendingByKey[key] = n
+	}
+	p.pending++
+	p.pendingMetric.Set(float64(p.pending))
+	return n
+}
+
+// untrack stops counting n as pending.
+func (p *processorListener[T]) untrack(n *notification[T]) {
+	if key, ok := p.key(n); ok && p.pendingByKey[key] == n {
+		delete(p.pendingByKey, key)
+	}
+	p.pending--
+	p.pendingMetric.Set(float64(p.pending))
+}
+
+// drop discards n, which will never reach the handler.
+func (p *processorListener[T]) drop(n *notification[T]) {
+	if n.initialListAdd() {
+		// Don't hold up HasSynced for it.
+		p.syncTracker.Finished()
+	}
+	p.droppedMetric.Inc()
+}
+
+// evict discards head, if hasHead, and every buffered notification, and
+// marks the listener unhealthy.
+func (p *processorListener[T]) evict(head *notification[T], hasHead bool) {
+	p.evicted.Store(true)
+	for n, ok := head, hasHead; ok; n, ok = p.pendingNotifications.ReadOne() {
+		p.drop(n)
+	}
+	for key := range p.pendingByKey {
+		delete(p.pendingByKey, key)
+	}
+	p.pending = 0
+	p.pendingMetric.Set(0)
+}
+
+// shouldResync deterimines if the listener needs a resync. If the listener's resyncPeriod is 0,
+// this always returns false.
+func (p *processorListener[T]) shouldResync(now time.Time) bool {
+	p.resyncLock.Lock()
+	defer p.resyncLock.Unlock()
+
+	if p.resyncPeriod == 0 {
+		return false
+	}
+
+	return now.After(p.nextResync) || now.Equal(p.nextResync)
+}
+
+func (p *processorListener[T]) determineNextResync(now time.Time) {
+	p.resyncLock.Lock()
+	defer p.resyncLock.Unlock()
+
+	p.nextResync = now.Add(p.resyncPeriod)
+}
+
+func (p *processorListener[T]) setResyncPeriod(resyncPeriod time.Duration) {
+	p.resyncLock.Lock()
+	defer p.resyncLock.Unlock()
+
+	p.resyncPeriod = resyncPeriod
+}
+
+// determineResyncPeriod returns the resync period of a handler requesting
+// desired from an informer checking for resyncs every check.
+func determineResyncPeriod(desired, check time.Duration) time.Duration {
+	if desired == 0 {
+		return desired
+	}
+	if check == 0 {
+		log.Printf("cache: the specified resyncPeriod %v is invalid because this shared informer doesn't support resyncing", desired)
+		return 0
+	}
+	if desired < check {
+		log.Printf("cache: the specified resyncPeriod %v is being increased to the minimum resyncCheckPeriod %v", desired, check)
+		return check
+	}
+	return desired
+}
+
+// run invokes the handler for every notification, one at a time and in the
+// order they were added, until pop closes nextCh.
 func (p *processorListener[T]) run() {
-	// stopCh := make(chan struct{})
-}
\ No newline at end of file
+	for next := range p.nextCh {
+		next.dispatch(p.handler)
+		if next.initialListAdd() {
+			p.syncTracker.Finished()
+		}
+	}
+}
@@ -0,0 +1,699 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testTimeout = 30 * time.Second
+
+// sequentialHandler records events and fails if two of its methods ever run
+// at the same time.
+type sequentialHandler struct {
+	t       *testing.T
+	running int32
+	lock    sync.Mutex
+	events  []string
+	delay   time.Duration
+}
+
+func (h *sequentialHandler) record(event string) {
+	if atomic.AddInt32(&h.running, 1) != 1 {
+		h.t.Errorf("handler invoked concurrently at %q", event)
+	}
+	time.Sleep(h.delay)
+	h.lock.Lock()
+	h.events = append(h.events, event)
+	h.lock.Unlock()
+	atomic.AddInt32(&h.running, -1)
+}
+
+func (h *sequentialHandler) OnAdd(obj string, isInInitialList bool) { h.record("add " + obj) }
+
+func (h *sequentialHandler) OnUpdate(oldObj, newObj string) {
+	h.record("update " + oldObj + "->" + newObj)
+}
+
+func (h *sequentialHandler) OnDelete(obj string, tombstone *DeletedFinalStateUnknown[string]) {
+	h.record("delete " + obj)
+}
+
+func (h *sequentialHandler) recorded() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]string(nil), h.events...)
+}
+
+func waitForEvents(t *testing.T, h *sequentialHandler, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		if events := h.recorded(); len(events) >= n {
+			return events
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d events, got %v", n, h.recorded())
+	return nil
+}
+
+func alwaysSynced() bool { return true }
+
+// runProcessor runs p until stopCh is closed and returns once its listeners
+// are started, along with a channel which is closed when run returns.
+func runProcessor[T any](t testing.TB, p *sharedProcessor[T], stopCh <-chan struct{}) <-chan struct{} {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.run(stopCh)
+	}()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		p.listenersLock.RLock()
+		started := p.listenersStarted
+		p.listenersLock.RUnlock()
+		if started {
+			return done
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the processor to start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSharedProcessorDeliversSequentially(t *testing.T) {
+	p := &sharedProcessor[string]{}
+	handlers := []*sequentialHandler{
+		{t: t},
+		{t: t, delay: time.Millisecond},
+	}
+	for _, h := range handlers {
+		p.addListener(newProcessListener[string](h, 0, 0, time.Now(), 4, alwaysSynced, HandlerOptions[string]{}))
+	}
+
+	stopCh := make(chan struct{})
+	done := runProcessor(t, p, stopCh)
+
+	var expected []string
+	for i := 0; i < 20; i++ {
+		obj := fmt.Sprint(i)
+		p.distribute(&notification[string]{kind: addNotification, newObj: obj}, false)
+		p.distribute(&notification[string]{kind: updateNotification, oldObj: obj, newObj: obj + "'"}, false)
+		p.distribute(&notification[string]{kind: deleteNotification, oldObj: obj + "'"}, false)
+		expected = append(expected, "add "+obj, "update "+obj+"->"+obj+"'", "delete "+obj+"'")
+	}
+
+	for i, h := range handlers {
+		if events := waitForEvents(t, h, len(expected)); !reflect.DeepEqual(expected, events) {
+			t.Errorf("handler %d: expected %v, got %v", i, expected, events)
+		}
+	}
+
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("sharedProcessor did not stop")
+	}
+}
+
+func TestSharedProcessorStopWaitsForHandler(t *testing.T) {
+	p := &sharedProcessor[string]{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished int32
+	p.addListener(newProcessListener[string](ResourceEventHandlerFuncs[string]{
+		AddFunc: func(obj string) {
+			close(started)
+			<-release
+			atomic.StoreInt32(&finished, 1)
+		},
+	}, 0, 0, time.Now(), 4, alwaysSynced, HandlerOptions[string]{}))
+
+	stopCh := make(chan struct{})
+	done := runProcessor(t, p, stopCh)
+
+	p.distribute(&notification[string]{kind: addNotification, newObj: "a"}, false)
+	<-started
+	close(stopCh)
+
+	select {
+	case <-done:
+		t.Fatal("sharedProcessor stopped while a handler was still running")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("sharedProcessor did not stop")
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("handler did not finish before the processor stopped")
+	}
+}
+
+func TestSharedProcessorRemoveListener(t *testing.T) {
+	p := &sharedProcessor[string]{}
+	removed := &sequentialHandler{t: t}
+	kept := &sequentialHandler{t: t}
+	handle := p.addListener(newProcessListener[string](removed, 0, 0, time.Now(), 4, alwaysSynced, HandlerOptions[string]{}))
+	p.addListener(newProcessListener[string](kept, 0, 0, time.Now(), 4, alwaysSynced, HandlerOptions[string]{}))
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	runProcessor(t, p, stopCh)
+
+	p.distribute(&notification[string]{kind: addNotification, newObj: "a"}, false)
+	waitForEvents(t, removed, 1)
+
+	if err := p.removeListener(handle); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Removing twice is a no-op.
+	if err := p.removeListener(handle); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.distribute(&notification[string]{kind: addNotification, newObj: "b"}, false)
+
+	waitForEvents(t, kept, 2)
+	if events := removed.recorded(); !reflect.DeepEqual([]string{"add a"}, events) {
+		t.Errorf("removed handler got %v", events)
+	}
+}
+
+func TestProcessorListenerHasSynced(t *testing.T) {
+	p := &sharedProcessor[string]{}
+	upstreamSynced := false
+	release := make(chan struct{})
+	listener := newProcessListener[string](ResourceEventHandlerFuncs[string]{
+		AddFunc: func(obj string) { <-release },
+	}, 0, 0, time.Now(), 4, func() bool { return upstreamSynced }, HandlerOptions[string]{})
+	p.addListener(listener)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	runProcessor(t, p, stopCh)
+
+	p.distribute(&notification[string]{kind: addNotification, newObj: "a", isInInitialList: true}, false)
+	upstreamSynced = true
+	if listener.HasSynced() {
+		t.Error("expected HasSynced to be false while the initial list is being handled")
+	}
+
+	close(release)
+	deadline := time.Now().Add(testTimeout)
+	for !listener.HasSynced() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for HasSynced")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type fakeListenerMetrics struct {
+	lock    sync.Mutex
+	pending map[string]float64
+	dropped map[string]int
+}
+
+func newFakeListenerMetrics() *fakeListenerMetrics {
+	return &fakeListenerMetrics{pending: map[string]float64{}, dropped: map[string]int{}}
+}
+
+type fakeGauge struct {
+	m    *fakeListenerMetrics
+	name string
+}
+
+func (g fakeGauge) Set(v float64) {
+	g.m.lock.Lock()
+	defer g.m.lock.Unlock()
+	g.m.pending[g.name] = v
+}
+
+type fakeCounter struct {
+	m    *fakeListenerMetrics
+	name string
+}
+
+func (c fakeCounter) Inc() {
+	c.m.lock.Lock()
+	defer c.m.lock.Unlock()
+	c.m.dropped[c.name]++
+}
+
+func (m *fakeListenerMetrics) NewPendingNotificationsMetric(name string) SettableGaugeMetric {
+	return fakeGauge{m: m, name: name}
+}
+
+func (m *fakeListenerMetrics) NewDroppedNotificationsMetric(name string) CounterMetric {
+	return fakeCounter{m: m, name: name}
+}
+
+func (m *fakeListenerMetrics) get(name string) (float64, int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.pending[name], m.dropped[name]
+}
+
+// gatedHandler records events, blocking in its first call until released.
+type gatedHandler struct {
+	sequentialHandler
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newGatedHandler(t *testing.T) *gatedHandler {
+	return &gatedHandler{
+		sequentialHandler: sequentialHandler{t: t},
+		started:           make(chan struct{}),
+		release:           make(chan struct{}),
+	}
+}
+
+func (h *gatedHandler) gate() {
+	h.once.Do(func() {
+		close(h.started)
+		<-h.release
+	})
+}
+
+func (h *gatedHandler) OnAdd(obj string, isInInitialList bool) {
+	h.gate()
+	h.sequentialHandler.OnAdd(obj, isInInitialList)
+}
+
+func (h *gatedHandler) OnUpdate(oldObj, newObj string) {
+	h.gate()
+	h.sequentialHandler.OnUpdate(oldObj, newObj)
+}
+
+func (h *gatedHandler) OnDelete(obj string, tombstone *DeletedFinalStateUnknown[string]) {
+	h.gate()
+	h.sequentialHandler.OnDelete(obj, tombstone)
+}
+
+func addOf(obj string) *notification[string] {
+	return &notification[string]{kind: addNotification, newObj: obj}
+}
+
+func updateOf(oldObj, newObj string) *notification[string] {
+	return &notification[string]{kind: updateNotification, oldObj: oldObj, newObj: newObj}
+}
+
+func deleteOf(obj string) *notification[string] {
+	return &notification[string]{kind: deleteNotification, oldObj: obj}
+}
+
+// startGated runs a processor with a single gated handler registered with
+// options, and waits until the handler is stuck on the first notification.
+func startGated(t *testing.T, options HandlerOptions[string]) (*sharedProcessor[string], *gatedHandler, *processorListener[string], chan struct{}) {
+	t.Helper()
+	p := &sharedProcessor[string]{}
+	h := newGatedHandler(t)
+	listener := newProcessListener[string](h, 0, 0, time.Now(), 1, alwaysSynced, options)
+	p.addListener(listener)
+	stopCh := make(chan struct{})
+	runProcessor(t, p, stopCh)
+	p.distribute(addOf("first"), false)
+	<-h.started
+	return p, h, listener, stopCh
+}
+
+func TestProcessorListenerOverflowBlock(t *testing.T) {
+	p, h, _, stopCh := startGated(t, HandlerOptions[string]{MaxPending: 2})
+	defer close(stopCh)
+
+	p.distribute(addOf("a"), false)
+	p.distribute(addOf("b"), false)
+	distributed := make(chan struct{})
+	go func() {
+		defer close(distributed)
+		p.distribute(addOf("c"), false)
+	}()
+	select {
+	case <-distributed:
+		t.Fatal("expected distribute to block while the handler is behind")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(h.release)
+	<-distributed
+	expected := []string{"add first", "add a", "add b", "add c"}
+	if events := waitForEvents(t, &h.sequentialHandler, len(expected)); !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
+
+func TestProcessorListenerOverflowDropOldest(t *testing.T) {
+	metrics := newFakeListenerMetrics()
+	upstreamSynced := func() bool { return true }
+	p := &sharedProcessor[string]{}
+	h := newGatedHandler(t)
+	listener := newProcessListener[string](h, 0, 0, time.Now(), 1, upstreamSynced, HandlerOptions[string]{
+		Name:            "drop",
+		MaxPending:      2,
+		OverflowPolicy:  OverflowDropOldest,
+		MetricsProvider: metrics,
+	})
+	p.addListener(listener)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	runProcessor(t, p, stopCh)
+
+	p.distribute(addOf("first"), false)
+	<-h.started
+	for _, obj := range []string{"a", "b", "c", "d"} {
+		p.distribute(&notification[string]{kind: addNotification, newObj: obj, isInInitialList: true}, false)
+	}
+	if pending, dropped := metrics.get("drop"); pending != 2 || dropped != 2 {
+		t.Errorf("Expected 2 pending and 2 dropped, got %v and %v", pending, dropped)
+	}
+
+	close(h.release)
+	expected := []string{"add first", "add c", "add d"}
+	if events := waitForEvents(t, &h.sequentialHandler, len(expected)); !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+	// The dropped initial adds must not hold up HasSynced.
+	deadline := time.Now().Add(testTimeout)
+	for !listener.HasSynced() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for HasSynced")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if pending, _ := metrics.get("drop"); pending != 0 {
+		t.Errorf("Expected no pending notifications, got %v", pending)
+	}
+}
+
+func TestProcessorListenerOverflowCoalesce(t *testing.T) {
+	// Objects are "key=value".
+	keyFunc := func(obj string) (string, error) {
+		key, _, ok := strings.Cut(obj, "=")
+		if !ok {
+			return "", fmt.Errorf("no key in %q", obj)
+		}
+		return key, nil
+	}
+	p, h, _, stopCh := startGated(t, HandlerOptions[string]{
+		MaxPending:     2,
+		OverflowPolicy: OverflowCoalesce,
+		KeyFunc:        keyFunc,
+	})
+	defer close(stopCh)
+
+	updates := []*notification[string]{
+		addOf("x=1"),
+		updateOf("x=1", "x=2"),
+		updateOf("x=2", "x=3"),
+		updateOf("y=1", "y=2"),
+		deleteOf("y=2"),
+		addOf("y=3"),
+		updateOf("y=3", "y=4"),
+		// Waits for room, as two objects are pending.
+		addOf("z=1"),
+	}
+	for _, n := range updates {
+		p.distribute(n, false)
+	}
+	// The notifications distributed are shared with other listeners and
+	// must not be modified.
+	if n := updates[0]; n.kind != addNotification || n.newObj != "x=1" {
+		t.Errorf("distributed notification was modified: %+v", n)
+	}
+
+	close(h.release)
+	expected := []string{"add first", "add x=3", "update y=2->y=4", "add z=1"}
+	if events := waitForEvents(t, &h.sequentialHandler, len(expected)); !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
+
+func TestProcessorListenerOverflowCoalesceAddDelete(t *testing.T) {
+	metrics := newFakeListenerMetrics()
+	p, h, listener, stopCh := startGated(t, HandlerOptions[string]{
+		Name:           "coalesce",
+		MaxPending:     2,
+		OverflowPolicy: OverflowCoalesce,
+		KeyFunc: func(obj string) (string, error) {
+			key, _, _ := strings.Cut(obj, "=")
+			return key, nil
+		},
+		MetricsProvider: metrics,
+	})
+	defer close(stopCh)
+
+	for _, n := range []*notification[string]{
+		// x is the next notification to send, y is buffered behind it.
+		{kind: addNotification, newObj: "x=1", isInInitialList: true},
+		addOf("y=1"),
+		updateOf("y=1", "y=2"),
+		deleteOf("y=2"),
+		deleteOf("x=1"),
+		addOf("z=1"),
+	} {
+		p.distribute(n, false)
+	}
+	close(h.release)
+	expected := []string{"add first", "add z=1"}
+	if events := waitForEvents(t, &h.sequentialHandler, len(expected)); !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+	// The cancelled initial add must not hold up HasSynced.
+	deadline := time.Now().Add(testTimeout)
+	for !listener.HasSynced() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for HasSynced")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if events := h.sequentialHandler.recorded(); !reflect.DeepEqual(expected, events) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+	if pending, _ := metrics.get("coalesce"); pending != 0 {
+		t.Errorf("Expected no pending notifications, got %v", pending)
+	}
+}
+
+func TestProcessorListenerOverflowEvict(t *testing.T) {
+	metrics := newFakeListenerMetrics()
+	p, h, listener, stopCh := startGated(t, HandlerOptions[string]{
+		Name:            "evict",
+		MaxPending:      1,
+		OverflowPolicy:  OverflowEvict,
+		MetricsProvider: metrics,
+	})
+	defer close(stopCh)
+
+	p.distribute(addOf("a"), false)
+	if !listener.Healthy() {
+		t.Fatal("expected the listener to be healthy before overflowing")
+	}
+	p.distribute(addOf("b"), false)
+	if listener.Healthy() {
+		t.Fatal("expected the listener to be evicted")
+	}
+	// Evicted listeners never block the informer.
+	for i := 0; i < 10; i++ {
+		p.distribute(addOf(fmt.Sprint(i)), false)
+	}
+	if pending, dropped := metrics.get("evict"); pending != 0 || dropped != 12 {
+		t.Errorf("Expected 0 pending and 12 dropped, got %v and %v", pending, dropped)
+	}
+
+	close(h.release)
+	waitForEvents(t, &h.sequentialHandler, 1)
+	time.Sleep(10 * time.Millisecond)
+	if events := h.recorded(); !reflect.DeepEqual([]string{"add first"}, events) {
+		t.Errorf("evicted handler got %v", events)
+	}
+}
+
+// syncAfter returns an InformerSynced which reports synced from its nth call
+// on.
+func syncAfter(n int32) InformerSynced {
+	var calls int32
+	return func() bool {
+		return atomic.AddInt32(&calls, 1) >= n
+	}
+}
+
+func TestWaitForCacheSync(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if !WaitForCacheSync(stopCh, alwaysSynced, syncAfter(3)) {
+		t.Error("expected the caches to sync")
+	}
+	if !WaitForCacheSync(stopCh) {
+		t.Error("expected no caches to be synced right away")
+	}
+}
+
+func TestWaitForCacheSyncStopped(t *testing.T) {
+	stopCh := make(chan struct{})
+	time.AfterFunc(10*time.Millisecond, func() { close(stopCh) })
+	if WaitForNamedCacheSync("test", stopCh, NamedInformerSynced{Name: "a", Synced: alwaysSynced}, NamedInformerSynced{Name: "b", Synced: func() bool { return false }}) {
+		t.Error("expected the wait to give up")
+	}
+	if !WaitForNamedCacheSync("test", make(chan struct{}), NamedInformerSynced{Name: "a", Synced: syncAfter(2)}) {
+		t.Error("expected the caches to sync")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if WaitForCacheSyncWithContext(ctx, func() bool { return false }) {
+		t.Error("expected the wait to give up")
+	}
+	if !WaitForCacheSyncWithContext(context.Background(), alwaysSynced) {
+		t.Error("expected the caches to sync")
+	}
+}
+
+func TestWaitForCacheSyncLogsPending(t *testing.T) {
+	var lock sync.Mutex
+	var logged [][]string
+	var syncedB atomic.Bool
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	done := make(chan bool)
+	go func() {
+		done <- waitForCacheSync(stopCh, time.Millisecond, 5*time.Millisecond,
+			[]NamedInformerSynced{
+				{Name: "a", Synced: alwaysSynced},
+				{Name: "b", Synced: syncedB.Load},
+				{Name: "c", Synced: func() bool { return syncedB.Load() }},
+			},
+			func(pending []string) {
+				lock.Lock()
+				defer lock.Unlock()
+				logged = append(logged, pending)
+			})
+	}()
+
+	deadline := time.Now().Add(testTimeout)
+	for {
+		lock.Lock()
+		n := len(logged)
+		lock.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the pending caches to be logged")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	syncedB.Store(true)
+	if !<-done {
+		t.Error("expected the caches to sync")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if e, a := []string{"b", "c"}, logged[0]; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected caches %v to be pending, got %v", e, a)
+	}
+}
+
+func TestHandlerOptionsValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		options HandlerOptions[string]
+		valid   bool
+	}{
+		"default":  {valid: true},
+		"negative": {options: HandlerOptions[string]{MaxPending: -1}},
+		"coalesce without key": {
+			options: HandlerOptions[string]{OverflowPolicy: OverflowCoalesce},
+		},
+		"coalesce": {
+			options: HandlerOptions[string]{OverflowPolicy: OverflowCoalesce, KeyFunc: func(string) (string, error) { return "", nil }},
+			valid:   true,
+		},
+		"unknown": {options: HandlerOptions[string]{OverflowPolicy: OverflowPolicy(42)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := tc.options.validate(); (err == nil) != tc.valid {
+				t.Errorf("Expected valid=%v, got %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+type point struct {
+	x, y int
+}
+
+func TestSharedProcessorValueTypes(t *testing.T) {
+	p := &sharedProcessor[point]{}
+	var got []point
+	done := make(chan struct{})
+	p.addListener(newProcessListener[point](ResourceEventHandlerFuncs[point]{
+		UpdateFunc: func(oldObj, newObj point) {
+			got = append(got, oldObj, newObj)
+		},
+		DeleteFunc: func(obj point, tombstone *DeletedFinalStateUnknown[point]) {
+			got = append(got, obj)
+			close(done)
+		},
+	}, 0, 0, time.Now(), 1, alwaysSynced, HandlerOptions[point]{}))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	runProcessor(t, p, stopCh)
+
+	// The zero value of T is a notification like any other.
+	p.distribute(&notification[point]{kind: updateNotification, oldObj: point{}, newObj: point{1, 2}}, false)
+	p.distribute(&notification[point]{kind: deleteNotification}, false)
+	<-done
+	if expected := []point{{}, {1, 2}, {}}; !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func benchmarkSharedProcessor(b *testing.B, listeners int, options HandlerOptions[int]) {
+	p := &sharedProcessor[int]{}
+	var wg sync.WaitGroup
+	for i := 0; i < listeners; i++ {
+		p.addListener(newProcessListener[int](ResourceEventHandlerFuncs[int]{
+			AddFunc: func(int) { wg.Done() },
+		}, 0, 0, time.Now(), 1024, alwaysSynced, options))
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	runProcessor(b, p, stopCh)
+
+	wg.Add(b.N * listeners)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.distribute(&notification[int]{kind: addNotification, newObj: i}, false)
+	}
+	wg.Wait()
+	b.StopTimer()
+}
+
+// BenchmarkSharedProcessor measures the cost of delivering one event, from
+// distribute to the handler, with listeners that keep up.
+func BenchmarkSharedProcessor(b *testing.B) {
+	for _, listeners := range []int{1, 10} {
+		b.Run(fmt.Sprintf("listeners=%d", listeners), func(b *testing.B) {
+			benchmarkSharedProcessor(b, listeners, HandlerOptions[int]{})
+		})
+		b.Run(fmt.Sprintf("listeners=%d/bounded", listeners), func(b *testing.B) {
+			benchmarkSharedProcessor(b, listeners, HandlerOptions[int]{MaxPending: 100})
+		})
+	}
+}
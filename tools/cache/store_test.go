@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type testStoreObject struct {
+	id  string
+	val string
+}
+
+func testStoreKeyFunc(obj testStoreObject) (string, error) {
+	return obj.id, nil
+}
+
+func testStoreIndexFunc(obj testStoreObject) ([]string, error) {
+	return []string{obj.val}, nil
+}
+
+func testStoreIndexers() Indexers[testStoreObject] {
+	return Indexers[testStoreObject]{"by_val": testStoreIndexFunc}
+}
+
+func mkObj(id, val string) testStoreObject {
+	return testStoreObject{id: id, val: val}
+}
+
+func ids(objs []testStoreObject) []string {
+	found := []string{}
+	for _, obj := range objs {
+		found = append(found, obj.id)
+	}
+	sort.Strings(found)
+	return found
+}
+
+// Test public interface
+func doTestStore(t *testing.T, store Store[testStoreObject]) {
+	store.Add(mkObj("foo", "bar"))
+	if item, ok, _ := store.Get(mkObj("foo", "")); !ok {
+		t.Errorf("didn't find inserted item")
+	} else if e, a := "bar", item.val; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	store.Update(mkObj("foo", "baz"))
+	if item, ok, _ := store.Get(mkObj("foo", "")); !ok {
+		t.Errorf("didn't find inserted item")
+	} else if e, a := "baz", item.val; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	store.Delete(mkObj("foo", ""))
+	if _, ok, _ := store.Get(mkObj("foo", "")); ok {
+		t.Errorf("found deleted item??")
+	}
+
+	// Test List.
+	store.Add(mkObj("a", "b"))
+	store.Add(mkObj("c", "d"))
+	store.Add(mkObj("e", "e"))
+	if e, a := []string{"a", "c", "e"}, ids(store.List()); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	keys := store.ListKeys()
+	sort.Strings(keys)
+	if e, a := []string{"a", "c", "e"}, keys; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Test Replace.
+	store.Replace([]testStoreObject{
+		mkObj("foo", "foo"),
+		mkObj("bar", "bar"),
+	}, "0")
+	if e, a := []string{"bar", "foo"}, ids(store.List()); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if item, ok, _ := store.GetByKey("bar"); !ok || item.val != "bar" {
+		t.Errorf("expected bar to be stored, got %v %v", item, ok)
+	}
+	if err := store.Resync(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// Test public interface
+func doTestIndex(t *testing.T, indexer Indexer[testStoreObject]) {
+	mkObj := func(id string, val string) testStoreObject {
+		return testStoreObject{id: id, val: val}
+	}
+
+	// Test Index
+	expected := map[string][]string{}
+	expected["b"] = []string{"a", "c"}
+	expected["f"] = []string{"e"}
+	expected["h"] = []string{"g"}
+	indexer.Add(mkObj("a", "b"))
+	indexer.Add(mkObj("c", "b"))
+	indexer.Add(mkObj("e", "f"))
+	indexer.Add(mkObj("g", "h"))
+	for k, v := range expected {
+		indexResults, err := indexer.Index("by_val", mkObj("", k))
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if e, a := v, ids(indexResults); !reflect.DeepEqual(e, a) {
+			t.Errorf("Index %s: expected %v, got %v", k, e, a)
+		}
+		byIndex, err := indexer.ByIndex("by_val", k)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if e, a := v, ids(byIndex); !reflect.DeepEqual(e, a) {
+			t.Errorf("ByIndex %s: expected %v, got %v", k, e, a)
+		}
+		keys, err := indexer.IndexKeys("by_val", k)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		sort.Strings(keys)
+		if e, a := v, keys; !reflect.DeepEqual(e, a) {
+			t.Errorf("IndexKeys %s: expected %v, got %v", k, e, a)
+		}
+	}
+	if e, a := []string{"b", "f", "h"}, indexer.ListIndexFuncValues("by_val"); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Updating and deleting objects moves them in the index.
+	indexer.Update(mkObj("a", "f"))
+	indexer.Delete(mkObj("g", ""))
+	if keys, _ := indexer.IndexKeys("by_val", "f"); !reflect.DeepEqual([]string{"a", "e"}, sortedCopy(keys)) {
+		t.Errorf("expected a and e to be indexed by f, got %v", keys)
+	}
+	if e, a := []string{"b", "f"}, indexer.ListIndexFuncValues("by_val"); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if _, err := indexer.ByIndex("missing", "b"); err == nil {
+		t.Error("expected an error for a missing index")
+	}
+}
+
+func sortedCopy(s []string) []string {
+	c := append([]string(nil), s...)
+	sort.Strings(c)
+	return c
+}
+
+func TestCache(t *testing.T) {
+	doTestStore(t, NewStore(testStoreKeyFunc))
+}
+
+func TestIndex(t *testing.T) {
+	doTestIndex(t, NewIndexer(testStoreKeyFunc, testStoreIndexers()))
+}
+
+func TestIndexerAddIndexers(t *testing.T) {
+	indexer := NewIndexer(testStoreKeyFunc, testStoreIndexers())
+	indexer.Add(mkObj("a", "b"))
+	indexer.Add(mkObj("c", "d"))
+
+	byID := func(obj testStoreObject) ([]string, error) { return []string{strings.ToUpper(obj.id)}, nil }
+	if err := indexer.AddIndexers(Indexers[testStoreObject]{"by_id": byID}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Objects stored before the indexer was added are indexed too.
+	if objs, _ := indexer.ByIndex("by_id", "C"); !reflect.DeepEqual([]string{"c"}, ids(objs)) {
+		t.Errorf("expected c to be indexed, got %v", objs)
+	}
+	if err := indexer.AddIndexers(Indexers[testStoreObject]{"by_val": testStoreIndexFunc}); err == nil {
+		t.Error("expected conflicting indexers to be rejected")
+	}
+	if _, exists := indexer.GetIndexers()["by_id"]; !exists {
+		t.Error("expected by_id to be listed in the indexers")
+	}
+
+	// Replace rebuilds every index.
+	indexer.Replace([]testStoreObject{mkObj("e", "f")}, "1")
+	if e, a := []string{"E"}, indexer.ListIndexFuncValues("by_id"); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := []string{"f"}, indexer.ListIndexFuncValues("by_val"); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestStoreKeyError(t *testing.T) {
+	errBadKey := errors.New("bad key")
+	store := NewStore(func(testStoreObject) (string, error) { return "", errBadKey })
+	err := store.Add(mkObj("a", "b"))
+	var keyErr KeyError
+	if !errors.As(err, &keyErr) || !errors.Is(err, errBadKey) {
+		t.Errorf("expected a KeyError wrapping the key func error, got %v", err)
+	}
+}
+
+func TestMetaNamespaceKeyFunc(t *testing.T) {
+	for _, tc := range []struct {
+		obj       *testObject
+		key       string
+		namespace string
+	}{
+		{obj: newTestObject("ns", "a", "", ""), key: "ns/a", namespace: "ns"},
+		{obj: newTestObject("", "a", "", ""), key: "a"},
+	} {
+		key, err := MetaNamespaceKeyFunc(tc.obj)
+		if err != nil || key != tc.key {
+			t.Errorf("expected key %q, got %q, %v", tc.key, key, err)
+		}
+		namespace, name, err := SplitMetaNamespaceKey(key)
+		if err != nil || namespace != tc.namespace || name != tc.obj.Name {
+			t.Errorf("SplitMetaNamespaceKey(%q) = %q, %q, %v", key, namespace, name, err)
+		}
+	}
+	if _, _, err := SplitMetaNamespaceKey("a/b/c"); err == nil {
+		t.Error("expected an error for a malformed key")
+	}
+}
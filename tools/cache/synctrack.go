@@ -0,0 +1,50 @@
+package cache
+
+import "sync"
+
+// singleFileTracker helps propagate HasSynced when events are processed in
+// order (i.e. via a queue).
+type singleFileTracker struct {
+	// lock guards count.
+	lock  sync.Mutex
+	count int64
+
+	// UpstreamHasSynced reports whether the source of the events has
+	// delivered its whole initial list.
+	UpstreamHasSynced func() bool
+}
+
+// Start should be called prior to processing each key which is part of the
+// initial list.
+func (t *singleFileTracker) Start() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.count++
+}
+
+// Finished should be called when finished processing a key which was part of
+// the initial list. You must never call Finished() before (or without) its
+// corresponding Start(), that is a logic error that could cause HasSynced to
+// return a wrong value. To help you notice this should it happen, Finished()
+// will panic if the internal counter goes negative.
+func (t *singleFileTracker) Finished() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.count--
+	if t.count < 0 {
+		panic("synctrack: negative counter; this logic error means HasSynced may return incorrect value")
+	}
+}
+
+// HasSynced returns true if the source has synced and every item it
+// delivered before that has been processed.
+func (t *singleFileTracker) HasSynced() bool {
+	// Ask upstream first, so that everything it delivered before it
+	// synced has already been counted.
+	if t.UpstreamHasSynced == nil || !t.UpstreamHasSynced() {
+		return false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.count <= 0
+}
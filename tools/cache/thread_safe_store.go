@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ThreadSafeStore is an interface that allows concurrent indexed
+// access to a storage backend. It is like Indexer but does not
+// (necessarily) know how to extract the Store key from a given
+// object.
+//
+// TL;DR caveats: you must not modify anything returned by Get or List as it will break
+// the indexing feature in addition to not being thread safe.
+//
+// The guarantees of thread safety provided by List/Get are only valid if the caller
+// treats returned items as read-only. For example, a pointer inserted in the store
+// through `Add` will be returned as is by `Get`. Multiple clients might invoke `Get`
+// on the same key and modify the pointer in a non-thread-safe way. Also note that
+// modifying objects stored by the indexers (if any) will *not* automatically lead
+// to a re-index. So it's not a good idea to directly modify the objects returned by
+// Get/List, in general.
+type ThreadSafeStore[T any] interface {
+	Add(key string, obj T)
+	Update(key string, obj T)
+	Delete(key string)
+	Get(key string) (item T, exists bool)
+	List() []T
+	ListKeys() []string
+	Replace(items map[string]T, resourceVersion string)
+	Index(indexName string, obj T) ([]T, error)
+	IndexKeys(indexName, indexedValue string) ([]string, error)
+	ListIndexFuncValues(name string) []string
+	ByIndex(indexName, indexedValue string) ([]T, error)
+	GetIndexers() Indexers[T]
+
+	// AddIndexers adds more indexers to this store. This supports adding indexes after the store already has items.
+	AddIndexers(newIndexers Indexers[T]) error
+}
+
+// index maps the indexed value to the set of keys in the store that match on
+// that value.
+type index map[string]map[string]struct{}
+
+// threadSafeMap implements ThreadSafeStore
+type threadSafeMap[T any] struct {
+	lock  sync.RWMutex
+	items map[string]T
+
+	indexers Indexers[T]
+	// indices maps the name of an indexer to its index.
+	indices map[string]index
+}
+
+// NewThreadSafeStore creates a new instance of ThreadSafeStore.
+func NewThreadSafeStore[T any](indexers Indexers[T]) ThreadSafeStore[T] {
+	return newThreadSafeMap(indexers)
+}
+
+func newThreadSafeMap[T any](indexers Indexers[T]) *threadSafeMap[T] {
+	c := &threadSafeMap[T]{
+		items:    map[string]T{},
+		indexers: Indexers[T]{},
+		indices:  map[string]index{},
+	}
+	for name, indexFunc := range indexers {
+		c.indexers[name] = indexFunc
+		c.indices[name] = index{}
+	}
+	return c
+}
+
+func (c *threadSafeMap[T]) Add(key string, obj T) {
+	c.Update(key, obj)
+}
+
+func (c *threadSafeMap[T]) Update(key string, obj T) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	oldObj, exists := c.items[key]
+	c.items[key] = obj
+	c.updateIndices(oldObj, exists, obj, true, key)
+}
+
+func (c *threadSafeMap[T]) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if obj, exists := c.items[key]; exists {
+		var zero T
+		c.updateIndices(obj, true, zero, false, key)
+		delete(c.items, key)
+	}
+}
+
+func (c *threadSafeMap[T]) Get(key string) (item T, exists bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	item, exists = c.items[key]
+	return item, exists
+}
+
+func (c *threadSafeMap[T]) List() []T {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	list := make([]T, 0, len(c.items))
+	for _, item := range c.items {
+		list = append(list, item)
+	}
+	return list
+}
+
+// ListKeys returns a list of all the keys of the objects currently
+// in the threadSafeMap.
+func (c *threadSafeMap[T]) ListKeys() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	list := make([]string, 0, len(c.items))
+	for key := range c.items {
+		list = append(list, key)
+	}
+	return list
+}
+
+func (c *threadSafeMap[T]) Replace(items map[string]T, resourceVersion string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items = items
+
+	// rebuild any index
+	var zero T
+	for name := range c.indices {
+		c.indices[name] = index{}
+	}
+	for key, item := range c.items {
+		c.updateIndices(zero, false, item, true, key)
+	}
+}
+
+// Index returns a list of items that match the given object on the index function.
+// Index is thread-safe so long as you treat all items as immutable.
+func (c *threadSafeMap[T]) Index(indexName string, obj T) ([]T, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	indexFunc := c.indexers[indexName]
+	if indexFunc == nil {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+
+	indexedValues, err := indexFunc(obj)
+	if err != nil {
+		return nil, err
+	}
+	idx := c.indices[indexName]
+
+	var storeKeySet map[string]struct{}
+	if len(indexedValues) == 1 {
+		// In majority of cases, there is exactly one value matching.
+		// Optimize the most common path - deduping is not needed here.
+		storeKeySet = idx[indexedValues[0]]
+	} else {
+		// Need to de-dupe the return list.
+		// Since multiple keys are allowed, this can happen.
+		storeKeySet = map[string]struct{}{}
+		for _, indexedValue := range indexedValues {
+			for key := range idx[indexedValue] {
+				storeKeySet[key] = struct{}{}
+			}
+		}
+	}
+
+	list := make([]T, 0, len(storeKeySet))
+	for storeKey := range storeKeySet {
+		list = append(list, c.items[storeKey])
+	}
+	return list, nil
+}
+
+// ByIndex returns a list of the items whose indexed values in the given index include the given indexed value
+func (c *threadSafeMap[T]) ByIndex(indexName, indexedValue string) ([]T, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	idx, exists := c.indices[indexName]
+	if !exists {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	set := idx[indexedValue]
+	list := make([]T, 0, len(set))
+	for key := range set {
+		list = append(list, c.items[key])
+	}
+	return list, nil
+}
+
+// IndexKeys returns a list of the Store keys of the objects whose indexed values in the given index include the given indexed value.
+// IndexKeys is thread-safe so long as you treat all items as immutable.
+func (c *threadSafeMap[T]) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	idx, exists := c.indices[indexName]
+	if !exists {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	set := idx[indexedValue]
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ListIndexFuncValues returns the indexed values of the given index, sorted.
+func (c *threadSafeMap[T]) ListIndexFuncValues(indexName string) []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	idx := c.indices[indexName]
+	names := make([]string, 0, len(idx))
+	for key := range idx {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *threadSafeMap[T]) GetIndexers() Indexers[T] {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	indexers := make(Indexers[T], len(c.indexers))
+	for name, indexFunc := range c.indexers {
+		indexers[name] = indexFunc
+	}
+	return indexers
+}
+
+func (c *threadSafeMap[T]) AddIndexers(newIndexers Indexers[T]) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for name := range newIndexers {
+		if _, exists := c.indexers[name]; exists {
+			return fmt.Errorf("indexer conflict: %v", name)
+		}
+	}
+
+	var zero T
+	for name, indexFunc := range newIndexers {
+		c.indexers[name] = indexFunc
+		c.indices[name] = index{}
+		for key, item := range c.items {
+			c.updateIndex(name, indexFunc, zero, false, item, true, key)
+		}
+	}
+	return nil
+}
+
+// updateIndices modifies the objects location in the managed indexes:
+// - for create you must provide only the newObj
+// - for update you must provide both the oldObj and the newObj
+// - for delete you must provide only the oldObj
+// updateIndices must be called from a function that already has a lock on the cache
+func (c *threadSafeMap[T]) updateIndices(oldObj T, hasOld bool, newObj T, hasNew bool, key string) {
+	for name, indexFunc := range c.indexers {
+		c.updateIndex(name, indexFunc, oldObj, hasOld, newObj, hasNew, key)
+	}
+}
+
+func (c *threadSafeMap[T]) updateIndex(name string, indexFunc IndexFunc[T], oldObj T, hasOld bool, newObj T, hasNew bool, key string) {
+	var oldIndexValues, indexValues []string
+	var err error
+	if hasOld {
+		oldIndexValues, err = indexFunc(oldObj)
+		if err != nil {
+			panic(fmt.Errorf("unable to calculate an index entry for key %q on index %q: %v", key, name, err))
+		}
+	}
+	if hasNew {
+		indexValues, err = indexFunc(newObj)
+		if err != nil {
+			panic(fmt.Errorf("unable to calculate an index entry for key %q on index %q: %v", key, name, err))
+		}
+	}
+
+	idx := c.indices[name]
+	for _, value := range oldIndexValues {
+		set := idx[value]
+		delete(set, key)
+		if len(set) == 0 {
+			// Delete the set when empty to prevent memory leaks.
+			delete(idx, value)
+		}
+	}
+	for _, value := range indexValues {
+		set := idx[value]
+		if set == nil {
+			set = map[string]struct{}{}
+			idx[value] = set
+		}
+		set[key] = struct{}{}
+	}
+}
@@ -0,0 +1,387 @@
+// Package debug provides an http.Handler to inspect and control work queues
+// of a running process.
+//
+// The handler serves, relative to wherever it is mounted:
+//
+//	GET  /                      status of every registered queue
+//	GET  /<name>                status of a single queue
+//	POST /<name>/pause          pause the queue
+//	POST /<name>/resume         resume the queue
+//	POST /<name>/forget?item=x  stop tracking the failures of item x
+//	POST /<name>/requeue?item=x add item x to the queue again
+//
+// GET requests accept a top parameter limiting how many retrying items are
+// listed, 10 by default.
+package debug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ForbiddenR/jxclient-go/util/workqueue"
+)
+
+const defaultTopRetrying = 10
+
+// ParseFunc turns the item query parameter of a POST action back into a
+// queue item.
+type ParseFunc[T comparable] func(s string) (T, error)
+
+// QueueStatus is the state of a registered queue as served by the Handler.
+type QueueStatus struct {
+	Name         string           `json:"name"`
+	Len          int              `json:"len"`
+	Paused       bool             `json:"paused"`
+	ShuttingDown bool             `json:"shuttingDown"`
+	InFlight     []InFlightStatus `json:"inFlight"`
+	Pending      []PendingStatus  `json:"pending"`
+	// Retrying lists the items with the most failures tracked by the rate
+	// limiter. Items between attempts are only listed if the rate limiter
+	// is a workqueue.StatefulRateLimiter.
+	Retrying []RetryStatus `json:"retrying"`
+}
+
+// InFlightStatus is an item being processed by a worker.
+type InFlightStatus struct {
+	Item  string    `json:"item"`
+	Since time.Time `json:"since"`
+	Age   string    `json:"age"`
+}
+
+// PendingStatus is an item waiting in a delaying queue.
+type PendingStatus struct {
+	Item    string    `json:"item"`
+	ReadyAt time.Time `json:"readyAt"`
+}
+
+// RetryStatus is an item tracked by the rate limiter of a queue.
+type RetryStatus struct {
+	Item        string `json:"item"`
+	NumRequeues int    `json:"numRequeues"`
+}
+
+var (
+	errNotFound    = errors.New("queue not found")
+	errUnsupported = errors.New("action not supported by this queue")
+)
+
+// queue is the type-erased view of a registered work queue.
+type queue interface {
+	status(now time.Time, top int) QueueStatus
+	pause() error
+	resume() error
+	forget(item string) error
+	requeue(item string) error
+}
+
+// Handler serves the state of registered work queues over HTTP.
+type Handler struct {
+	lock   sync.RWMutex
+	queues map[string]queue
+	now    func() time.Time
+}
+
+var _ http.Handler = &Handler{}
+
+// NewHandler constructs a Handler without any queues.
+func NewHandler() *Handler {
+	return &Handler{
+		queues: map[string]queue{},
+		now:    time.Now,
+	}
+}
+
+// Register adds q to h under name. The forget action is only supported if q
+// is a workqueue.RateLimitingInterface, the pause and resume actions if q is
+// a workqueue.PausableInterface, and in-flight and pending items are
+// only listed if q is workqueue.Inspectable. parse turns the item parameter of
+// the forget and requeue actions into an item; if it is nil, those actions
+// are only supported for queues whose items are strings.
+func Register[T comparable](h *Handler, name string, q workqueue.Interface[T], parse ParseFunc[T]) error {
+	if name == "" {
+		return errors.New("queue name must not be empty")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("queue name %q must not contain a slash", name)
+	}
+	if parse == nil {
+		parse = parseString[T]
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, exists := h.queues[name]; exists {
+		return fmt.Errorf("queue %q is already registered", name)
+	}
+	h.queues[name] = &registeredQueue[T]{name: name, queue: q, parse: parse}
+	return nil
+}
+
+// Unregister removes the queue registered under name, if any.
+func (h *Handler) Unregister(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.queues, name)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.serveList(w, r)
+		return
+	}
+
+	name, action, _ := strings.Cut(path, "/")
+	h.lock.RLock()
+	q, ok := h.queues[name]
+	h.lock.RUnlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("%v: %q", errNotFound, name), http.StatusNotFound)
+		return
+	}
+
+	if action == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		top, err := topParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, q.status(h.now(), top))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var err error
+	switch action {
+	case "pause":
+		err = q.pause()
+	case "resume":
+		err = q.resume()
+	case "forget":
+		err = q.forget(r.URL.Query().Get("item"))
+	case "requeue":
+		err = q.requeue(r.URL.Query().Get("item"))
+	default:
+		http.Error(w, fmt.Sprintf("unknown action %q", action), http.StatusNotFound)
+		return
+	}
+	switch {
+	case errors.Is(err, errUnsupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, q.status(h.now(), defaultTopRetrying))
+}
+
+func (h *Handler) serveList(w http.ResponseWriter, r *http.Request) {
+	top, err := topParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.lock.RLock()
+	queues := make([]queue, 0, len(h.queues))
+	for _, q := range h.queues {
+		queues = append(queues, q)
+	}
+	h.lock.RUnlock()
+
+	now := h.now()
+	statuses := make([]QueueStatus, 0, len(queues))
+	for _, q := range queues {
+		statuses = append(statuses, q.status(now, top))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	writeJSON(w, statuses)
+}
+
+func topParam(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("top")
+	if raw == "" {
+		return defaultTopRetrying, nil
+	}
+	top, err := strconv.Atoi(raw)
+	if err != nil || top < 0 {
+		return 0, fmt.Errorf("invalid top parameter %q", raw)
+	}
+	return top, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func parseString[T comparable](s string) (T, error) {
+	item, ok := any(s).(T)
+	if !ok {
+		return item, fmt.Errorf("%w: no ParseFunc registered", errUnsupported)
+	}
+	return item, nil
+}
+
+type registeredQueue[T comparable] struct {
+	name  string
+	queue workqueue.Interface[T]
+	parse ParseFunc[T]
+}
+
+func (r *registeredQueue[T]) status(now time.Time, top int) QueueStatus {
+	status := QueueStatus{
+		Name:         r.name,
+		Len:          r.queue.Len(),
+		ShuttingDown: r.queue.ShuttingDown(),
+		InFlight:     []InFlightStatus{},
+		Pending:      []PendingStatus{},
+		Retrying:     []RetryStatus{},
+	}
+	if pq, ok := r.queue.(workqueue.PausableInterface[T]); ok {
+		status.Paused = pq.Paused()
+	}
+
+	iq, ok := r.queue.(workqueue.Inspectable[T])
+	if !ok {
+		return status
+	}
+	snapshot := iq.Snapshot()
+
+	sort.Slice(snapshot.InFlight, func(i, j int) bool { return snapshot.InFlight[i].Since.Before(snapshot.InFlight[j].Since) })
+	for _, item := range snapshot.InFlight {
+		status.InFlight = append(status.InFlight, InFlightStatus{
+			Item:  fmt.Sprint(item.Item),
+			Since: item.Since,
+			Age:   now.Sub(item.Since).String(),
+		})
+	}
+	sort.Slice(snapshot.Pending, func(i, j int) bool { return snapshot.Pending[i].ReadyAt.Before(snapshot.Pending[j].ReadyAt) })
+	for _, item := range snapshot.Pending {
+		status.Pending = append(status.Pending, PendingStatus{Item: fmt.Sprint(item.Item), ReadyAt: item.ReadyAt})
+	}
+
+	if snapshot.Retrying != nil {
+		for item, n := range snapshot.Retrying {
+			if n > 0 {
+				status.Retrying = append(status.Retrying, RetryStatus{Item: fmt.Sprint(item), NumRequeues: n})
+			}
+		}
+		status.Retrying = topRetrying(status.Retrying, top)
+		return status
+	}
+
+	rq, ok := r.queue.(workqueue.RateLimitingInterface[T])
+	if !ok {
+		return status
+	}
+	// The rate limiter cannot enumerate the items it tracks, so only the
+	// items the queue currently holds are candidates; items between
+	// attempts are missed.
+	seen := map[T]bool{}
+	check := func(item T) {
+		if seen[item] {
+			return
+		}
+		seen[item] = true
+		if n := rq.NumRequeues(item); n > 0 {
+			status.Retrying = append(status.Retrying, RetryStatus{Item: fmt.Sprint(item), NumRequeues: n})
+		}
+	}
+	for _, item := range snapshot.Queued {
+		check(item)
+	}
+	for _, item := range snapshot.InFlight {
+		check(item.Item)
+	}
+	for _, item := range snapshot.Pending {
+		check(item.Item)
+	}
+	status.Retrying = topRetrying(status.Retrying, top)
+	return status
+}
+
+// topRetrying returns the top items of retrying with the most requeues.
+func topRetrying(retrying []RetryStatus, top int) []RetryStatus {
+	sort.SliceStable(retrying, func(i, j int) bool {
+		if retrying[i].NumRequeues != retrying[j].NumRequeues {
+			return retrying[i].NumRequeues > retrying[j].NumRequeues
+		}
+		return retrying[i].Item < retrying[j].Item
+	})
+	if len(retrying) > top {
+		retrying = retrying[:top]
+	}
+	return retrying
+}
+
+func (r *registeredQueue[T]) pause() error {
+	pq, ok := r.queue.(workqueue.PausableInterface[T])
+	if !ok {
+		return fmt.Errorf("%w: %q cannot be paused", errUnsupported, r.name)
+	}
+	pq.Pause()
+	return nil
+}
+
+func (r *registeredQueue[T]) resume() error {
+	pq, ok := r.queue.(workqueue.PausableInterface[T])
+	if !ok {
+		return fmt.Errorf("%w: %q cannot be paused", errUnsupported, r.name)
+	}
+	pq.Resume()
+	return nil
+}
+
+func (r *registeredQueue[T]) forget(raw string) error {
+	rq, ok := r.queue.(workqueue.RateLimitingInterface[T])
+	if !ok {
+		return fmt.Errorf("%w: %q is not rate limited", errUnsupported, r.name)
+	}
+	item, err := r.parseItem(raw)
+	if err != nil {
+		return err
+	}
+	rq.Forget(item)
+	return nil
+}
+
+// requeue adds the item to the queue right away. An item being processed is
+// picked up again once it is Done.
+func (r *registeredQueue[T]) requeue(raw string) error {
+	item, err := r.parseItem(raw)
+	if err != nil {
+		return err
+	}
+	r.queue.Add(item)
+	return nil
+}
+
+func (r *registeredQueue[T]) parseItem(raw string) (T, error) {
+	if raw == "" {
+		var item T
+		return item, errors.New("missing item parameter")
+	}
+	return r.parse(raw)
+}
@@ -0,0 +1,161 @@
+package debug_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ForbiddenR/jxclient-go/util/workqueue"
+	"github.com/ForbiddenR/jxclient-go/util/workqueue/debug"
+)
+
+func do(t *testing.T, h http.Handler, method, target string, out any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("Failed to decode %q: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestHandlerStatus(t *testing.T) {
+	q := workqueue.NewRateLimitingQueue[string](workqueue.NewItemExponentialFailureRateLimiter[string](time.Hour, time.Hour))
+	defer q.ShutDown()
+	h := debug.NewHandler()
+	if err := debug.Register[string](h, "verify", q, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Add("foo")
+	q.Add("bar")
+	q.AddRateLimited("baz")
+	q.AddRateLimited("baz")
+	item, _ := q.Get()
+
+	var statuses []debug.QueueStatus
+	if code := do(t, h, http.MethodGet, "/", &statuses); code != http.StatusOK {
+		t.Fatalf("Expected %v, got %v", http.StatusOK, code)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("Expected 1 queue, got %v", len(statuses))
+	}
+	status := statuses[0]
+	if status.Name != "verify" || status.Len != 1 {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if len(status.InFlight) != 1 || status.InFlight[0].Item != item {
+		t.Errorf("Expected %v in flight, got %+v", item, status.InFlight)
+	}
+	if len(status.Pending) != 1 || status.Pending[0].Item != "baz" {
+		t.Errorf("Expected baz pending, got %+v", status.Pending)
+	}
+	if len(status.Retrying) != 1 || status.Retrying[0].Item != "baz" || status.Retrying[0].NumRequeues != 2 {
+		t.Errorf("Expected baz retrying twice, got %+v", status.Retrying)
+	}
+
+	if code := do(t, h, http.MethodGet, "/missing", nil); code != http.StatusNotFound {
+		t.Errorf("Expected %v, got %v", http.StatusNotFound, code)
+	}
+}
+
+func TestHandlerActions(t *testing.T) {
+	q := workqueue.NewRateLimitingQueue[int](workqueue.DefaultContrllerRateLimiter[int]())
+	defer q.ShutDown()
+	h := debug.NewHandler()
+	if err := debug.Register[int](h, "qrcode", q, strconv.Atoi); err != nil {
+		t.Fatal(err)
+	}
+
+	var status debug.QueueStatus
+	if code := do(t, h, http.MethodPost, "/qrcode/pause", &status); code != http.StatusOK {
+		t.Fatalf("Expected %v, got %v", http.StatusOK, code)
+	}
+	if !status.Paused || !q.(workqueue.PausableInterface[int]).Paused() {
+		t.Errorf("Expected queue to be paused")
+	}
+	if code := do(t, h, http.MethodPost, "/qrcode/resume", &status); code != http.StatusOK || status.Paused {
+		t.Errorf("Expected queue to be resumed")
+	}
+
+	if code := do(t, h, http.MethodPost, "/qrcode/requeue?item=7", &status); code != http.StatusOK || status.Len != 1 {
+		t.Errorf("Expected item to be requeued, got %v %+v", code, status)
+	}
+	if code := do(t, h, http.MethodPost, "/qrcode/requeue?item=seven", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected %v, got %v", http.StatusBadRequest, code)
+	}
+
+	q.AddRateLimited(7)
+	if code := do(t, h, http.MethodPost, "/qrcode/forget?item=7", nil); code != http.StatusOK {
+		t.Errorf("Expected %v, got %v", http.StatusOK, code)
+	}
+	if n := q.NumRequeues(7); n != 0 {
+		t.Errorf("Expected failures to be forgotten, got %v", n)
+	}
+
+	if code := do(t, h, http.MethodGet, "/qrcode/pause", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected %v, got %v", http.StatusMethodNotAllowed, code)
+	}
+}
+
+func TestHandlerForgetUnsupported(t *testing.T) {
+	q := workqueue.New[string]()
+	h := debug.NewHandler()
+	if err := debug.Register[string](h, "plain", q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := debug.Register[string](h, "plain", q, nil); err == nil {
+		t.Errorf("Expected registering a name twice to fail")
+	}
+	if code := do(t, h, http.MethodPost, "/plain/forget?item=foo", nil); code != http.StatusNotImplemented {
+		t.Errorf("Expected %v, got %v", http.StatusNotImplemented, code)
+	}
+}
+
+// plainQueue only implements the core workqueue.Interface.
+type plainQueue struct {
+	workqueue.Interface[string]
+}
+
+func TestHandlerPauseUnsupported(t *testing.T) {
+	h := debug.NewHandler()
+	if err := debug.Register[string](h, "plain", plainQueue{workqueue.New[string]()}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range []string{"pause", "resume"} {
+		if code := do(t, h, http.MethodPost, "/plain/"+action, nil); code != http.StatusNotImplemented {
+			t.Errorf("%s: expected %v, got %v", action, http.StatusNotImplemented, code)
+		}
+	}
+}
+
+func TestHandlerStatusRetryingBetweenAttempts(t *testing.T) {
+	rateLimiter := workqueue.NewItemExponentialFailureRateLimiter[string](time.Millisecond, time.Hour)
+	q := workqueue.NewRateLimitingQueue[string](rateLimiter)
+	defer q.ShutDown()
+	h := debug.NewHandler()
+	if err := debug.Register[string](h, "verify", q, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// foo failed three times and bar once, and neither is held by the
+	// queue until their next attempts.
+	for _, item := range []string{"foo", "foo", "bar", "foo"} {
+		rateLimiter.When(item)
+	}
+
+	var status debug.QueueStatus
+	if code := do(t, h, http.MethodGet, "/verify?top=1", &status); code != http.StatusOK {
+		t.Fatalf("Expected %v, got %v", http.StatusOK, code)
+	}
+	expected := []debug.RetryStatus{{Item: "foo", NumRequeues: 3}}
+	if !reflect.DeepEqual(expected, status.Retrying) {
+		t.Errorf("Expected %+v, got %+v", expected, status.Retrying)
+	}
+}
@@ -26,6 +26,17 @@ func DefaultContrllerRateLimiter[T comparable]() RateLimiter[T] {
 	)
 }
 
+// DefaultCostedControllerRateLimiter is DefaultContrllerRateLimiter with the overall
+// bucket charging every item its cost, so expensive bulk items slow down retries
+// more than cheap ones. Per item the exponential backoff applies regardless of cost.
+func DefaultCostedControllerRateLimiter[T comparable](cost func(item T) int) RateLimiter[T] {
+	return NewMaxOfRateLimiter[T](
+		NewItemExponentialFailureRateLimiter[T](5*time.Millisecond, 1000*time.Second),
+		// 10 tokens per second, 100 bucket size, each item taking as many tokens as it costs.
+		NewCostedBucketRateLimiter[T](rate.NewLimiter(rate.Limit(10), 100), cost),
+	)
+}
+
 // BucketRateLimiter adapts a standard bucket to the workqueue ratelimiter API
 type BucketRateLimiter[T comparable] struct {
 	*rate.Limiter
@@ -44,19 +55,61 @@ func (r *BucketRateLimiter[T]) NumRequeues(item T) int {
 func (r *BucketRateLimiter[T]) Forget(item T) {
 }
 
+// CostedBucketRateLimiter adapts a standard bucket to the workqueue ratelimiter API,
+// reserving as many tokens per item as its cost. An item which costs more than the
+// burst of the bucket is charged the burst, so it still gets through eventually.
+type CostedBucketRateLimiter[T comparable] struct {
+	*rate.Limiter
+
+	// Cost returns how many tokens item takes. Costs below one are treated as one.
+	Cost func(item T) int
+}
+
+var _ RateLimiter[any] = &CostedBucketRateLimiter[any]{}
+
+func NewCostedBucketRateLimiter[T comparable](limiter *rate.Limiter, cost func(item T) int) RateLimiter[T] {
+	return &CostedBucketRateLimiter[T]{Limiter: limiter, Cost: cost}
+}
+
+func (r *CostedBucketRateLimiter[T]) When(item T) time.Duration {
+	n := r.Cost(item)
+	if n < 1 {
+		n = 1
+	}
+	if burst := r.Limiter.Burst(); n > burst && burst > 0 {
+		n = burst
+	}
+	return r.Limiter.ReserveN(time.Now(), n).Delay()
+}
+
+func (r *CostedBucketRateLimiter[T]) NumRequeues(item T) int {
+	return 0
+}
+
+func (r *CostedBucketRateLimiter[T]) Forget(item T) {
+}
+
 type ItemExponentialFailureRateLimiter[T comparable] struct {
 	failuresLock sync.Mutex
 	failures     map[T]int
+	expiry       failureExpiry[T]
 
 	baseDelay time.Duration
 	maxDelay  time.Duration
 }
 
-var _ RateLimiter[any] = &ItemExponentialFailureRateLimiter[any]{}
+var _ StatefulRateLimiter[any] = &ItemExponentialFailureRateLimiter[any]{}
 
 func NewItemExponentialFailureRateLimiter[T comparable](baseDelay time.Duration, maxDelay time.Duration) RateLimiter[T] {
+	return NewItemExponentialFailureRateLimiterWithOptions[T](baseDelay, maxDelay, ItemFailureOptions{})
+}
+
+// NewItemExponentialFailureRateLimiterWithOptions constructs an ItemExponentialFailureRateLimiter
+// which can drop the failures of idle items and report how many items it tracks.
+func NewItemExponentialFailureRateLimiterWithOptions[T comparable](baseDelay time.Duration, maxDelay time.Duration, options ItemFailureOptions) RateLimiter[T] {
 	return &ItemExponentialFailureRateLimiter[T]{
 		failures:  map[T]int{},
+		expiry:    newFailureExpiry[T](options),
 		baseDelay: baseDelay,
 		maxDelay:  maxDelay,
 	}
@@ -66,6 +119,9 @@ func (r *ItemExponentialFailureRateLimiter[T]) When(item T) time.Duration {
 	r.failuresLock.Lock()
 	defer r.failuresLock.Unlock()
 
+	r.expiry.expire(r.failures, item)
+	defer r.expiry.touch(r.failures, item)
+
 	exp := r.failures[item]
 	r.failures[item] = r.failures[item] + 1
 
@@ -87,6 +143,8 @@ func (r *ItemExponentialFailureRateLimiter[T]) NumRequeues(item T) int {
 	r.failuresLock.Lock()
 	defer r.failuresLock.Unlock()
 
+	r.expiry.expire(r.failures, item)
+
 	return r.failures[item]
 }
 
@@ -95,23 +153,49 @@ func (r *ItemExponentialFailureRateLimiter[T]) Forget(item T) {
 	defer r.failuresLock.Unlock()
 
 	delete(r.failures, item)
+	r.expiry.forget(r.failures, item)
+}
+
+func (r *ItemExponentialFailureRateLimiter[T]) Snapshot() RateLimiterState[T] {
+	r.failuresLock.Lock()
+	defer r.failuresLock.Unlock()
+
+	r.expiry.sweep(r.failures)
+
+	return RateLimiterState[T]{Failures: copyFailures(r.failures)}
+}
+
+func (r *ItemExponentialFailureRateLimiter[T]) Restore(state RateLimiterState[T]) {
+	r.failuresLock.Lock()
+	defer r.failuresLock.Unlock()
+
+	r.failures = copyFailures(state.Failures)
+	r.expiry.restore(r.failures)
 }
 
 // ItemFastSlowRateLimiter does a quick retry for a certain number of attempts, then a slow retry after that
 type ItemFastSlowRateLimiter[T comparable] struct {
 	failuresLock sync.Mutex
 	failures     map[T]int
+	expiry       failureExpiry[T]
 
 	maxFastAttempts int
 	fastDelay       time.Duration
 	slowDelay       time.Duration
 }
 
-var _ RateLimiter[any] = &ItemFastSlowRateLimiter[any]{}
+var _ StatefulRateLimiter[any] = &ItemFastSlowRateLimiter[any]{}
 
 func NewItemFastSlowRateLimiter[T comparable](fastDelay, slowDelay time.Duration, maxFastAttempts int) RateLimiter[T] {
+	return NewItemFastSlowRateLimiterWithOptions[T](fastDelay, slowDelay, maxFastAttempts, ItemFailureOptions{})
+}
+
+// NewItemFastSlowRateLimiterWithOptions constructs an ItemFastSlowRateLimiter which can drop
+// the failures of idle items and report how many items it tracks.
+func NewItemFastSlowRateLimiterWithOptions[T comparable](fastDelay, slowDelay time.Duration, maxFastAttempts int, options ItemFailureOptions) RateLimiter[T] {
 	return &ItemFastSlowRateLimiter[T]{
 		failures:        map[T]int{},
+		expiry:          newFailureExpiry[T](options),
 		fastDelay:       fastDelay,
 		slowDelay:       slowDelay,
 		maxFastAttempts: maxFastAttempts,
@@ -122,6 +206,9 @@ func (r *ItemFastSlowRateLimiter[T]) When(item T) time.Duration {
 	r.failuresLock.Lock()
 	defer r.failuresLock.Unlock()
 
+	r.expiry.expire(r.failures, item)
+	defer r.expiry.touch(r.failures, item)
+
 	r.failures[item] = r.failures[item] + 1
 
 	if r.failures[item] <= r.maxFastAttempts {
@@ -135,6 +222,8 @@ func (r *ItemFastSlowRateLimiter[T]) NumRequeues(item T) int {
 	r.failuresLock.Lock()
 	defer r.failuresLock.Unlock()
 
+	r.expiry.expire(r.failures, item)
+
 	return r.failures[item]
 }
 
@@ -143,6 +232,32 @@ func (r *ItemFastSlowRateLimiter[T]) Forget(item T) {
 	defer r.failuresLock.Unlock()
 
 	delete(r.failures, item)
+	r.expiry.forget(r.failures, item)
+}
+
+func (r *ItemFastSlowRateLimiter[T]) Snapshot() RateLimiterState[T] {
+	r.failuresLock.Lock()
+	defer r.failuresLock.Unlock()
+
+	r.expiry.sweep(r.failures)
+
+	return RateLimiterState[T]{Failures: copyFailures(r.failures)}
+}
+
+func (r *ItemFastSlowRateLimiter[T]) Restore(state RateLimiterState[T]) {
+	r.failuresLock.Lock()
+	defer r.failuresLock.Unlock()
+
+	r.failures = copyFailures(state.Failures)
+	r.expiry.restore(r.failures)
+}
+
+func copyFailures[T comparable](failures map[T]int) map[T]int {
+	ret := make(map[T]int, len(failures))
+	for item, count := range failures {
+		ret[item] = count
+	}
+	return ret
 }
 
 // NewMaxOfRateLimiter calls every RateLimiter and returns the worst case respose
@@ -152,6 +267,8 @@ type MaxOfRateLimiter[T comparable] struct {
 	limiters []RateLimiter[T]
 }
 
+var _ StatefulRateLimiter[any] = &MaxOfRateLimiter[any]{}
+
 func (r *MaxOfRateLimiter[T]) When(item T) time.Duration {
 	ret := time.Duration(0)
 	for _, limiter := range r.limiters {
@@ -186,12 +303,41 @@ func (r *MaxOfRateLimiter[T]) Forget(item T) {
 	}
 }
 
+// Snapshot merges the state of the limiters which track failures, keeping the
+// highest count per item as NumRequeues does.
+func (r *MaxOfRateLimiter[T]) Snapshot() RateLimiterState[T] {
+	ret := RateLimiterState[T]{Failures: map[T]int{}}
+	for _, limiter := range r.limiters {
+		stateful, ok := limiter.(StatefulRateLimiter[T])
+		if !ok {
+			continue
+		}
+		for item, count := range stateful.Snapshot().Failures {
+			if count > ret.Failures[item] {
+				ret.Failures[item] = count
+			}
+		}
+	}
+	return ret
+}
+
+// Restore restores state into every limiter which tracks failures.
+func (r *MaxOfRateLimiter[T]) Restore(state RateLimiterState[T]) {
+	for _, limiter := range r.limiters {
+		if stateful, ok := limiter.(StatefulRateLimiter[T]); ok {
+			stateful.Restore(state)
+		}
+	}
+}
+
 // WithMaxWaitRateLimiter have maxDelay which avoids waiting too long
 type WithMaxWaitRateLimiter[T comparable] struct {
 	limiter  RateLimiter[T]
 	maxDelay time.Duration
 }
 
+var _ StatefulRateLimiter[any] = &WithMaxWaitRateLimiter[any]{}
+
 func NewWithMaxWaitRateLimiter[T comparable](limiter RateLimiter[T], maxDelay time.Duration) RateLimiter[T] {
 	return &WithMaxWaitRateLimiter[T]{limiter: limiter, maxDelay: maxDelay}
 }
@@ -211,3 +357,16 @@ func (w WithMaxWaitRateLimiter[T]) Forget(item T) {
 func (w WithMaxWaitRateLimiter[T]) NumRequeues(item T) int {
 	return w.limiter.NumRequeues(item)
 }
+
+func (w WithMaxWaitRateLimiter[T]) Snapshot() RateLimiterState[T] {
+	if stateful, ok := w.limiter.(StatefulRateLimiter[T]); ok {
+		return stateful.Snapshot()
+	}
+	return RateLimiterState[T]{Failures: map[T]int{}}
+}
+
+func (w WithMaxWaitRateLimiter[T]) Restore(state RateLimiterState[T]) {
+	if stateful, ok := w.limiter.(StatefulRateLimiter[T]); ok {
+		stateful.Restore(state)
+	}
+}
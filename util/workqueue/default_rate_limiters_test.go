@@ -1,6 +1,13 @@
 package workqueue_test
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"github.com/ForbiddenR/jxclient-go/internal/clocktesting"
+	"github.com/ForbiddenR/jxclient-go/util/workqueue"
+	"golang.org/x/time/rate"
+)
 
 
 type Type[T comparable] struct {
@@ -17,4 +24,177 @@ func TestGeneric(t *testing.T) {
 	}
 	d1 := &Data{}
 	my.keep[d1] = struct{}{} 
-}
\ No newline at end of file
+}
+type qrCodeBatch struct {
+	terminals int
+}
+
+func TestCostedBucketRateLimiter(t *testing.T) {
+	limiter := workqueue.NewCostedBucketRateLimiter[qrCodeBatch](
+		rate.NewLimiter(rate.Limit(1), 10),
+		func(b qrCodeBatch) int { return b.terminals },
+	)
+
+	if e, a := time.Duration(0), limiter.When(qrCodeBatch{terminals: 5}); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	if e, a := time.Duration(0), limiter.When(qrCodeBatch{terminals: 5}); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	// The bucket is empty, so the next batch waits for as many tokens as it costs.
+	if a := limiter.When(qrCodeBatch{terminals: 3}); a < 2*time.Second || a > 3*time.Second {
+		t.Errorf("Expected about %v, got %v", 3*time.Second, a)
+	}
+	// A batch costing more than the burst is charged the burst.
+	if a := limiter.When(qrCodeBatch{terminals: 500}); a < 12*time.Second || a > 13*time.Second {
+		t.Errorf("Expected about %v, got %v", 13*time.Second, a)
+	}
+}
+
+func TestCostedMaxOfRateLimiter(t *testing.T) {
+	limiter := workqueue.NewMaxOfRateLimiter[qrCodeBatch](
+		workqueue.NewItemExponentialFailureRateLimiter[qrCodeBatch](time.Millisecond, time.Second),
+		workqueue.NewCostedBucketRateLimiter[qrCodeBatch](
+			rate.NewLimiter(rate.Limit(1), 10),
+			func(b qrCodeBatch) int { return b.terminals },
+		),
+	)
+
+	single, bulk := qrCodeBatch{terminals: 1}, qrCodeBatch{terminals: 9}
+	if e, a := time.Millisecond, limiter.When(single); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	// The bulk batch drains the bucket but is not delayed by it yet.
+	if e, a := time.Millisecond, limiter.When(bulk); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	// Now the cost dominates the per item backoff.
+	if a := limiter.When(single); a < 900*time.Millisecond {
+		t.Errorf("Expected the bucket to delay the item, got %v", a)
+	}
+	if e, a := 2, limiter.NumRequeues(single); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+}
+
+func TestRateLimiterSnapshotRestore(t *testing.T) {
+	newLimiter := func() workqueue.RateLimiter[string] {
+		return workqueue.NewWithMaxWaitRateLimiter[string](
+			workqueue.NewMaxOfRateLimiter[string](
+				workqueue.NewItemExponentialFailureRateLimiter[string](time.Millisecond, time.Second),
+				workqueue.NewItemFastSlowRateLimiter[string](time.Millisecond, time.Minute, 5),
+				&workqueue.BucketRateLimiter[string]{Limiter: rate.NewLimiter(rate.Limit(1000), 100)},
+			),
+			time.Hour,
+		)
+	}
+
+	before := newLimiter()
+	for i := 0; i < 3; i++ {
+		before.When("flapping")
+	}
+	before.When("once")
+
+	codec := workqueue.JSONRateLimiterStateCodec[string]{}
+	data, err := codec.Encode(before.(workqueue.StatefulRateLimiter[string]).Snapshot())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	state, err := codec.Decode(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	after := newLimiter()
+	after.(workqueue.StatefulRateLimiter[string]).Restore(state)
+	if e, a := 3, after.NumRequeues("flapping"); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	if e, a := 1, after.NumRequeues("once"); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	if e, a := 8*time.Millisecond, after.When("flapping"); e != a {
+		t.Errorf("Expected the backoff to continue, expected %v, got %v", e, a)
+	}
+}
+
+func TestJSONRateLimiterStateCodecErrors(t *testing.T) {
+	codec := workqueue.JSONRateLimiterStateCodec[string]{}
+	for _, data := range []string{
+		`not json`,
+		`{"version": 2, "failures": []}`,
+		`{"version": 1, "failures": [{"item": "foo", "failures": -1}]}`,
+		`{"version": 1, "failures": [{"item": 1, "failures": 1}]}`,
+	} {
+		if _, err := codec.Decode([]byte(data)); err == nil {
+			t.Errorf("Expected %s to be rejected", data)
+		}
+	}
+}
+
+type fakeGauge struct {
+	value float64
+}
+
+func (g *fakeGauge) Set(value float64) {
+	g.value = value
+}
+
+func TestItemFailureIdleExpiry(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	options := func(gauge *fakeGauge) workqueue.ItemFailureOptions {
+		return workqueue.ItemFailureOptions{
+			IdleExpiry:   time.Minute,
+			Clock:        fakeClock,
+			TrackedItems: gauge,
+		}
+	}
+
+	expGauge, fastSlowGauge := &fakeGauge{}, &fakeGauge{}
+	limiters := []struct {
+		limiter workqueue.RateLimiter[string]
+		gauge   *fakeGauge
+	}{
+		{
+			limiter: workqueue.NewItemExponentialFailureRateLimiterWithOptions[string](time.Millisecond, time.Second, options(expGauge)),
+			gauge:   expGauge,
+		},
+		{
+			limiter: workqueue.NewItemFastSlowRateLimiterWithOptions[string](time.Millisecond, time.Second, 3, options(fastSlowGauge)),
+			gauge:   fastSlowGauge,
+		},
+	}
+	for _, l := range limiters {
+		l.limiter.When("idle")
+		l.limiter.When("busy")
+		l.limiter.When("busy")
+		if e, a := 2.0, l.gauge.value; e != a {
+			t.Errorf("Expected %v tracked items, got %v", e, a)
+		}
+
+		fakeClock.Step(50 * time.Second)
+		l.limiter.When("busy")
+		fakeClock.Step(20 * time.Second)
+
+		// idle was last seen 70s ago, busy 20s ago.
+		if e, a := 0, l.limiter.NumRequeues("idle"); e != a {
+			t.Errorf("Expected %v, got %v", e, a)
+		}
+		if e, a := 3, l.limiter.NumRequeues("busy"); e != a {
+			t.Errorf("Expected %v, got %v", e, a)
+		}
+		if e, a := 1.0, l.gauge.value; e != a {
+			t.Errorf("Expected %v tracked items, got %v", e, a)
+		}
+		// An expired item starts again from the base delay.
+		if e, a := time.Millisecond, l.limiter.When("idle"); e != a {
+			t.Errorf("Expected %v, got %v", e, a)
+		}
+
+		l.limiter.Forget("busy")
+		l.limiter.Forget("idle")
+		if e, a := 0.0, l.gauge.value; e != a {
+			t.Errorf("Expected %v tracked items, got %v", e, a)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package workqueue
 
 import (
 	"container/heap"
+	"context"
 	"sync"
 	"time"
 
@@ -16,15 +17,36 @@ type DelayingInterface[T comparable] interface {
 	AddAfter(item T, duration time.Duration)
 }
 
+// DelayingDeadlineInterface is a DelayingInterface whose items can be given a
+// deadline, see DeadlineInterface.
+type DelayingDeadlineInterface[T comparable] interface {
+	DelayingInterface[T]
+	AddWithDeadline(item T, deadline time.Time)
+	// AddAfterWithDeadline adds an item to the workqueue after the indicated duration
+	// has passed, unless its deadline has passed by then
+	AddAfterWithDeadline(item T, duration time.Duration, deadline time.Time)
+}
+
 type DelayingQueueConfig[T comparable] struct {
 	// Name for the queue. If unnamed, the metrics will not be registered.
 	Name string
 
+	// MetricsProvider optionally allows specifying a metrics provider to use for the queue.
+	MetricsProvider MetricsProvider
+
 	// Clock optionally allows injecting a real or fake clock for testing purposes.
 	Clock clock.WithTicker
 
 	// Queue optionally allows injecting custom queue Interface instead of the default one.
 	Queue Interface[T]
+
+	// OnExpired is optionally called for every item dropped because its deadline passed.
+	// It is ignored if Queue is set.
+	OnExpired func(item T)
+
+	// Hooks optionally allows observing items as they move through the queue.
+	// Only OnRequeue is used if Queue is set.
+	Hooks QueueHooks[T]
 }
 
 func NewDelayingQueue[T comparable]() DelayingInterface[T] {
@@ -39,22 +61,29 @@ func NewDelayingQueueWithConfig[T comparable](config DelayingQueueConfig[T]) Del
 	}
 
 	if config.Queue == nil {
-		config.Queue = NewWithConfig[T](QueueConfig{
-			Name:  config.Name,
-			Clock: config.Clock,
+		config.Queue = NewWithOptions(QueueConfig{
+			Name:            config.Name,
+			MetricsProvider: config.MetricsProvider,
+			Clock:           config.Clock,
+		}, QueueOptions[T]{
+			OnExpired: config.OnExpired,
+			Hooks:     config.Hooks,
 		})
 	}
 
-	return newDelayingQueue(config.Clock, config.Queue, config.Name)
+	return newDelayingQueue(config.Clock, config.Queue, config.Name, config.Hooks)
 }
 
-func newDelayingQueue[T comparable](clock clock.WithTicker, q Interface[T], jjname string) *delayingType[T] {
+func newDelayingQueue[T comparable](clock clock.WithTicker, q Interface[T], jjname string, hooks QueueHooks[T]) *delayingType[T] {
 	ret := &delayingType[T]{
 		Interface:       q,
 		clock:           clock,
+		hooks:           hooks,
 		heartbeat:       clock.NewTicker(maxWait),
 		stopCh:          make(chan struct{}),
 		waitingForAddCh: make(chan *waitFor[T], 1000),
+		snapshotCh:      make(chan chan []PendingItem[T]),
+		loopDoneCh:      make(chan struct{}),
 	}
 
 	go ret.waitingLoop()
@@ -78,12 +107,26 @@ type delayingType[T comparable] struct {
 
 	// waitingForAddCh is a buffered channel that feeds waitingForAdd
 	waitingForAddCh chan *waitFor[T]
+
+	// snapshotCh asks the waiting loop for the items it is holding
+	snapshotCh chan chan []PendingItem[T]
+	// loopDoneCh is closed once the waiting loop has returned
+	loopDoneCh chan struct{}
+
+	hooks QueueHooks[T]
 }
 
+var _ Inspectable[any] = &delayingType[any]{}
+var _ DelayingDeadlineInterface[any] = &delayingType[any]{}
+var _ PausableInterface[any] = &delayingType[any]{}
+var _ DrainContextInterface[any] = &delayingType[any]{}
+
 // waitFor holds the data to add and the time it should be added
 type waitFor[T comparable] struct {
 	data    T
 	readyAt time.Time
+	// deadline after which data is no longer worth processing, zero if none
+	deadline time.Time
 	// index in the priority queue (heap)
 	index int
 }
@@ -131,9 +174,76 @@ func (pq waitForPriorityQueue[T]) Peek() any {
 	return pq[0]
 }
 
+// ShutDown stops the waiting loop, dropping items not yet ready, and shuts
+// down the underlying queue.
 func (q *delayingType[T]) ShutDown() {
+	q.stopWaiting()
+	q.Interface.ShutDown()
+}
+
+// ShutDownWithDrain stops the waiting loop, dropping items not yet ready, and
+// waits for the underlying queue to drain.
+func (q *delayingType[T]) ShutDownWithDrain() {
+	q.stopWaiting()
+	q.Interface.ShutDownWithDrain()
+}
+
+// ShutDownWithDrainContext stops the waiting loop, dropping items not yet
+// ready, and waits for the underlying queue to drain until ctx is done. If
+// the underlying queue is not a DrainContextInterface, its drain goes on in
+// the background once ctx is done, and the error of ctx is returned.
+func (q *delayingType[T]) ShutDownWithDrainContext(ctx context.Context) error {
+	q.stopWaiting()
+	if dq, ok := q.Interface.(DrainContextInterface[T]); ok {
+		return dq.ShutDownWithDrainContext(ctx)
+	}
+	return shutDownWithDrainContext(ctx, q.Interface)
+}
+
+// Pause pauses the underlying queue, if it is a PausableInterface. Items
+// becoming ready while the queue is paused are still added to it.
+func (q *delayingType[T]) Pause() {
+	if pq, ok := q.Interface.(PausableInterface[T]); ok {
+		pq.Pause()
+	}
+}
+
+// Resume resumes the underlying queue, if it is a PausableInterface.
+func (q *delayingType[T]) Resume() {
+	if pq, ok := q.Interface.(PausableInterface[T]); ok {
+		pq.Resume()
+	}
+}
+
+// Paused reports whether the underlying queue is a paused
+// PausableInterface.
+func (q *delayingType[T]) Paused() bool {
+	if pq, ok := q.Interface.(PausableInterface[T]); ok {
+		return pq.Paused()
+	}
+	return false
+}
+
+// Snapshot returns the items held by the underlying queue, if it is
+// Inspectable, along with the items waiting to be added.
+func (q *delayingType[T]) Snapshot() Snapshot[T] {
+	var snapshot Snapshot[T]
+	if iq, ok := q.Interface.(Inspectable[T]); ok {
+		snapshot = iq.Snapshot()
+	}
+
+	reply := make(chan []PendingItem[T], 1)
+	select {
+	case q.snapshotCh <- reply:
+		snapshot.Pending = <-reply
+	case <-q.loopDoneCh:
+	}
+	return snapshot
+}
+
+// stopWaiting signals the waiting loop to exit.
+func (q *delayingType[T]) stopWaiting() {
 	q.stopOnce.Do(func() {
-		q.Interface.ShutDown()
 		close(q.stopCh)
 		q.heartbeat.Stop()
 	})
@@ -141,6 +251,37 @@ func (q *delayingType[T]) ShutDown() {
 
 // AddAfter adds the given item to the work queue after the given delay
 func (q *delayingType[T]) AddAfter(item T, duration time.Duration) {
+	q.AddAfterWithDeadline(item, duration, time.Time{})
+}
+
+// AddWithDeadline adds the given item to the work queue, to be dropped if it
+// is not picked up before deadline. If the underlying queue cannot track
+// deadlines, the item is added as long as the deadline has not passed yet.
+func (q *delayingType[T]) AddWithDeadline(item T, deadline time.Time) {
+	if deadline.IsZero() {
+		q.Add(item)
+		return
+	}
+	if dq, ok := q.Interface.(DeadlineInterface[T]); ok {
+		dq.AddWithDeadline(item, deadline)
+		return
+	}
+	addBeforeDeadline[T](q.Interface, q.clock, item, deadline)
+}
+
+// addBeforeDeadline adds item to q, which cannot track deadlines, as long as
+// deadline has not passed yet.
+func addBeforeDeadline[T comparable](q Interface[T], clock clock.PassiveClock, item T, deadline time.Time) {
+	if deadline.IsZero() || clock.Now().Before(deadline) {
+		q.Add(item)
+	}
+}
+
+// AddAfterWithDeadline adds the given item to the work queue after the given
+// delay. An item whose deadline passes while it waits is dropped when it
+// becomes ready instead of being handed to a worker. A zero deadline means the
+// item never expires.
+func (q *delayingType[T]) AddAfterWithDeadline(item T, duration time.Duration, deadline time.Time) {
 	// don't add if we're already shutting down
 	if q.ShuttingDown() {
 		return
@@ -148,14 +289,15 @@ func (q *delayingType[T]) AddAfter(item T, duration time.Duration) {
 
 	// immediately add things with no delay
 	if duration <= 0 {
-		q.Add(item)
+		q.AddWithDeadline(item, deadline)
 		return
 	}
 
 	select {
 	case <-q.stopCh:
 		// unblock if ShutDown() is called
-	case q.waitingForAddCh <- &waitFor[T]{data: item, readyAt: q.clock.Now().Add(duration)}:
+	case q.waitingForAddCh <- &waitFor[T]{data: item, readyAt: q.clock.Now().Add(duration), deadline: deadline}:
+		q.hooks.requeue(item, duration)
 	}
 }
 
@@ -163,6 +305,7 @@ const maxWait = 10 * time.Second
 
 // waitingLoop runs until the workqueue is shutdown and keeps a check on the list of items to be added.
 func (q *delayingType[T]) waitingLoop() {
+	defer close(q.loopDoneCh)
 
 	// Make a placeholder channel to use when there are no items in our list
 	never := make(<-chan time.Time)
@@ -175,6 +318,24 @@ func (q *delayingType[T]) waitingLoop() {
 
 	waitingEntryByData := map[T]*waitFor[T]{}
 
+	addEntry := func(waitEntry *waitFor[T]) {
+		if waitEntry.readyAt.After(q.clock.Now()) {
+			insert(waitingForQueue, waitingEntryByData, waitEntry)
+		} else {
+			q.AddWithDeadline(waitEntry.data, waitEntry.deadline)
+		}
+	}
+	drainAdds := func() {
+		for {
+			select {
+			case waitEntry := <-q.waitingForAddCh:
+				addEntry(waitEntry)
+			default:
+				return
+			}
+		}
+	}
+
 	for {
 		if q.Interface.ShuttingDown() {
 			return
@@ -190,7 +351,7 @@ func (q *delayingType[T]) waitingLoop() {
 			}
 
 			entry = heap.Pop(waitingForQueue).(*waitFor[T])
-			q.Add(entry.data)
+			q.AddWithDeadline(entry.data, entry.deadline)
 			delete(waitingEntryByData, entry.data)
 		}
 
@@ -215,26 +376,18 @@ func (q *delayingType[T]) waitingLoop() {
 		case <-nextReadyAt:
 			// continue the loop, which will add ready items
 
-		case waitEntry := <-q.waitingForAddCh:
-			if waitEntry.readyAt.After(q.clock.Now()) {
-				insert(waitingForQueue, waitingEntryByData, waitEntry)
-			} else {
-				q.Add(waitEntry.data)
+		case reply := <-q.snapshotCh:
+			// entries handed over before the snapshot was asked for belong in it
+			drainAdds()
+			pending := make([]PendingItem[T], 0, waitingForQueue.Len())
+			for _, entry := range *waitingForQueue {
+				pending = append(pending, PendingItem[T]{Item: entry.data, ReadyAt: entry.readyAt})
 			}
+			reply <- pending
 
-			drained := false
-			for !drained {
-				select {
-				case waitEntry := <-q.waitingForAddCh:
-					if waitEntry.readyAt.After(q.clock.Now()) {
-						insert(waitingForQueue, waitingEntryByData, waitEntry)
-					} else {
-						q.Add(waitEntry.data)
-					}
-				default:
-					drained = true
-				}
-			}
+		case waitEntry := <-q.waitingForAddCh:
+			addEntry(waitEntry)
+			drainAdds()
 		}
 	}
 }
@@ -244,6 +397,10 @@ func insert[T comparable](q *waitForPriorityQueue[T], knownEntries map[T]*waitFo
 	// if the entry already exists, update the time only if it would cause the item to be queue sooner
 	existing, exists := knownEntries[entry.data]
 	if exists {
+		// keep the more lenient deadline, where no deadline at all is the most lenient
+		if entry.deadline.IsZero() || (!existing.deadline.IsZero() && existing.deadline.Before(entry.deadline)) {
+			existing.deadline = entry.deadline
+		}
 		if existing.readyAt.After(entry.readyAt) {
 			existing.readyAt = entry.readyAt
 			heap.Fix(q, existing.index)
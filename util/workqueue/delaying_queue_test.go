@@ -0,0 +1,77 @@
+package workqueue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ForbiddenR/jxclient-go/internal/clocktesting"
+	"github.com/ForbiddenR/jxclient-go/util/workqueue"
+)
+
+const testTimeout = 30 * time.Second
+
+// waitForLen polls q until it holds the expected number of items.
+func waitForLen[T comparable](t *testing.T, q workqueue.Interface[T], expected int) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for q.Len() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %v items, got %v", expected, q.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSimpleDelayingQueue(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	q := workqueue.NewDelayingQueueWithConfig(workqueue.DelayingQueueConfig[string]{Clock: fakeClock})
+	defer q.ShutDown()
+
+	q.AddAfter("foo", 50*time.Millisecond)
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty. Has %v items", a)
+	}
+
+	fakeClock.Step(60 * time.Millisecond)
+	waitForLen[string](t, q, 1)
+
+	item, _ := q.Get()
+	q.Done(item)
+
+	// step past the next heartbeat
+	fakeClock.Step(10 * time.Second)
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty. Has %v items", a)
+	}
+}
+
+func TestDelayingQueueDeadline(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	expired := make(chan string, 2)
+	q := workqueue.NewDelayingQueueWithConfig(workqueue.DelayingQueueConfig[string]{
+		Clock:     fakeClock,
+		OnExpired: func(item string) { expired <- item },
+	}).(workqueue.DelayingDeadlineInterface[string])
+	defer q.ShutDown()
+
+	// stale is still sitting in the heap when its deadline passes.
+	q.AddAfterWithDeadline("stale", 50*time.Millisecond, fakeClock.Now().Add(20*time.Millisecond))
+	q.AddAfterWithDeadline("fresh", 50*time.Millisecond, fakeClock.Now().Add(time.Minute))
+	fakeClock.Step(60 * time.Millisecond)
+	waitForLen[string](t, q, 1)
+
+	item, _ := q.Get()
+	if item != "fresh" {
+		t.Errorf("Expected %v, got %v", "fresh", item)
+	}
+	q.Done(item)
+
+	select {
+	case item := <-expired:
+		if item != "stale" {
+			t.Errorf("Expected %v to expire, got %v", "stale", item)
+		}
+	case <-time.After(testTimeout):
+		t.Errorf("Expected %v to expire", "stale")
+	}
+}
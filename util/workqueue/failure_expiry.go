@@ -0,0 +1,125 @@
+package workqueue
+
+import (
+	"time"
+
+	"github.com/ForbiddenR/jxutils/clock"
+)
+
+// ItemFailureOptions customizes the rate limiters which track failures per item.
+type ItemFailureOptions struct {
+	// IdleExpiry optionally drops the failures of an item once When has not been
+	// called for it for this long, as if Forget had been called. This bounds the
+	// memory used when callers miss a Forget. Zero keeps failures until Forget.
+	IdleExpiry time.Duration
+
+	// Clock optionally allows injecting a real or fake clock for testing purposes.
+	Clock clock.PassiveClock
+
+	// TrackedItems optionally reports the number of items with failures tracked.
+	TrackedItems SettableGaugeMetric
+}
+
+// failureExpiry implements ItemFailureOptions for a per-item failures map. Its
+// methods must be called with the lock guarding that map held. The zero value
+// never expires anything and reports nothing.
+type failureExpiry[T comparable] struct {
+	idleExpiry   time.Duration
+	clock        clock.PassiveClock
+	trackedItems SettableGaugeMetric
+
+	// lastTouched records when When was last called for every tracked item.
+	lastTouched map[T]time.Time
+	// lastSweep is when every idle item was last dropped.
+	lastSweep time.Time
+}
+
+func newFailureExpiry[T comparable](options ItemFailureOptions) failureExpiry[T] {
+	if options.Clock == nil {
+		options.Clock = clock.RealClock{}
+	}
+	if options.TrackedItems == nil {
+		options.TrackedItems = noopMetric{}
+	}
+	return failureExpiry[T]{
+		idleExpiry:   options.IdleExpiry,
+		clock:        options.Clock,
+		trackedItems: options.TrackedItems,
+		lastTouched:  map[T]time.Time{},
+		lastSweep:    options.Clock.Now(),
+	}
+}
+
+func (e *failureExpiry[T]) enabled() bool {
+	return e.idleExpiry > 0
+}
+
+// expire drops the failures of item if it has been idle for too long, and every
+// other idle item once per IdleExpiry.
+func (e *failureExpiry[T]) expire(failures map[T]int, item T) {
+	if !e.enabled() {
+		return
+	}
+	now := e.clock.Now()
+	if touched, ok := e.lastTouched[item]; ok && now.Sub(touched) >= e.idleExpiry {
+		delete(failures, item)
+		delete(e.lastTouched, item)
+	}
+	if now.Sub(e.lastSweep) >= e.idleExpiry {
+		e.sweepAt(failures, now)
+	}
+	e.report(failures)
+}
+
+// sweep drops the failures of every idle item.
+func (e *failureExpiry[T]) sweep(failures map[T]int) {
+	if !e.enabled() {
+		return
+	}
+	e.sweepAt(failures, e.clock.Now())
+	e.report(failures)
+}
+
+func (e *failureExpiry[T]) sweepAt(failures map[T]int, now time.Time) {
+	for item, touched := range e.lastTouched {
+		if now.Sub(touched) >= e.idleExpiry {
+			delete(failures, item)
+			delete(e.lastTouched, item)
+		}
+	}
+	e.lastSweep = now
+}
+
+// touch records that When was called for item.
+func (e *failureExpiry[T]) touch(failures map[T]int, item T) {
+	if e.enabled() {
+		e.lastTouched[item] = e.clock.Now()
+	}
+	e.report(failures)
+}
+
+// forget stops tracking item, whose failures were already dropped.
+func (e *failureExpiry[T]) forget(failures map[T]int, item T) {
+	if e.enabled() {
+		delete(e.lastTouched, item)
+	}
+	e.report(failures)
+}
+
+// restore starts tracking every item in failures afresh.
+func (e *failureExpiry[T]) restore(failures map[T]int) {
+	if e.enabled() {
+		now := e.clock.Now()
+		e.lastTouched = make(map[T]time.Time, len(failures))
+		for item := range failures {
+			e.lastTouched[item] = now
+		}
+	}
+	e.report(failures)
+}
+
+func (e *failureExpiry[T]) report(failures map[T]int) {
+	if e.trackedItems != nil {
+		e.trackedItems.Set(float64(len(failures)))
+	}
+}
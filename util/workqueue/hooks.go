@@ -0,0 +1,61 @@
+package workqueue
+
+import "time"
+
+// QueueHooks are optional callbacks observing items as they move through a
+// queue, e.g. to attach trace spans or write audit logs. Every hook is called
+// synchronously from the goroutine calling the queue method, after the queue
+// lock has been released, so hooks may call back into the queue. Slow hooks
+// slow down the caller.
+type QueueHooks[T comparable] struct {
+	// OnAdd is called whenever an item is accepted by Add or
+	// AddWithDeadline, including when it was already waiting.
+	OnAdd func(item T)
+	// OnGet is called whenever Get hands an item to a worker.
+	OnGet func(item T)
+	// OnDone is called whenever Done is called for an item.
+	OnDone func(item T)
+	// OnRequeue is called whenever an item is added with a delay, e.g. by
+	// AddAfter or AddRateLimited.
+	OnRequeue func(item T, delay time.Duration)
+	// OnForget is called whenever a rate limiting queue forgets an item.
+	OnForget func(item T)
+	// OnShutdown is called once, when the queue starts shutting down.
+	OnShutdown func()
+}
+
+func (h *QueueHooks[T]) add(item T) {
+	if h.OnAdd != nil {
+		h.OnAdd(item)
+	}
+}
+
+func (h *QueueHooks[T]) get(item T) {
+	if h.OnGet != nil {
+		h.OnGet(item)
+	}
+}
+
+func (h *QueueHooks[T]) done(item T) {
+	if h.OnDone != nil {
+		h.OnDone(item)
+	}
+}
+
+func (h *QueueHooks[T]) requeue(item T, delay time.Duration) {
+	if h.OnRequeue != nil {
+		h.OnRequeue(item, delay)
+	}
+}
+
+func (h *QueueHooks[T]) forget(item T) {
+	if h.OnForget != nil {
+		h.OnForget(item)
+	}
+}
+
+func (h *QueueHooks[T]) shutdown() {
+	if h.OnShutdown != nil {
+		h.OnShutdown()
+	}
+}
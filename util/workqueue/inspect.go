@@ -0,0 +1,39 @@
+package workqueue
+
+import "time"
+
+// Inspectable is implemented by queues which can report the items they hold.
+// It is meant for debugging and observability only; the result is stale as
+// soon as it is returned.
+type Inspectable[T comparable] interface {
+	Snapshot() Snapshot[T]
+}
+
+// Snapshot is a point in time view of the items held by a queue.
+type Snapshot[T comparable] struct {
+	// Queued holds the items waiting to be handed out, in order.
+	Queued []T
+	// InFlight holds the items handed to a worker which is not Done yet.
+	InFlight []InFlightItem[T]
+	// Pending holds the items a delaying queue will add once they are ready.
+	Pending []PendingItem[T]
+	// Retrying holds how many failures the rate limiter of a rate limiting
+	// queue tracks for each item, including the items which are between
+	// attempts and so not held by the queue. It is nil unless the rate
+	// limiter is a StatefulRateLimiter.
+	Retrying map[T]int
+}
+
+// InFlightItem is an item which is being processed.
+type InFlightItem[T comparable] struct {
+	Item T
+	// Since is when the item was handed to a worker.
+	Since time.Time
+}
+
+// PendingItem is an item waiting to be added to a delaying queue.
+type PendingItem[T comparable] struct {
+	Item T
+	// ReadyAt is when the item will be added.
+	ReadyAt time.Time
+}
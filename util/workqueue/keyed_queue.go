@@ -0,0 +1,204 @@
+package workqueue
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyFunc returns the key identifying item in a KeyedQueue. Items with the
+// same key are considered the same piece of work.
+type KeyFunc[T any] func(item T) string
+
+// MergeFunc combines the value already waiting under a key with a newly added
+// one, returning the value the worker will get.
+type MergeFunc[T any] func(existing, added T) T
+
+// KeyedQueueConfig specifies the configuration of a KeyedQueue.
+type KeyedQueueConfig[T any] struct {
+	// KeyFunc is required and identifies items for de-duplication.
+	KeyFunc KeyFunc[T]
+
+	// Merge optionally combines an added item with the one already waiting
+	// under the same key. By default the latest added item wins.
+	Merge MergeFunc[T]
+}
+
+// NewKeyedQueue constructs a new work queue for items which are not
+// comparable, such as structs containing slices or maps.
+func NewKeyedQueue[T any](config KeyedQueueConfig[T]) *KeyedQueue[T] {
+	if config.KeyFunc == nil {
+		panic("workqueue: KeyedQueueConfig.KeyFunc must be set")
+	}
+	if config.Merge == nil {
+		config.Merge = func(_, added T) T { return added }
+	}
+
+	return &KeyedQueue[T]{
+		keyFunc:    config.KeyFunc,
+		merge:      config.Merge,
+		dirty:      map[string]T{},
+		processing: set[string]{},
+		cond:       sync.NewCond(&sync.Mutex{}),
+	}
+}
+
+// KeyedQueue is a work queue which de-duplicates items by the key returned by
+// its KeyFunc instead of by the items themselves. Apart from that it follows
+// the Add, Get and Done semantics of Type: a key is never handed to two
+// workers at once, and a key added while being processed is queued again once
+// Done is called for it.
+type KeyedQueue[T any] struct {
+	keyFunc KeyFunc[T]
+	merge   MergeFunc[T]
+
+	// queue defines the order in which we will work on keys. Every
+	// element of queue should be in the dirty map and not in the
+	// processing set.
+	queue []string
+
+	// dirty holds the value to hand out for every key that needs to be
+	// processed.
+	dirty map[string]T
+
+	// Keys that are currently being processed are in the processing set.
+	processing set[string]
+
+	cond *sync.Cond
+
+	shuttingDown bool
+	drain        bool
+}
+
+// Add marks item as needing processing. If an item with the same key is
+// already waiting, the two are merged and keep their place in the queue.
+func (q *KeyedQueue[T]) Add(item T) {
+	key := q.keyFunc(item)
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	if existing, ok := q.dirty[key]; ok {
+		q.dirty[key] = q.merge(existing, item)
+		return
+	}
+
+	q.dirty[key] = item
+	if q.processing.has(key) {
+		return
+	}
+
+	q.queue = append(q.queue, key)
+	q.cond.Signal()
+}
+
+// Len returns the current queue length, for informational purposes only.
+func (q *KeyedQueue[T]) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return len(q.queue)
+}
+
+// Get blocks until it can return an item to be processed. If shutdown = true,
+// the caller should end their goroutine. You must call Done with item when you
+// have finished processing it.
+func (q *KeyedQueue[T]) Get() (item T, shutdown bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for len(q.queue) == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	if len(q.queue) == 0 {
+		// We must be shutting down.
+		return item, true
+	}
+
+	key := q.queue[0]
+	q.queue[0] = ""
+	q.queue = q.queue[1:]
+
+	item = q.dirty[key]
+	delete(q.dirty, key)
+	q.processing.insert(key)
+
+	return item, false
+}
+
+// Done marks item as done processing, and if an item with the same key has
+// been added while it was being processed, that one is queued for
+// re-processing.
+func (q *KeyedQueue[T]) Done(item T) {
+	key := q.keyFunc(item)
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	q.processing.delete(key)
+	if _, ok := q.dirty[key]; ok {
+		q.queue = append(q.queue, key)
+		q.cond.Signal()
+	} else if q.processing.len() == 0 {
+		q.cond.Signal()
+	}
+}
+
+// ShutDown will cause q to ignore all new items added to it and
+// immediately instruct the worker goroutines to exit.
+func (q *KeyedQueue[T]) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.drain = false
+	q.shuttingDown = true
+	q.cond.Broadcast()
+}
+
+// ShutDownWithDrain will cause q to ignore all new items added to it and
+// return once every item being processed was marked as Done.
+func (q *KeyedQueue[T]) ShutDownWithDrain() {
+	_ = q.ShutDownWithDrainContext(context.Background())
+}
+
+// ShutDownWithDrainContext behaves like ShutDownWithDrain, but stops waiting
+// once ctx is done. It then returns a *DrainTimeoutError listing the keys of
+// the items which were still being processed.
+func (q *KeyedQueue[T]) ShutDownWithDrainContext(ctx context.Context) error {
+	q.cond.L.Lock()
+	q.drain = true
+	q.shuttingDown = true
+	q.cond.Broadcast()
+	q.cond.L.Unlock()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.cond.L.Lock()
+			defer q.cond.L.Unlock()
+			q.cond.Broadcast()
+		case <-stopCh:
+		}
+	}()
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for q.processing.len() != 0 && q.drain {
+		if err := ctx.Err(); err != nil {
+			keys := make([]string, 0, q.processing.len())
+			for key := range q.processing {
+				keys = append(keys, key)
+			}
+			return &DrainTimeoutError[string]{Items: keys, Err: err}
+		}
+		q.cond.Wait()
+	}
+	return nil
+}
+
+// ShuttingDown reports whether the queue has been shut down.
+func (q *KeyedQueue[T]) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.shuttingDown
+}
@@ -0,0 +1,109 @@
+package workqueue_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ForbiddenR/jxclient-go/util/workqueue"
+)
+
+type verifyRequest struct {
+	StationID string
+	Cards     []string
+}
+
+func stationKey(r verifyRequest) string {
+	return r.StationID
+}
+
+func TestKeyedQueueKeepsLatest(t *testing.T) {
+	q := workqueue.NewKeyedQueue(workqueue.KeyedQueueConfig[verifyRequest]{KeyFunc: stationKey})
+	q.Add(verifyRequest{StationID: "a", Cards: []string{"1"}})
+	q.Add(verifyRequest{StationID: "b", Cards: []string{"2"}})
+	q.Add(verifyRequest{StationID: "a", Cards: []string{"3"}})
+
+	if e, a := 2, q.Len(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	item, _ := q.Get()
+	if item.StationID != "a" || len(item.Cards) != 1 || item.Cards[0] != "3" {
+		t.Errorf("Expected the latest item for a, got %v", item)
+	}
+	q.Done(item)
+}
+
+func TestKeyedQueueMerge(t *testing.T) {
+	q := workqueue.NewKeyedQueue(workqueue.KeyedQueueConfig[verifyRequest]{
+		KeyFunc: stationKey,
+		Merge: func(existing, added verifyRequest) verifyRequest {
+			existing.Cards = append(existing.Cards, added.Cards...)
+			return existing
+		},
+	})
+	q.Add(verifyRequest{StationID: "a", Cards: []string{"1"}})
+	q.Add(verifyRequest{StationID: "a", Cards: []string{"2"}})
+
+	item, _ := q.Get()
+	if len(item.Cards) != 2 || item.Cards[0] != "1" || item.Cards[1] != "2" {
+		t.Errorf("Expected merged cards, got %v", item.Cards)
+	}
+	q.Done(item)
+}
+
+func TestKeyedQueueReinsert(t *testing.T) {
+	q := workqueue.NewKeyedQueue(workqueue.KeyedQueueConfig[verifyRequest]{KeyFunc: stationKey})
+	q.Add(verifyRequest{StationID: "a", Cards: []string{"1"}})
+
+	// Start processing
+	item, _ := q.Get()
+
+	// Add a newer version back while processing
+	q.Add(verifyRequest{StationID: "a", Cards: []string{"2"}})
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty while processing. Has %v items", a)
+	}
+
+	// Finish it up
+	q.Done(item)
+
+	// The newer version should be back on the queue
+	item, _ = q.Get()
+	if item.Cards[0] != "2" {
+		t.Errorf("Expected %v, got %v", "2", item.Cards[0])
+	}
+	q.Done(item)
+
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty. Has %v items", a)
+	}
+}
+
+func TestKeyedQueueShutDownWithDrain(t *testing.T) {
+	q := workqueue.NewKeyedQueue(workqueue.KeyedQueueConfig[verifyRequest]{KeyFunc: stationKey})
+	q.Add(verifyRequest{StationID: "a"})
+	q.Add(verifyRequest{StationID: "b"})
+
+	first, _ := q.Get()
+	second, _ := q.Get()
+
+	finishedWG := sync.WaitGroup{}
+	finishedWG.Add(1)
+	go func() {
+		defer finishedWG.Done()
+		q.ShutDownWithDrain()
+	}()
+
+	shuttingDown := false
+	for !shuttingDown {
+		_, shuttingDown = q.Get()
+	}
+	q.Add(verifyRequest{StationID: "c"})
+
+	q.Done(first)
+	q.Done(second)
+
+	finishedWG.Wait()
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty. Has %v items", a)
+	}
+}
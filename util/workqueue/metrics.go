@@ -23,3 +23,54 @@ type SettableGaugeMetric interface {
 	Set(float64)
 }
 
+// CounterMetric represents a single numerical value that only ever
+// goes up.
+type CounterMetric interface {
+	Inc()
+}
+
+// HistogramMetric counts individual observations.
+type HistogramMetric interface {
+	Observe(float64)
+}
+
+// MetricsProvider generates various metrics used by the queue.
+type MetricsProvider interface {
+	NewExpiredMetric(name string) CounterMetric
+	NewPausedDurationMetric(name string) HistogramMetric
+}
+
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
+
+type noopMetricsProvider struct{}
+
+func (noopMetricsProvider) NewExpiredMetric(name string) CounterMetric {
+	return noopMetric{}
+}
+
+func (noopMetricsProvider) NewPausedDurationMetric(name string) HistogramMetric {
+	return noopMetric{}
+}
+
+// expiredMetric returns the counter for items dropped past their deadline.
+// Unnamed queues do not register metrics.
+func expiredMetric(provider MetricsProvider, name string) CounterMetric {
+	if name == "" {
+		return noopMetric{}
+	}
+	return provider.NewExpiredMetric(name)
+}
+
+// pausedDurationMetric returns the histogram of how long, in seconds, each
+// pause of the queue lasted. Unnamed queues do not register metrics.
+func pausedDurationMetric(provider MetricsProvider, name string) HistogramMetric {
+	if name == "" {
+		return noopMetric{}
+	}
+	return provider.NewPausedDurationMetric(name)
+}
@@ -1,6 +1,8 @@
 package workqueue
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -17,14 +19,60 @@ type Interface[T comparable] interface {
 	ShuttingDown() bool
 }
 
+// DrainContextInterface is an Interface whose drain can be given up on.
+type DrainContextInterface[T comparable] interface {
+	Interface[T]
+	// ShutDownWithDrainContext behaves like ShutDownWithDrain, but stops
+	// waiting for the items being processed once ctx is done.
+	ShutDownWithDrainContext(ctx context.Context) error
+}
+
+// PausableInterface is an Interface whose workers can be held back without
+// shutting the queue down, for example while a dependency is unavailable.
+type PausableInterface[T comparable] interface {
+	Interface[T]
+	// Pause stops Get from handing out items until Resume is called.
+	Pause()
+	// Resume lets Get hand out items again after Pause.
+	Resume()
+	// Paused reports whether the queue is currently paused.
+	Paused() bool
+}
+
+// DeadlineInterface is an Interface that can Add an item which is dropped
+// instead of being handed to a worker once its deadline has passed.
+type DeadlineInterface[T comparable] interface {
+	Interface[T]
+	// AddWithDeadline marks item as needing processing, unless it is still
+	// waiting to be handed out when deadline passes. A zero deadline means
+	// the item never expires.
+	AddWithDeadline(item T, deadline time.Time)
+}
+
 // QueueConfig specifies optional configurations to customize an Interface.
 type QueueConfig struct {
 	// Name for the queue. If unnamed, the metrics will not be registered.
 	Name string
 
+	// MetricsProvider optionally allows specifying a metrics provider to
+	// use for the queue.
+	MetricsProvider MetricsProvider
+
 	Clock clock.WithTicker
 }
 
+// QueueOptions specifies the optional callbacks of a queue, which depend on
+// the type of its items.
+type QueueOptions[T comparable] struct {
+	// OnExpired is optionally called, without the queue lock held, for
+	// every item dropped because its deadline passed before a worker
+	// could get it.
+	OnExpired func(item T)
+
+	// Hooks optionally allows observing items as they move through the queue.
+	Hooks QueueHooks[T]
+}
+
 // New constructs a new work queue.
 func New[T comparable]() *Type[T] {
 	return NewWithConfig[T](QueueConfig{
@@ -35,7 +83,13 @@ func New[T comparable]() *Type[T] {
 // NewWithConfig constructs a new workqueue with ability to
 // customize different properties.
 func NewWithConfig[T comparable](config QueueConfig) *Type[T] {
-	return newQueueWithConfig[T](config, defaultUnfinishedWorkUpdatePeriod)
+	return NewWithOptions(config, QueueOptions[T]{})
+}
+
+// NewWithOptions is a variant of NewWithConfig which also sets the
+// callbacks of the queue.
+func NewWithOptions[T comparable](config QueueConfig, options QueueOptions[T]) *Type[T] {
+	return newQueueWithConfig(config, options, defaultUnfinishedWorkUpdatePeriod)
 }
 
 // NewNamed creates a new named queue.
@@ -48,23 +102,36 @@ func NewNamed[T comparable](name string) *Type[T] {
 
 // newQueueWithConfig constructs a new named workqueue
 // with the ability to customize different properties for testing purposes.
-func newQueueWithConfig[T comparable](config QueueConfig, updatePeriod time.Duration) *Type[T] {
+func newQueueWithConfig[T comparable](config QueueConfig, options QueueOptions[T], updatePeriod time.Duration) *Type[T] {
 	if config.Clock == nil {
 		config.Clock = clock.RealClock{}
 	}
 
-	return newQueue[T](
+	if config.MetricsProvider == nil {
+		config.MetricsProvider = noopMetricsProvider{}
+	}
+
+	q := newQueue[T](
 		config.Clock,
 		updatePeriod,
 	)
+	q.onExpired = options.OnExpired
+	q.hooks = options.Hooks
+	q.expiredMetric = expiredMetric(config.MetricsProvider, config.Name)
+	q.pausedDurationMetric = pausedDurationMetric(config.MetricsProvider, config.Name)
+	return q
 }
 
 func newQueue[T comparable](c clock.WithTicker, updatePeriod time.Duration) *Type[T] {
 	t := &Type[T]{
-		clock: c,
-		dirty: set[T]{},
-		processing: set[T]{},
-		cond: sync.NewCond(&sync.Mutex{}),
+		clock:                      c,
+		dirty:                      set[T]{},
+		processing:                 set[T]{},
+		processingSince:            map[T]time.Time{},
+		deadlines:                  map[T]time.Time{},
+		cond:                       sync.NewCond(&sync.Mutex{}),
+		expiredMetric:              noopMetric{},
+		pausedDurationMetric:       noopMetric{},
 		unfinishedWorkUpdatePeriod: updatePeriod,
 	}
 
@@ -89,16 +156,57 @@ type Type[T comparable] struct {
 	// it's in the dirty set, and if so, add it to the queue.
 	processing set[T]
 
+	// processingSince records when each item in the processing set was
+	// handed to a worker.
+	processingSince map[T]time.Time
+
+	// deadlines holds the deadline of every dirty item that was added with
+	// one. An item without an entry never expires.
+	deadlines map[T]time.Time
+
 	cond *sync.Cond
 
 	shuttingDown bool
 	drain        bool
 
+	// paused holds back Get while still accepting new items. pausedAt is
+	// when the current pause started.
+	paused   bool
+	pausedAt time.Time
+
 	unfinishedWorkUpdatePeriod time.Duration
 	clock                      clock.WithTicker
+
+	onExpired            func(item T)
+	hooks                QueueHooks[T]
+	expiredMetric        CounterMetric
+	pausedDurationMetric HistogramMetric
+}
+
+var _ DeadlineInterface[any] = &Type[any]{}
+var _ PausableInterface[any] = &Type[any]{}
+var _ DrainContextInterface[any] = &Type[any]{}
+var _ Inspectable[any] = &Type[any]{}
+
+// DrainTimeoutError is returned by ShutDownWithDrainContext when the context
+// is done before every item being processed was marked as Done.
+type DrainTimeoutError[T comparable] struct {
+	// Items were still being processed when the drain was given up.
+	Items []T
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *DrainTimeoutError[T]) Error() string {
+	return fmt.Sprintf("workqueue drain interrupted with %d items still processing %v: %v", len(e.Items), e.Items, e.Err)
+}
+
+func (e *DrainTimeoutError[T]) Unwrap() error {
+	return e.Err
 }
 
 type empty struct{}
+
 // type t comparable
 type set[T comparable] map[T]empty
 
@@ -121,22 +229,85 @@ func (s set[T]) len() int {
 
 // Add marks item as needing processing.
 func (q *Type[T]) Add(item T) {
+	if q.add(item) {
+		q.hooks.add(item)
+	}
+}
+
+// add reports whether item was accepted, which it is unless the queue is
+// shutting down.
+func (q *Type[T]) add(item T) bool {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
 	if q.shuttingDown {
-		return
+		return false
 	}
+	// An item added without a deadline must be processed, whatever
+	// deadline an earlier Add asked for.
+	delete(q.deadlines, item)
 	if q.dirty.has(item) {
-		return
+		return true
 	}
 
 	q.dirty.insert(item)
 	if q.processing.has(item) {
+		return true
+	}
+
+	q.queue = append(q.queue, item)
+	q.cond.Signal()
+	return true
+}
+
+// AddWithDeadline marks item as needing processing until deadline. If the
+// item is still waiting in the queue once the deadline has passed, Get drops
+// it instead of handing it to a worker. An item which is already waiting
+// keeps the later of the two deadlines, and never expires if it was added
+// without one. A zero deadline means the item never expires, as with Add.
+func (q *Type[T]) AddWithDeadline(item T, deadline time.Time) {
+	if deadline.IsZero() {
+		q.Add(item)
 		return
 	}
+	if !q.clock.Now().Before(deadline) {
+		q.cond.L.Lock()
+		shuttingDown, dirty := q.shuttingDown, q.dirty.has(item)
+		q.cond.L.Unlock()
+		if !shuttingDown && !dirty {
+			q.expire(item)
+		}
+		return
+	}
+
+	if q.addWithDeadline(item, deadline) {
+		q.hooks.add(item)
+	}
+}
+
+// addWithDeadline reports whether item was accepted, which it is unless the
+// queue is shutting down.
+func (q *Type[T]) addWithDeadline(item T, deadline time.Time) bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return false
+	}
+	if q.dirty.has(item) {
+		if existing, ok := q.deadlines[item]; ok && existing.Before(deadline) {
+			q.deadlines[item] = deadline
+		}
+		return true
+	}
+
+	q.dirty.insert(item)
+	q.deadlines[item] = deadline
+	if q.processing.has(item) {
+		return true
+	}
 
 	q.queue = append(q.queue, item)
 	q.cond.Signal()
+	return true
 }
 
 // Len returns the current queue length, for informational purposes only. You
@@ -156,16 +327,34 @@ func (q *Type[T]) Len() int {
 // indefinitely. It is, however, safe to call ShutDown after having called
 // ShutDownWithDrain, as to force the queue shut down to terminae immediately
 // without waiting for the drainage.
+//
+// Items whose deadline passed while they were waiting are dropped and
+// reported as expired instead of being returned.
 func (q *Type[T]) Get() (item T, shutDown bool) {
+	for {
+		item, shutDown, expired := q.get()
+		if !expired {
+			if !shutDown {
+				q.hooks.get(item)
+			}
+			return item, shutDown
+		}
+		q.expire(item)
+	}
+}
+
+// get pops the next item off the queue. If that item's deadline has passed
+// it is forgotten and reported as expired rather than marked as processing.
+func (q *Type[T]) get() (item T, shutDown, expired bool) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
 	var t T
-	for len(q.queue) == 0 && !q.shuttingDown {
+	for (len(q.queue) == 0 || q.paused) && !q.shuttingDown {
 		q.cond.Wait()
 	}
-	if len(q.queue) == 0 {
+	if len(q.queue) == 0 || q.paused {
 		// We must be shutting down.
-		return t, true
+		return t, true, false
 	}
 
 	item = q.queue[0]
@@ -173,20 +362,42 @@ func (q *Type[T]) Get() (item T, shutDown bool) {
 	q.queue[0] = t
 	q.queue = q.queue[1:]
 
-	q.processing.insert(item)
 	q.dirty.delete(item)
+	deadline, hasDeadline := q.deadlines[item]
+	delete(q.deadlines, item)
+	if hasDeadline && !q.clock.Now().Before(deadline) {
+		return item, false, true
+	}
+
+	q.processing.insert(item)
+	q.processingSince[item] = q.clock.Now()
+
+	return item, false, false
+}
 
-	return item, false
+// expire records that item was dropped because its deadline passed. It must
+// be called without the queue lock held.
+func (q *Type[T]) expire(item T) {
+	q.expiredMetric.Inc()
+	if q.onExpired != nil {
+		q.onExpired(item)
+	}
 }
 
 // Done marks item as done processing, and if it has been marked as dirty again
 // while it was being processed, it will be re-added to the queue for
 // re-processing.
 func (q *Type[T]) Done(item T) {
+	q.done(item)
+	q.hooks.done(item)
+}
+
+func (q *Type[T]) done(item T) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
 
 	q.processing.delete(item)
+	delete(q.processingSince, item)
 	if q.dirty.has(item) {
 		q.queue = append(q.queue, item)
 		q.cond.Signal()
@@ -207,34 +418,63 @@ func (q *Type[T]) ShutDown() {
 // Done on all existing items in the queue; they will be instructed to exit and
 // ShutDownWithDrain will return.
 func (q *Type[T]) ShutDownWithDrain() {
-	q.setDrain(true)
-	q.shutdown()
-	for q.isProcessing() && q.shouldDrain() {
-		q.waitForProcessing()
-	}
+	_ = q.ShutDownWithDrainContext(context.Background())
 }
 
-// isProcessing indiccates if there are still items on the work queue being
-// processed. It's used to drain the work queue on an eventual shutdown.
-func (q *Type[T]) isProcessing() bool {
-	q.cond.L.Lock()
-	defer q.cond.L.Unlock()
-	return q.processing.len() != 0
+// shutDownWithDrainContext drains q, which cannot be interrupted, in the
+// background, and returns the error of ctx if it is done first.
+func shutDownWithDrainContext[T comparable](ctx context.Context, q Interface[T]) error {
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		q.ShutDownWithDrain()
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// waitForProcessing waits for the worker goroutines to finish processing items
-// and call Done on them.
-func (q *Type[T]) waitForProcessing() {
+// ShutDownWithDrainContext behaves like ShutDownWithDrain, but stops waiting
+// for the worker goroutines once ctx is done. It then returns a
+// *DrainTimeoutError listing the items which were still being processed. The
+// queue stays shut down either way.
+func (q *Type[T]) ShutDownWithDrainContext(ctx context.Context) error {
+	q.setDrain(true)
+	q.shutdown()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Wake up the wait below so it notices the context is done.
+			q.cond.L.Lock()
+			defer q.cond.L.Unlock()
+			q.cond.Broadcast()
+		case <-stopCh:
+		}
+	}()
+
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
 	// Ensure that we do not wait on queue which is already empty, as that
 	// could result in waiting for Done to be called on items in an emtpy queue
 	// which has already been shut down, which will result in waiting
 	// indefinitely.
-	if q.processing.len() == 0 {
-		return
+	for q.processing.len() != 0 && q.drain {
+		if err := ctx.Err(); err != nil {
+			items := make([]T, 0, q.processing.len())
+			for item := range q.processing {
+				items = append(items, item)
+			}
+			return &DrainTimeoutError[T]{Items: items, Err: err}
+		}
+		q.cond.Wait()
 	}
-	q.cond.Wait()
+	return nil
 }
 
 func (q *Type[T]) setDrain(shouldDrain bool) {
@@ -243,19 +483,68 @@ func (q *Type[T]) setDrain(shouldDrain bool) {
 	q.drain = shouldDrain
 }
 
-func (q *Type[T]) shouldDrain() bool {
+func (q *Type[T]) shutdown() {
+	q.cond.L.Lock()
+	first := !q.shuttingDown
+	q.shuttingDown = true
+	q.cond.Broadcast()
+	q.cond.L.Unlock()
+
+	if first {
+		q.hooks.shutdown()
+	}
+}
+
+// Snapshot returns the items waiting in the queue, in order, and the items
+// being processed.
+func (q *Type[T]) Snapshot() Snapshot[T] {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	return q.drain
+
+	snapshot := Snapshot[T]{
+		Queued:   make([]T, len(q.queue)),
+		InFlight: make([]InFlightItem[T], 0, len(q.processingSince)),
+	}
+	copy(snapshot.Queued, q.queue)
+	for item, since := range q.processingSince {
+		snapshot.InFlight = append(snapshot.InFlight, InFlightItem[T]{Item: item, Since: since})
+	}
+	return snapshot
 }
 
-func (q *Type[T]) shutdown() {
+// Pause stops Get from handing out items until Resume is called. Items can
+// still be added, and keep their order, while the queue is paused. Items
+// already being processed are unaffected. Shutting down a paused queue
+// releases the waiting workers without handing out the queued items.
+func (q *Type[T]) Pause() {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	q.shuttingDown = true
+	if q.paused {
+		return
+	}
+	q.paused = true
+	q.pausedAt = q.clock.Now()
+}
+
+// Resume lets Get hand out items again after Pause.
+func (q *Type[T]) Resume() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if !q.paused {
+		return
+	}
+	q.paused = false
+	q.pausedDurationMetric.Observe(q.clock.Since(q.pausedAt).Seconds())
 	q.cond.Broadcast()
 }
 
+// Paused reports whether the queue is currently paused.
+func (q *Type[T]) Paused() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.paused
+}
+
 func (q *Type[T]) ShuttingDown() bool {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
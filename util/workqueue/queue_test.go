@@ -1,10 +1,13 @@
 package workqueue_test
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/ForbiddenR/jxclient-go/internal/clocktesting"
 	"github.com/ForbiddenR/jxclient-go/util/workqueue"
 )
 
@@ -210,3 +213,363 @@ func TestQueueDrainageUsingShutDownWithDrain(t *testing.T) {
 	finishedWG.Wait()
 }
 
+
+func TestAddWithDeadline(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	var expired []string
+	q := workqueue.NewWithOptions(workqueue.QueueConfig{Clock: fakeClock}, workqueue.QueueOptions[string]{
+		OnExpired: func(item string) { expired = append(expired, item) },
+	})
+
+	q.AddWithDeadline("stale", fakeClock.Now().Add(time.Second))
+	q.AddWithDeadline("fresh", fakeClock.Now().Add(time.Minute))
+	q.Add("forever")
+	fakeClock.Step(2 * time.Second)
+
+	if e, a := 3, q.Len(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	for _, e := range []string{"fresh", "forever"} {
+		item, _ := q.Get()
+		if item != e {
+			t.Errorf("Expected %v, got %v", e, item)
+		}
+		q.Done(item)
+	}
+	if e, a := []string{"stale"}, expired; len(a) != 1 || a[0] != e[0] {
+		t.Errorf("Expected %v to expire, got %v", e, a)
+	}
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty. Has %v items", a)
+	}
+}
+
+func TestAddWithDeadlineAlreadyPassed(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	expired := 0
+	q := workqueue.NewWithOptions(workqueue.QueueConfig{Clock: fakeClock}, workqueue.QueueOptions[string]{
+		OnExpired: func(string) { expired++ },
+	})
+
+	q.AddWithDeadline("foo", fakeClock.Now())
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty. Has %v items", a)
+	}
+	if expired != 1 {
+		t.Errorf("Expected 1 expired item, got %v", expired)
+	}
+}
+
+func TestAddWithZeroDeadline(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	expired := 0
+	q := workqueue.NewWithOptions(workqueue.QueueConfig{Clock: fakeClock}, workqueue.QueueOptions[string]{
+		OnExpired: func(string) { expired++ },
+	})
+
+	// A zero deadline never expires, and removes an earlier deadline like
+	// a plain Add.
+	q.AddWithDeadline("foo", time.Time{})
+	q.AddWithDeadline("bar", fakeClock.Now().Add(time.Second))
+	q.AddWithDeadline("bar", time.Time{})
+	fakeClock.Step(time.Hour)
+
+	for _, e := range []string{"foo", "bar"} {
+		item, _ := q.Get()
+		if item != e {
+			t.Errorf("Expected %v, got %v", e, item)
+		}
+		q.Done(item)
+	}
+	if expired != 0 {
+		t.Errorf("Expected no expired items, got %v", expired)
+	}
+}
+
+func TestAddWithDeadlineMerge(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	q := workqueue.NewWithConfig[string](workqueue.QueueConfig{Clock: fakeClock})
+
+	// The later deadline wins.
+	q.AddWithDeadline("foo", fakeClock.Now().Add(time.Second))
+	q.AddWithDeadline("foo", fakeClock.Now().Add(time.Minute))
+	// A plain Add removes the deadline altogether.
+	q.AddWithDeadline("bar", fakeClock.Now().Add(time.Second))
+	q.Add("bar")
+	q.AddWithDeadline("bar", fakeClock.Now().Add(time.Second))
+	fakeClock.Step(2 * time.Second)
+
+	for _, e := range []string{"foo", "bar"} {
+		item, _ := q.Get()
+		if item != e {
+			t.Errorf("Expected %v, got %v", e, item)
+		}
+		q.Done(item)
+	}
+}
+
+func TestAddWithDeadlineWhileProcessing(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	expired := 0
+	q := workqueue.NewWithOptions(workqueue.QueueConfig{Clock: fakeClock}, workqueue.QueueOptions[string]{
+		OnExpired: func(string) { expired++ },
+	})
+
+	q.Add("foo")
+	item, _ := q.Get()
+	q.AddWithDeadline(item, fakeClock.Now().Add(time.Second))
+	fakeClock.Step(2 * time.Second)
+	q.Done(item)
+
+	q.Add("bar")
+	if item, _ := q.Get(); item != "bar" {
+		t.Errorf("Expected %v, got %v", "bar", item)
+	}
+	if expired != 1 {
+		t.Errorf("Expected 1 expired item, got %v", expired)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	q := workqueue.New[string]()
+	q.Add("foo")
+	q.Pause()
+	if !q.Paused() {
+		t.Errorf("Expected queue to be paused")
+	}
+	q.Add("bar")
+	q.Add("baz")
+
+	got := make(chan string, 3)
+	go func() {
+		for {
+			item, quit := q.Get()
+			if quit {
+				close(got)
+				return
+			}
+			got <- item
+			q.Done(item)
+		}
+	}()
+
+	select {
+	case item := <-got:
+		t.Fatalf("Got %v from a paused queue", item)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if e, a := 3, q.Len(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+
+	q.Resume()
+	if q.Paused() {
+		t.Errorf("Expected queue to be resumed")
+	}
+	for _, e := range []string{"foo", "bar", "baz"} {
+		if a := <-got; a != e {
+			t.Errorf("Expected %v, got %v", e, a)
+		}
+	}
+	q.ShutDown()
+	if _, ok := <-got; ok {
+		t.Errorf("Expected the worker to exit")
+	}
+}
+
+func TestShutDownWhilePaused(t *testing.T) {
+	q := workqueue.New[string]()
+	q.Add("foo")
+	q.Pause()
+
+	done := make(chan bool)
+	go func() {
+		_, quit := q.Get()
+		done <- quit
+	}()
+	q.ShutDown()
+	if quit := <-done; !quit {
+		t.Errorf("Expected Get to report shutdown")
+	}
+}
+
+func TestPauseForwardedByWrappers(t *testing.T) {
+	rq := workqueue.NewRateLimitingQueue[string](workqueue.DefaultContrllerRateLimiter[string]())
+	defer rq.ShutDown()
+	q, ok := rq.(workqueue.PausableInterface[string])
+	if !ok {
+		t.Fatalf("Expected the rate limiting queue to be pausable")
+	}
+
+	q.Pause()
+	rq.AddAfter("foo", time.Millisecond)
+	waitForLen[string](t, q, 1)
+	if !q.Paused() {
+		t.Errorf("Expected queue to be paused")
+	}
+	q.Resume()
+	if item, _ := q.Get(); item != "foo" {
+		t.Errorf("Expected %v, got %v", "foo", item)
+	}
+}
+
+func TestShutDownWithDrainContext(t *testing.T) {
+	q := workqueue.New[string]()
+	q.Add("foo")
+	q.Add("bar")
+
+	foo, _ := q.Get()
+	bar, _ := q.Get()
+	q.Done(bar)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := q.ShutDownWithDrainContext(ctx)
+
+	var drainErr *workqueue.DrainTimeoutError[string]
+	if !errors.As(err, &drainErr) {
+		t.Fatalf("Expected a drain timeout error, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, drainErr.Err)
+	}
+	if len(drainErr.Items) != 1 || drainErr.Items[0] != foo {
+		t.Errorf("Expected %v to be still processing, got %v", []string{foo}, drainErr.Items)
+	}
+	if !q.ShuttingDown() {
+		t.Errorf("Expected queue to be shutting down")
+	}
+}
+
+func TestShutDownWithDrainContextDrained(t *testing.T) {
+	q := workqueue.New[string]()
+	q.Add("foo")
+	item, _ := q.Get()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Done(item)
+	}()
+	if err := q.ShutDownWithDrainContext(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestShutDownWithDrainForwardedByWrappers(t *testing.T) {
+	q := workqueue.NewRateLimitingQueue[string](workqueue.DefaultContrllerRateLimiter[string]())
+	q.Add("foo")
+	q.AddAfter("bar", time.Hour)
+	item, _ := q.Get()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var drainErr *workqueue.DrainTimeoutError[string]
+	if err := q.(workqueue.DrainContextInterface[string]).ShutDownWithDrainContext(ctx); !errors.As(err, &drainErr) {
+		t.Fatalf("Expected a drain timeout error, got %v", err)
+	}
+
+	q.Done(item)
+	// The waiting loop is stopped, so this must neither block nor add.
+	q.AddAfter("baz", time.Millisecond)
+	q.ShutDownWithDrain()
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty. Has %v items", a)
+	}
+}
+
+// plainQueue only implements the core Interface.
+type plainQueue struct {
+	workqueue.Interface[string]
+}
+
+func TestShutDownWithDrainContextPlainQueue(t *testing.T) {
+	q := workqueue.NewDelayingQueueWithConfig(workqueue.DelayingQueueConfig[string]{
+		Queue: plainQueue{workqueue.New[string]()},
+	})
+	q.Add("foo")
+	item, _ := q.Get()
+
+	// The drain of a queue which cannot be interrupted is given up on
+	// with the error of the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.(workqueue.DrainContextInterface[string]).ShutDownWithDrainContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	q.Done(item)
+	if err := q.(workqueue.DrainContextInterface[string]).ShutDownWithDrainContext(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// plainDelayingQueue only implements the core DelayingInterface.
+type plainDelayingQueue struct {
+	workqueue.DelayingInterface[string]
+}
+
+func TestAddWithDeadlinePlainDelayingQueue(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	delaying := workqueue.NewDelayingQueueWithConfig(workqueue.DelayingQueueConfig[string]{Clock: fakeClock})
+	q := workqueue.NewRateLimitingQueueWithConfig(workqueue.DefaultContrllerRateLimiter[string](), workqueue.RateLimitingQueueConfig[string]{
+		Clock:         fakeClock,
+		DelayingQueue: plainDelayingQueue{delaying},
+	}).(workqueue.DelayingDeadlineInterface[string])
+	defer q.ShutDown()
+
+	// Without deadline support, items are only added if their deadline
+	// has not passed by the time they are ready.
+	q.AddWithDeadline("stale", fakeClock.Now())
+	q.AddWithDeadline("fresh", fakeClock.Now().Add(time.Minute))
+	q.AddAfterWithDeadline("late", time.Hour, fakeClock.Now().Add(time.Minute))
+	if e, a := 1, q.Len(); e != a {
+		t.Fatalf("Expected %v, got %v", e, a)
+	}
+	if item, _ := q.Get(); item != "fresh" {
+		t.Errorf("Expected %v, got %v", "fresh", item)
+	}
+}
+
+func TestQueueHooks(t *testing.T) {
+	var lock sync.Mutex
+	var events []string
+	record := func(event string) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event)
+	}
+
+	var q workqueue.RateLimitingInterface[string]
+	q = workqueue.NewRateLimitingQueueWithConfig(workqueue.DefaultContrllerRateLimiter[string](), workqueue.RateLimitingQueueConfig[string]{
+		Hooks: workqueue.QueueHooks[string]{
+			// Hooks run without the queue lock held, so calling back in
+			// must not deadlock.
+			OnAdd:      func(item string) { record("add " + item); q.Len() },
+			OnGet:      func(item string) { record("get " + item); q.Len() },
+			OnDone:     func(item string) { record("done " + item); q.Len() },
+			OnRequeue:  func(item string, _ time.Duration) { record("requeue " + item) },
+			OnForget:   func(item string) { record("forget " + item) },
+			OnShutdown: func() { record("shutdown"); q.ShuttingDown() },
+		},
+	})
+
+	q.Add("foo")
+	item, _ := q.Get()
+	q.AddAfter(item, time.Hour)
+	q.Forget(item)
+	q.Done(item)
+	q.ShutDown()
+	q.ShutDown()
+
+	expected := []string{"add foo", "get foo", "requeue foo", "forget foo", "done foo", "shutdown"}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, events)
+			break
+		}
+	}
+}
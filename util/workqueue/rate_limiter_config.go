@@ -0,0 +1,343 @@
+package workqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiterSpec declares a RateLimiter. Exactly one of its fields must be
+// set. Durations are strings in the format accepted by time.ParseDuration. In
+// JSON a spec looks like:
+//
+//	{"withMaxWait": {
+//	  "maxWait": "1m",
+//	  "limiter": {"maxOf": [
+//	    {"exponential": {"base": "5ms", "max": "1000s"}},
+//	    {"bucket": {"qps": 10, "burst": 100}}
+//	  ]}
+//	}}
+type RateLimiterSpec struct {
+	Exponential  *ExponentialSpec  `json:"exponential,omitempty"`
+	FastSlow     *FastSlowSpec     `json:"fastSlow,omitempty"`
+	Bucket       *BucketSpec       `json:"bucket,omitempty"`
+	CostedBucket *BucketSpec       `json:"costedBucket,omitempty"`
+	MaxOf        []RateLimiterSpec `json:"maxOf,omitempty"`
+	WithMaxWait  *WithMaxWaitSpec  `json:"withMaxWait,omitempty"`
+}
+
+// ExponentialSpec declares an ItemExponentialFailureRateLimiter.
+type ExponentialSpec struct {
+	Base string `json:"base"`
+	Max  string `json:"max"`
+}
+
+// FastSlowSpec declares an ItemFastSlowRateLimiter.
+type FastSlowSpec struct {
+	Fast            string `json:"fast"`
+	Slow            string `json:"slow"`
+	MaxFastAttempts int    `json:"maxFastAttempts"`
+}
+
+// BucketSpec declares a BucketRateLimiter, or a CostedBucketRateLimiter.
+type BucketSpec struct {
+	QPS   float64 `json:"qps"`
+	Burst int     `json:"burst"`
+}
+
+// WithMaxWaitSpec declares a WithMaxWaitRateLimiter.
+type WithMaxWaitSpec struct {
+	Limiter *RateLimiterSpec `json:"limiter"`
+	MaxWait string           `json:"maxWait"`
+}
+
+// RateLimiterSpecOptions provides what a RateLimiterSpec cannot declare.
+type RateLimiterSpecOptions[T comparable] struct {
+	// Cost is required by costedBucket limiters.
+	Cost func(item T) int
+}
+
+// SpecError reports an invalid field of a RateLimiterSpec.
+type SpecError struct {
+	// Field is the path of the offending field, e.g. "maxOf[1].bucket.qps".
+	Field string
+	// Detail describes what is wrong with it.
+	Detail string
+}
+
+func (e *SpecError) Error() string {
+	if e.Field == "" {
+		return e.Detail
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Detail)
+}
+
+// ParseRateLimiterSpec decodes a JSON RateLimiterSpec, rejecting unknown
+// fields and any data after the spec. Fields of the wrong type and unknown
+// fields are reported in the Field of the *SpecError; the latter by name only,
+// as encoding/json does not report where they were found.
+func ParseRateLimiterSpec(data []byte) (*RateLimiterSpec, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	spec := &RateLimiterSpec{}
+	if err := decoder.Decode(spec); err != nil {
+		return nil, decodeSpecError(err)
+	}
+	if err := decoder.Decode(&json.RawMessage{}); err != io.EOF {
+		return nil, &SpecError{Detail: "unexpected data after the rate limiter spec"}
+	}
+	return spec, nil
+}
+
+func decodeSpecError(err error) *SpecError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &SpecError{Field: typeErr.Field, Detail: fmt.Sprintf("cannot be a JSON %s", typeErr.Value)}
+	}
+	// encoding/json has no type for unknown fields, only the message
+	// `json: unknown field "<name>"`.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if field, err := strconv.Unquote(name); err == nil {
+			return &SpecError{Field: field, Detail: "unknown field"}
+		}
+	}
+	return &SpecError{Detail: err.Error()}
+}
+
+// ParseRateLimiter decodes a JSON RateLimiterSpec and builds the RateLimiter it
+// declares.
+func ParseRateLimiter[T comparable](data []byte, options RateLimiterSpecOptions[T]) (RateLimiter[T], error) {
+	spec, err := ParseRateLimiterSpec(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewRateLimiterFromSpec(spec, options)
+}
+
+// NewRateLimiterFromSpec builds the RateLimiter declared by spec. The first
+// invalid field found is reported as a *SpecError.
+func NewRateLimiterFromSpec[T comparable](spec *RateLimiterSpec, options RateLimiterSpecOptions[T]) (RateLimiter[T], error) {
+	return buildRateLimiter(spec, "", options)
+}
+
+func buildRateLimiter[T comparable](spec *RateLimiterSpec, path string, options RateLimiterSpecOptions[T]) (RateLimiter[T], error) {
+	if spec == nil {
+		return nil, &SpecError{Field: path, Detail: "rate limiter is required"}
+	}
+
+	var set []string
+	if spec.Exponential != nil {
+		set = append(set, "exponential")
+	}
+	if spec.FastSlow != nil {
+		set = append(set, "fastSlow")
+	}
+	if spec.Bucket != nil {
+		set = append(set, "bucket")
+	}
+	if spec.CostedBucket != nil {
+		set = append(set, "costedBucket")
+	}
+	if spec.MaxOf != nil {
+		set = append(set, "maxOf")
+	}
+	if spec.WithMaxWait != nil {
+		set = append(set, "withMaxWait")
+	}
+	switch len(set) {
+	case 0:
+		return nil, &SpecError{Field: path, Detail: "exactly one rate limiter must be set"}
+	case 1:
+	default:
+		return nil, &SpecError{Field: path, Detail: fmt.Sprintf("exactly one rate limiter must be set, got %s", strings.Join(set, ", "))}
+	}
+
+	switch {
+	case spec.Exponential != nil:
+		path := joinField(path, "exponential")
+		base, err := parseSpecDuration(spec.Exponential.Base, joinField(path, "base"))
+		if err != nil {
+			return nil, err
+		}
+		max, err := parseSpecDuration(spec.Exponential.Max, joinField(path, "max"))
+		if err != nil {
+			return nil, err
+		}
+		if max < base {
+			return nil, &SpecError{Field: joinField(path, "max"), Detail: fmt.Sprintf("must not be less than base %v", base)}
+		}
+		return NewItemExponentialFailureRateLimiter[T](base, max), nil
+
+	case spec.FastSlow != nil:
+		path := joinField(path, "fastSlow")
+		fast, err := parseSpecDuration(spec.FastSlow.Fast, joinField(path, "fast"))
+		if err != nil {
+			return nil, err
+		}
+		slow, err := parseSpecDuration(spec.FastSlow.Slow, joinField(path, "slow"))
+		if err != nil {
+			return nil, err
+		}
+		if spec.FastSlow.MaxFastAttempts < 0 {
+			return nil, &SpecError{Field: joinField(path, "maxFastAttempts"), Detail: fmt.Sprintf("must not be negative, got %d", spec.FastSlow.MaxFastAttempts)}
+		}
+		return NewItemFastSlowRateLimiter[T](fast, slow, spec.FastSlow.MaxFastAttempts), nil
+
+	case spec.Bucket != nil:
+		limiter, err := buildBucket(spec.Bucket, joinField(path, "bucket"))
+		if err != nil {
+			return nil, err
+		}
+		return &BucketRateLimiter[T]{Limiter: limiter}, nil
+
+	case spec.CostedBucket != nil:
+		path := joinField(path, "costedBucket")
+		if options.Cost == nil {
+			return nil, &SpecError{Field: path, Detail: "requires a cost function in the options"}
+		}
+		limiter, err := buildBucket(spec.CostedBucket, path)
+		if err != nil {
+			return nil, err
+		}
+		return NewCostedBucketRateLimiter[T](limiter, options.Cost), nil
+
+	case spec.MaxOf != nil:
+		path := joinField(path, "maxOf")
+		if len(spec.MaxOf) == 0 {
+			return nil, &SpecError{Field: path, Detail: "must contain at least one rate limiter"}
+		}
+		limiters := make([]RateLimiter[T], 0, len(spec.MaxOf))
+		for i := range spec.MaxOf {
+			limiter, err := buildRateLimiter(&spec.MaxOf[i], fmt.Sprintf("%s[%d]", path, i), options)
+			if err != nil {
+				return nil, err
+			}
+			limiters = append(limiters, limiter)
+		}
+		return NewMaxOfRateLimiter(limiters...), nil
+
+	default:
+		path := joinField(path, "withMaxWait")
+		maxWait, err := parseSpecDuration(spec.WithMaxWait.MaxWait, joinField(path, "maxWait"))
+		if err != nil {
+			return nil, err
+		}
+		limiter, err := buildRateLimiter(spec.WithMaxWait.Limiter, joinField(path, "limiter"), options)
+		if err != nil {
+			return nil, err
+		}
+		return NewWithMaxWaitRateLimiter(limiter, maxWait), nil
+	}
+}
+
+func buildBucket(spec *BucketSpec, path string) (*rate.Limiter, error) {
+	if spec.QPS <= 0 {
+		return nil, &SpecError{Field: joinField(path, "qps"), Detail: fmt.Sprintf("must be positive, got %v", spec.QPS)}
+	}
+	if spec.Burst <= 0 {
+		return nil, &SpecError{Field: joinField(path, "burst"), Detail: fmt.Sprintf("must be positive, got %d", spec.Burst)}
+	}
+	return rate.NewLimiter(rate.Limit(spec.QPS), spec.Burst), nil
+}
+
+func parseSpecDuration(value, path string) (time.Duration, error) {
+	if value == "" {
+		return 0, &SpecError{Field: path, Detail: "is required"}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, &SpecError{Field: path, Detail: fmt.Sprintf("invalid duration %q", value)}
+	}
+	if d < 0 {
+		return 0, &SpecError{Field: path, Detail: fmt.Sprintf("must not be negative, got %v", d)}
+	}
+	return d, nil
+}
+
+func joinField(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// ReloadableRateLimiter forwards to a RateLimiter which can be replaced while
+// in use, e.g. after an operator changed its spec. The failure counts of the
+// per-item limiters are carried over to the replacement.
+type ReloadableRateLimiter[T comparable] struct {
+	lock    sync.RWMutex
+	limiter RateLimiter[T]
+}
+
+var _ StatefulRateLimiter[any] = &ReloadableRateLimiter[any]{}
+
+func NewReloadableRateLimiter[T comparable](limiter RateLimiter[T]) *ReloadableRateLimiter[T] {
+	return &ReloadableRateLimiter[T]{limiter: limiter}
+}
+
+func (r *ReloadableRateLimiter[T]) When(item T) time.Duration {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.limiter.When(item)
+}
+
+func (r *ReloadableRateLimiter[T]) NumRequeues(item T) int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.limiter.NumRequeues(item)
+}
+
+func (r *ReloadableRateLimiter[T]) Forget(item T) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	r.limiter.Forget(item)
+}
+
+// Swap replaces the current limiter with limiter. No When, Forget or
+// NumRequeues call observes a mix of the two.
+func (r *ReloadableRateLimiter[T]) Swap(limiter RateLimiter[T]) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if from, ok := r.limiter.(StatefulRateLimiter[T]); ok {
+		if to, ok := limiter.(StatefulRateLimiter[T]); ok {
+			to.Restore(from.Snapshot())
+		}
+	}
+	r.limiter = limiter
+}
+
+// Reload builds a limiter from the JSON spec in data and swaps it in. The
+// current limiter stays in place if the spec is invalid.
+func (r *ReloadableRateLimiter[T]) Reload(data []byte, options RateLimiterSpecOptions[T]) error {
+	limiter, err := ParseRateLimiter(data, options)
+	if err != nil {
+		return err
+	}
+	r.Swap(limiter)
+	return nil
+}
+
+func (r *ReloadableRateLimiter[T]) Snapshot() RateLimiterState[T] {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if stateful, ok := r.limiter.(StatefulRateLimiter[T]); ok {
+		return stateful.Snapshot()
+	}
+	return RateLimiterState[T]{Failures: map[T]int{}}
+}
+
+func (r *ReloadableRateLimiter[T]) Restore(state RateLimiterState[T]) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if stateful, ok := r.limiter.(StatefulRateLimiter[T]); ok {
+		stateful.Restore(state)
+	}
+}
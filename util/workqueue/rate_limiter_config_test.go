@@ -0,0 +1,117 @@
+package workqueue_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ForbiddenR/jxclient-go/util/workqueue"
+)
+
+func TestParseRateLimiter(t *testing.T) {
+	limiter, err := workqueue.ParseRateLimiter([]byte(`{"withMaxWait": {
+		"maxWait": "4ms",
+		"limiter": {"maxOf": [
+			{"exponential": {"base": "1ms", "max": "1s"}},
+			{"bucket": {"qps": 1000, "burst": 100}},
+			{"fastSlow": {"fast": "2ms", "slow": "10s", "maxFastAttempts": 2}}
+		]}
+	}}`), workqueue.RateLimiterSpecOptions[string]{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, e := range []time.Duration{2 * time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond} {
+		if a := limiter.When("one"); e != a {
+			t.Errorf("Expected %v, got %v", e, a)
+		}
+	}
+	if e, a := 3, limiter.NumRequeues("one"); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	limiter.Forget("one")
+	if e, a := 0, limiter.NumRequeues("one"); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+}
+
+func TestParseRateLimiterCostedBucket(t *testing.T) {
+	spec := []byte(`{"costedBucket": {"qps": 1, "burst": 10}}`)
+	_, err := workqueue.ParseRateLimiter(spec, workqueue.RateLimiterSpecOptions[int]{})
+	var specErr *workqueue.SpecError
+	if !errors.As(err, &specErr) || specErr.Field != "costedBucket" {
+		t.Errorf("Expected an error for costedBucket, got %v", err)
+	}
+
+	limiter, err := workqueue.ParseRateLimiter(spec, workqueue.RateLimiterSpecOptions[int]{
+		Cost: func(terminals int) int { return terminals },
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	limiter.When(10)
+	if a := limiter.When(1); a < 900*time.Millisecond {
+		t.Errorf("Expected the bucket to be drained, got %v", a)
+	}
+}
+
+func TestParseRateLimiterErrors(t *testing.T) {
+	tests := []struct {
+		spec  string
+		field string
+	}{
+		{spec: `{}`, field: ""},
+		{spec: `{"bucket": {"qps": 1, "burst": 1}, "fastSlow": {}}`, field: ""},
+		{spec: `{"exponential": {"base": "5ms"}}`, field: "exponential.max"},
+		{spec: `{"exponential": {"base": "5ms", "max": "1ms"}}`, field: "exponential.max"},
+		{spec: `{"maxOf": []}`, field: "maxOf"},
+		{spec: `{"maxOf": [{"exponential": {"base": "1ms", "max": "1s"}}, {"bucket": {"qps": 0, "burst": 100}}]}`, field: "maxOf[1].bucket.qps"},
+		{spec: `{"withMaxWait": {"maxWait": "1m"}}`, field: "withMaxWait.limiter"},
+		{spec: `{"withMaxWait": {"maxWait": "soon", "limiter": {"bucket": {"qps": 1, "burst": 1}}}}`, field: "withMaxWait.maxWait"},
+		{spec: `{"fastSlow": {"fast": "1ms", "slow": "-1s"}}`, field: "fastSlow.slow"},
+		{spec: `{"fastSlow": {"fast": "1ms", "slow": "1s", "maxFastAttempts": -1}}`, field: "fastSlow.maxFastAttempts"},
+		{spec: `{"bucket": {"qps": 1, "burst": 1, "extra": true}}`, field: "extra"},
+		{spec: `{"bucket": {"qps": "fast", "burst": 1}}`, field: "bucket.qps"},
+		{spec: `{"withMaxWait": {"maxWait": 60, "limiter": {"bucket": {"qps": 1, "burst": 1}}}}`, field: "withMaxWait.maxWait"},
+		{spec: `{"bucket": {"qps": 1, "burst": 1}} {"bucket": {"qps": 2, "burst": 2}}`, field: ""},
+		{spec: `{"bucket": {"qps": 1, "burst": 1}} trailing`, field: ""},
+	}
+	for _, test := range tests {
+		_, err := workqueue.ParseRateLimiter([]byte(test.spec), workqueue.RateLimiterSpecOptions[string]{})
+		var specErr *workqueue.SpecError
+		if !errors.As(err, &specErr) {
+			t.Errorf("%s: expected a spec error, got %v", test.spec, err)
+			continue
+		}
+		if specErr.Field != test.field {
+			t.Errorf("%s: expected field %q, got %q (%v)", test.spec, test.field, specErr.Field, err)
+		}
+	}
+}
+
+func TestReloadableRateLimiter(t *testing.T) {
+	limiter := workqueue.NewReloadableRateLimiter(workqueue.NewItemExponentialFailureRateLimiter[string](time.Millisecond, time.Second))
+	limiter.When("one")
+	limiter.When("one")
+
+	err := limiter.Reload([]byte(`{"maxOf": [
+		{"exponential": {"base": "10ms", "max": "1s"}},
+		{"bucket": {"qps": 1000, "burst": 100}}
+	]}`), workqueue.RateLimiterSpecOptions[string]{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if e, a := 2, limiter.NumRequeues("one"); e != a {
+		t.Errorf("Expected failures to be preserved, expected %v, got %v", e, a)
+	}
+	if e, a := 40*time.Millisecond, limiter.When("one"); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+
+	if err := limiter.Reload([]byte(`{"bucket": {}}`), workqueue.RateLimiterSpecOptions[string]{}); err == nil {
+		t.Errorf("Expected an invalid spec to be rejected")
+	}
+	if e, a := 3, limiter.NumRequeues("one"); e != a {
+		t.Errorf("Expected the limiter to be kept, expected %v, got %v", e, a)
+	}
+}
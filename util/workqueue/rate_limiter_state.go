@@ -0,0 +1,77 @@
+package workqueue
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StatefulRateLimiter is a RateLimiter whose per-item failure state can be
+// saved and restored, e.g. across restarts, so that flapping items keep
+// backing off instead of starting again from the base delay.
+type StatefulRateLimiter[T comparable] interface {
+	RateLimiter[T]
+	// Snapshot returns a copy of the current state.
+	Snapshot() RateLimiterState[T]
+	// Restore replaces the current state with state.
+	Restore(state RateLimiterState[T])
+}
+
+// RateLimiterState is the per-item failure state of a StatefulRateLimiter.
+type RateLimiterState[T comparable] struct {
+	// Failures holds how many failures each tracked item has had.
+	Failures map[T]int
+}
+
+// RateLimiterStateCodec turns a RateLimiterState into bytes and back.
+type RateLimiterStateCodec[T comparable] interface {
+	Encode(state RateLimiterState[T]) ([]byte, error)
+	Decode(data []byte) (RateLimiterState[T], error)
+}
+
+// JSONRateLimiterStateCodec encodes a RateLimiterState as JSON. Items are
+// encoded with encoding/json, so T must round trip through it.
+type JSONRateLimiterStateCodec[T comparable] struct{}
+
+var _ RateLimiterStateCodec[string] = JSONRateLimiterStateCodec[string]{}
+
+const rateLimiterStateVersion = 1
+
+type encodedRateLimiterState[T comparable] struct {
+	Version  int                      `json:"version"`
+	Failures []encodedItemFailures[T] `json:"failures"`
+}
+
+type encodedItemFailures[T comparable] struct {
+	Item     T   `json:"item"`
+	Failures int `json:"failures"`
+}
+
+func (JSONRateLimiterStateCodec[T]) Encode(state RateLimiterState[T]) ([]byte, error) {
+	encoded := encodedRateLimiterState[T]{
+		Version:  rateLimiterStateVersion,
+		Failures: make([]encodedItemFailures[T], 0, len(state.Failures)),
+	}
+	for item, failures := range state.Failures {
+		encoded.Failures = append(encoded.Failures, encodedItemFailures[T]{Item: item, Failures: failures})
+	}
+	return json.Marshal(encoded)
+}
+
+func (JSONRateLimiterStateCodec[T]) Decode(data []byte) (RateLimiterState[T], error) {
+	var encoded encodedRateLimiterState[T]
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return RateLimiterState[T]{}, err
+	}
+	if encoded.Version != rateLimiterStateVersion {
+		return RateLimiterState[T]{}, fmt.Errorf("unsupported rate limiter state version %d", encoded.Version)
+	}
+
+	state := RateLimiterState[T]{Failures: make(map[T]int, len(encoded.Failures))}
+	for _, item := range encoded.Failures {
+		if item.Failures < 0 {
+			return RateLimiterState[T]{}, fmt.Errorf("negative failure count %d for item %v", item.Failures, item.Item)
+		}
+		state.Failures[item.Item] = item.Failures
+	}
+	return state, nil
+}
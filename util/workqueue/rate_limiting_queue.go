@@ -1,6 +1,11 @@
 package workqueue
 
-import "github.com/ForbiddenR/jxutils/clock"
+import (
+	"context"
+	"time"
+
+	"github.com/ForbiddenR/jxutils/clock"
+)
 
 type RateLimitingInterface[T comparable] interface {
 	DelayingInterface[T]
@@ -21,15 +26,27 @@ type RateLimitingQueueConfig[T comparable] struct {
 	// Name for the queue. If unnamed, the metrics will not be registered.
 	Name string
 
+	// MetricsProvider optionally allows specifying a metrics provider to use for the queue.
+	MetricsProvider MetricsProvider
+
 	// Clock optionally allows injecting a read or fake clock for testing purposes.
 	Clock clock.WithTicker
 
 	// DelayingQueue optionally allows injecting custom delaying queue DelayingInterface instead of the default one.
 	DelayingQueue DelayingInterface[T]
+
+	// OnExpired is optionally called for every item dropped because its deadline passed.
+	// It is ignored if DelayingQueue is set.
+	OnExpired func(item T)
+
+	// Hooks optionally allows observing items as they move through the queue.
+	// Only OnForget is used if DelayingQueue is set.
+	Hooks QueueHooks[T]
 }
 
 // NewRateLimitingQueue constructs a new workqueue with rateLimited queuing ability
-// Remember to call Forget! If you don't, you may end up tracking failures forever.
+// Remember to call Forget! If you don't, you may end up tracking failures forever,
+// unless the per-item limiters are constructed with ItemFailureOptions.IdleExpiry.
 // NewRateLimitingQueue does not emit metrics.
 func NewRateLimitingQueue[T comparable](rateLimiter RateLimiter[T]) RateLimitingInterface[T] {
 	return NewRateLimitingQueueWithConfig[T](rateLimiter, RateLimitingQueueConfig[T]{})
@@ -45,14 +62,19 @@ func NewRateLimitingQueueWithConfig[T comparable](rateLimiter RateLimiter[T], co
 
 	if config.DelayingQueue == nil {
 		config.DelayingQueue = NewDelayingQueueWithConfig[T](DelayingQueueConfig[T]{
-			Name: config.Name,
-			Clock: config.Clock,
+			Name:            config.Name,
+			MetricsProvider: config.MetricsProvider,
+			Clock:           config.Clock,
+			OnExpired:       config.OnExpired,
+			Hooks:           config.Hooks,
 		})
 	}
 
 	return &rateLimitingType[T] {
 		DelayingInterface: config.DelayingQueue,
 		rateLimiter: rateLimiter,
+		clock: config.Clock,
+		hooks: config.Hooks,
 	}
 }
 
@@ -61,8 +83,17 @@ type rateLimitingType[T comparable] struct {
 	DelayingInterface[T]
 
 	rateLimiter RateLimiter[T]
+
+	clock clock.PassiveClock
+
+	hooks QueueHooks[T]
 }
 
+var _ Inspectable[any] = &rateLimitingType[any]{}
+var _ PausableInterface[any] = &rateLimitingType[any]{}
+var _ DrainContextInterface[any] = &rateLimitingType[any]{}
+var _ DelayingDeadlineInterface[any] = &rateLimitingType[any]{}
+
 func (q *rateLimitingType[T]) AddRateLimited(item T) {
 	q.DelayingInterface.AddAfter(item, q.rateLimiter.When(item))
 }
@@ -73,4 +104,79 @@ func (q *rateLimitingType[T]) NumRequeues(item T) int {
 
 func (q *rateLimitingType[T]) Forget(item T) {
 	q.rateLimiter.Forget(item)
-}
\ No newline at end of file
+	q.hooks.forget(item)
+}
+
+// AddWithDeadline adds item to the underlying delaying queue with deadline. If
+// the underlying queue cannot track deadlines, the item is added as long as
+// the deadline has not passed yet.
+func (q *rateLimitingType[T]) AddWithDeadline(item T, deadline time.Time) {
+	if dq, ok := q.DelayingInterface.(DeadlineInterface[T]); ok {
+		dq.AddWithDeadline(item, deadline)
+		return
+	}
+	addBeforeDeadline[T](q.DelayingInterface, q.clock, item, deadline)
+}
+
+// AddAfterWithDeadline adds item to the underlying delaying queue after
+// duration, with deadline. If the underlying queue cannot track deadlines,
+// the item is added after duration as long as the deadline will not have
+// passed by then.
+func (q *rateLimitingType[T]) AddAfterWithDeadline(item T, duration time.Duration, deadline time.Time) {
+	if dq, ok := q.DelayingInterface.(DelayingDeadlineInterface[T]); ok {
+		dq.AddAfterWithDeadline(item, duration, deadline)
+		return
+	}
+	if deadline.IsZero() || q.clock.Now().Add(duration).Before(deadline) {
+		q.DelayingInterface.AddAfter(item, duration)
+	}
+}
+
+// ShutDownWithDrainContext shuts the underlying delaying queue down and
+// waits for it to drain until ctx is done. If the underlying queue is not a
+// DrainContextInterface, its drain goes on in the background once ctx is
+// done, and the error of ctx is returned.
+func (q *rateLimitingType[T]) ShutDownWithDrainContext(ctx context.Context) error {
+	if dq, ok := q.DelayingInterface.(DrainContextInterface[T]); ok {
+		return dq.ShutDownWithDrainContext(ctx)
+	}
+	return shutDownWithDrainContext[T](ctx, q.DelayingInterface)
+}
+
+// Pause pauses the underlying delaying queue, if it is a PausableInterface.
+func (q *rateLimitingType[T]) Pause() {
+	if pq, ok := q.DelayingInterface.(PausableInterface[T]); ok {
+		pq.Pause()
+	}
+}
+
+// Resume resumes the underlying delaying queue, if it is a
+// PausableInterface.
+func (q *rateLimitingType[T]) Resume() {
+	if pq, ok := q.DelayingInterface.(PausableInterface[T]); ok {
+		pq.Resume()
+	}
+}
+
+// Paused reports whether the underlying delaying queue is a paused
+// PausableInterface.
+func (q *rateLimitingType[T]) Paused() bool {
+	if pq, ok := q.DelayingInterface.(PausableInterface[T]); ok {
+		return pq.Paused()
+	}
+	return false
+}
+
+// Snapshot returns the items held by the underlying delaying queue, if it is
+// Inspectable, along with the failures tracked by the rate limiter, if it is
+// a StatefulRateLimiter.
+func (q *rateLimitingType[T]) Snapshot() Snapshot[T] {
+	var snapshot Snapshot[T]
+	if iq, ok := q.DelayingInterface.(Inspectable[T]); ok {
+		snapshot = iq.Snapshot()
+	}
+	if stateful, ok := q.rateLimiter.(StatefulRateLimiter[T]); ok {
+		snapshot.Retrying = stateful.Snapshot().Failures
+	}
+	return snapshot
+}